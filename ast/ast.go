@@ -2,9 +2,11 @@ package ast
 
 import "github.com/bweir/lame/token"
 
-// base interface of the AST
+// base interface of the AST. Pos returns the position of the first
+// character of a node and End the position just after its last character.
 type Node interface {
 	Pos() token.Pos
+	End() token.Pos
 }
 
 // Blocks
@@ -28,16 +30,49 @@ type Declaration interface {
 // Block definitions
 
 type (
+	// A ConBlock holds *ConstantDeclaration nodes, and *BadDeclaration
+	// nodes where the source could not be parsed.
 	ConBlock struct {
 		From, To     token.Pos
-		Declarations []ConstantDeclaration
+		Declarations []Declaration
 	}
 
-	DatBlock struct{ From, To token.Pos }
-	ObjBlock struct{ From, To token.Pos }
-	PriBlock struct{ From, To token.Pos }
-	PubBlock struct{ From, To token.Pos }
-	VarBlock struct{ From, To token.Pos }
+	DatBlock struct {
+		From, To token.Pos
+		Entries  []DataEntry
+	}
+
+	// An ObjBlock holds *ObjectDeclaration nodes, and *BadDeclaration
+	// nodes where the source could not be parsed.
+	ObjBlock struct {
+		From, To     token.Pos
+		Declarations []Declaration
+	}
+
+	PriBlock struct {
+		From, To   token.Pos
+		Name       string
+		Parameters []*Identifier
+		Result     *Identifier // or nil
+		Locals     []*LocalDeclaration
+		Body       []Statement
+	}
+
+	PubBlock struct {
+		From, To   token.Pos
+		Name       string
+		Parameters []*Identifier
+		Result     *Identifier // or nil
+		Locals     []*LocalDeclaration
+		Body       []Statement
+	}
+
+	// A VarBlock holds *VariableDeclaration nodes, and *BadDeclaration
+	// nodes where the source could not be parsed.
+	VarBlock struct {
+		From, To     token.Pos
+		Declarations []Declaration
+	}
 )
 
 func (b *ConBlock) Pos() token.Pos { return b.From }
@@ -47,6 +82,13 @@ func (b *PriBlock) Pos() token.Pos { return b.From }
 func (b *PubBlock) Pos() token.Pos { return b.From }
 func (b *VarBlock) Pos() token.Pos { return b.From }
 
+func (b *ConBlock) End() token.Pos { return b.To }
+func (b *DatBlock) End() token.Pos { return b.To }
+func (b *ObjBlock) End() token.Pos { return b.To }
+func (b *PriBlock) End() token.Pos { return b.To }
+func (b *PubBlock) End() token.Pos { return b.To }
+func (b *VarBlock) End() token.Pos { return b.To }
+
 func (*ConBlock) blockNode() {}
 func (*DatBlock) blockNode() {}
 func (*ObjBlock) blockNode() {}
@@ -54,6 +96,39 @@ func (*PriBlock) blockNode() {}
 func (*PubBlock) blockNode() {}
 func (*VarBlock) blockNode() {}
 
+// Error nodes stand in for source that could not be parsed, so that the
+// parser can return the rest of the object.
+
+type (
+	// A BadBlock holds text outside of any block.
+	BadBlock struct{ From, To token.Pos }
+
+	// A BadDeclaration is a declaration or DAT entry with a syntax error.
+	BadDeclaration struct{ From, To token.Pos }
+
+	// A BadStatement is a statement with a syntax error.
+	BadStatement struct{ From, To token.Pos }
+
+	// A BadExpression is an expression with a syntax error.
+	BadExpression struct{ From, To token.Pos }
+)
+
+func (b *BadBlock) Pos() token.Pos       { return b.From }
+func (d *BadDeclaration) Pos() token.Pos { return d.From }
+func (s *BadStatement) Pos() token.Pos   { return s.From }
+func (e *BadExpression) Pos() token.Pos  { return e.From }
+
+func (b *BadBlock) End() token.Pos       { return b.To }
+func (d *BadDeclaration) End() token.Pos { return d.To }
+func (s *BadStatement) End() token.Pos   { return s.To }
+func (e *BadExpression) End() token.Pos  { return e.To }
+
+func (*BadBlock) blockNode()             {}
+func (*BadDeclaration) declarationNode() {}
+func (*BadDeclaration) dataEntryNode()   {}
+func (*BadStatement) statementNode()     {}
+func (*BadExpression) expressionNode()   {}
+
 // Statement definitions
 
 type (
@@ -62,23 +137,68 @@ type (
 
 func (s *ConStatement) Pos() token.Pos { return s.From }
 
+func (s *ConStatement) End() token.Pos { return s.To }
+
 func (*ConStatement) statementNode() {}
 
-// Statement definitions
+// Declaration definitions
 
 type (
 	ConstantDeclaration struct {
 		From, To token.Pos
 		Name     string
-		Value    string
+		Value    Expression
+	}
+
+	// An ObjectDeclaration declares an object instance, or an array of
+	// Count instances, of the object at Path.
+	ObjectDeclaration struct {
+		From, To token.Pos
+		Name     string
+		Count    Expression // or nil
+		Path     string
+	}
+
+	// A VariableDeclaration declares a variable, or an array of Count
+	// variables, of the given Size.
+	VariableDeclaration struct {
+		From, To token.Pos
+		Size     token.Type // BYTE, WORD or LONG
+		Name     string
+		Count    Expression // or nil
+	}
+
+	// A LocalDeclaration declares a method local, or an array of Count
+	// locals. Locals are always longs.
+	LocalDeclaration struct {
+		From, To token.Pos
+		Name     string
+		Count    Expression // or nil
 	}
 )
 
 func (s *ConstantDeclaration) Pos() token.Pos { return s.From }
+func (s *ObjectDeclaration) Pos() token.Pos   { return s.From }
+func (s *VariableDeclaration) Pos() token.Pos { return s.From }
+func (s *LocalDeclaration) Pos() token.Pos    { return s.From }
+
+func (s *ConstantDeclaration) End() token.Pos { return s.To }
+func (s *ObjectDeclaration) End() token.Pos   { return s.To }
+func (s *VariableDeclaration) End() token.Pos { return s.To }
+func (s *LocalDeclaration) End() token.Pos    { return s.To }
 
 func (*ConstantDeclaration) declarationNode() {}
+func (*ObjectDeclaration) declarationNode()   {}
+func (*VariableDeclaration) declarationNode() {}
+func (*LocalDeclaration) declarationNode()    {}
 
-// An object represents a Lame object.
+// An object represents a Lame object. Comments holds all comments in
+// source order; see CommentMap to find the nodes they belong to.
 type Object struct {
-	Blocks []Block
+	From, To token.Pos
+	Blocks   []Block
+	Comments []*CommentGroup
 }
+
+func (o *Object) Pos() token.Pos { return o.From }
+func (o *Object) End() token.Pos { return o.To }
@@ -0,0 +1,398 @@
+// Package astutil contains utilities for working with the Lame AST.
+package astutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/bweir/lame/ast"
+)
+
+// An ApplyFunc is invoked by Apply for each node n, even if n is nil,
+// before and/or after the node's children, using a Cursor describing
+// the current node and providing operations on it.
+//
+// The return value of ApplyFunc controls the syntax tree traversal.
+// See Apply for details.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses a syntax tree recursively, starting with root, and
+// calling pre and post for each node:
+//
+//   - If pre is not nil, it is called for each node before the node's
+//     children are traversed (pre-order). If pre returns false, no
+//     children are traversed, and post is not called for that node.
+//
+//   - If post is not nil, and a prior call of pre didn't return false,
+//     post is called for each node after its children are traversed
+//     (post-order). If post returns false, traversal is terminated and
+//     Apply returns immediately.
+//
+// Only fields that refer to AST nodes are considered children; i.e.,
+// token.Pos, strings, token types and other fields are not traversed.
+// Children are traversed in source order.
+//
+// Apply returns the syntax tree, possibly modified. If pre or post
+// replace the root node, the new root is returned.
+func Apply(root ast.Node, pre, post ApplyFunc) (result ast.Node) {
+	parent := &struct{ ast.Node }{root}
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = parent.Node
+	}()
+	a := &application{pre: pre, post: post}
+	a.apply(parent, "Node", nil, root)
+	return
+}
+
+var abort = new(int) // singleton, to signal termination of Apply
+
+// A Cursor describes a node encountered during Apply. Information about
+// the node and its parent is available from the Node, Parent, Name, and
+// Index methods.
+//
+// If p is a variable of type and value of the current parent node c.Parent(),
+// and f is the field identifier with name c.Name(), the following
+// invariants hold:
+//
+//	p.f            == c.Node()  if c.Index() <  0
+//	p.f[c.Index()] == c.Node()  if c.Index() >= 0
+//
+// The methods Replace, Delete, InsertBefore, and InsertAfter can be used
+// to change the AST without disrupting Apply.
+type Cursor struct {
+	parent ast.Node
+	name   string
+	iter   *iterator // valid if non-nil
+	node   ast.Node
+}
+
+// Node returns the current Node.
+func (c *Cursor) Node() ast.Node { return c.node }
+
+// Parent returns the parent of the current Node.
+func (c *Cursor) Parent() ast.Node { return c.parent }
+
+// Name returns the name of the parent Node field that contains the
+// current Node, e.g. "Body" or "Condition".
+func (c *Cursor) Name() string { return c.name }
+
+// Index reports the index >= 0 of the current Node in the slice of Nodes
+// that contains it, or a value < 0 if the current Node is not part of a
+// slice. The index of the current node changes if InsertBefore is called
+// while processing the current node.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// field returns the current node's parent field value.
+func (c *Cursor) field() reflect.Value {
+	return reflect.Indirect(reflect.ValueOf(c.parent)).FieldByName(c.name)
+}
+
+// Replace replaces the current Node with n. The replacement node is not
+// walked by Apply.
+func (c *Cursor) Replace(n ast.Node) {
+	v := c.field()
+	if i := c.Index(); i >= 0 {
+		v = v.Index(i)
+	}
+	v.Set(reflect.ValueOf(n))
+	c.node = n
+}
+
+// Delete deletes the current Node from its containing slice. If the
+// current Node is not part of a slice, Delete panics.
+func (c *Cursor) Delete() {
+	i := c.Index()
+	if i < 0 {
+		panic("Delete node not contained in slice")
+	}
+	v := c.field()
+	l := v.Len()
+	reflect.Copy(v.Slice(i, l), v.Slice(i+1, l))
+	v.Index(l - 1).Set(reflect.Zero(v.Type().Elem()))
+	v.SetLen(l - 1)
+	c.iter.step--
+}
+
+// InsertAfter inserts n after the current Node in its containing slice.
+// If the current Node is not part of a slice, InsertAfter panics. Apply
+// does not walk n.
+func (c *Cursor) InsertAfter(n ast.Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertAfter node not contained in slice")
+	}
+	v := c.field()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+2, l), v.Slice(i+1, l))
+	v.Index(i + 1).Set(reflect.ValueOf(n))
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the current Node in its containing
+// slice. If the current Node is not part of a slice, InsertBefore panics.
+// Apply will not walk n.
+func (c *Cursor) InsertBefore(n ast.Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertBefore node not contained in slice")
+	}
+	v := c.field()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+1, l), v.Slice(i, l))
+	v.Index(i).Set(reflect.ValueOf(n))
+	c.iter.index++
+}
+
+// application carries all the shared data so we can pass it around
+// cheaply.
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+	iter      iterator
+}
+
+func (a *application) apply(parent ast.Node, name string, iter *iterator, n ast.Node) {
+	// convert typed nil into untyped nil
+	if v := reflect.ValueOf(n); v.Kind() == reflect.Ptr && v.IsNil() {
+		n = nil
+	}
+
+	// avoid heap-allocating a new cursor for each apply call; reuse a.cursor instead
+	saved := a.cursor
+	a.cursor.parent = parent
+	a.cursor.name = name
+	a.cursor.iter = iter
+	a.cursor.node = n
+
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+
+	// walk children
+	// (the order of the cases matches the order of the corresponding node types in ast.Walk)
+	switch n := n.(type) {
+	case nil:
+		// nothing to do
+
+	// Objects and blocks
+	case *ast.Object:
+		a.applyList(n, "Blocks")
+
+	case *ast.ConBlock:
+		a.applyList(n, "Declarations")
+
+	case *ast.DatBlock:
+		a.applyList(n, "Entries")
+
+	case *ast.ObjBlock:
+		a.applyList(n, "Declarations")
+
+	case *ast.PriBlock:
+		a.applyList(n, "Parameters")
+		a.apply(n, "Result", nil, n.Result)
+		a.applyList(n, "Locals")
+		a.applyList(n, "Body")
+
+	case *ast.PubBlock:
+		a.applyList(n, "Parameters")
+		a.apply(n, "Result", nil, n.Result)
+		a.applyList(n, "Locals")
+		a.applyList(n, "Body")
+
+	case *ast.VarBlock:
+		a.applyList(n, "Declarations")
+
+	case *ast.BadBlock, *ast.BadDeclaration, *ast.BadStatement, *ast.BadExpression, *ast.ConStatement:
+		// nothing to do
+
+	// Declarations
+	case *ast.ConstantDeclaration:
+		a.apply(n, "Value", nil, n.Value)
+
+	case *ast.ObjectDeclaration:
+		a.apply(n, "Count", nil, n.Count)
+
+	case *ast.VariableDeclaration:
+		a.apply(n, "Count", nil, n.Count)
+
+	case *ast.LocalDeclaration:
+		a.apply(n, "Count", nil, n.Count)
+
+	// Expressions
+	case *ast.Identifier, *ast.NumberLiteral, *ast.StringLiteral, *ast.BooleanLiteral, *ast.CurrentAddressExpression:
+		// nothing to do
+
+	case *ast.UnaryExpression:
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.PostfixExpression:
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.BinaryExpression:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Y", nil, n.Y)
+
+	case *ast.AssignmentExpression:
+		a.apply(n, "Target", nil, n.Target)
+		a.apply(n, "Value", nil, n.Value)
+
+	case *ast.ParenExpression:
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.MemoryExpression:
+		a.apply(n, "Base", nil, n.Base)
+		a.apply(n, "Index", nil, n.Index)
+
+	case *ast.IndexExpression:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Index", nil, n.Index)
+
+	case *ast.SelectorExpression:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Name", nil, n.Name)
+
+	case *ast.CallExpression:
+		a.apply(n, "Function", nil, n.Function)
+		a.applyList(n, "Arguments")
+
+	case *ast.ObjectConstantExpression:
+		a.apply(n, "Object", nil, n.Object)
+		a.apply(n, "Name", nil, n.Name)
+
+	case *ast.StringExpression:
+		a.applyList(n, "Arguments")
+
+	case *ast.ConstantExpression:
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.LookupExpression:
+		a.apply(n, "Index", nil, n.Index)
+		a.applyList(n, "List")
+
+	case *ast.RangeExpression:
+		a.apply(n, "Low", nil, n.Low)
+		a.apply(n, "High", nil, n.High)
+
+	// Statements
+	case *ast.ExpressionStatement:
+		a.apply(n, "X", nil, n.X)
+
+	case *ast.IfStatement:
+		a.apply(n, "Condition", nil, n.Condition)
+		a.applyList(n, "Body")
+		a.apply(n, "Else", nil, n.Else)
+
+	case *ast.ElseStatement:
+		a.applyList(n, "Body")
+
+	case *ast.RepeatStatement:
+		a.apply(n, "Count", nil, n.Count)
+		a.applyList(n, "Body")
+
+	case *ast.RepeatRangeStatement:
+		a.apply(n, "Variable", nil, n.Variable)
+		a.apply(n, "Start", nil, n.Start)
+		a.apply(n, "Stop", nil, n.Stop)
+		a.apply(n, "Step", nil, n.Step)
+		a.applyList(n, "Body")
+
+	case *ast.RepeatWhileStatement:
+		if n.Post {
+			a.applyList(n, "Body")
+			a.apply(n, "Condition", nil, n.Condition)
+		} else {
+			a.apply(n, "Condition", nil, n.Condition)
+			a.applyList(n, "Body")
+		}
+
+	case *ast.CaseStatement:
+		a.apply(n, "Value", nil, n.Value)
+		a.applyList(n, "Arms")
+
+	case *ast.CaseArm:
+		a.applyList(n, "Matches")
+		a.applyList(n, "Body")
+
+	case *ast.ReturnStatement:
+		a.apply(n, "Value", nil, n.Value)
+
+	case *ast.AbortStatement:
+		a.apply(n, "Value", nil, n.Value)
+
+	case *ast.NextStatement, *ast.QuitStatement:
+		// nothing to do
+
+	// DAT entries
+	case *ast.Label, *ast.FileDirective:
+		// nothing to do
+
+	case *ast.DataDirective:
+		a.applyList(n, "Values")
+
+	case *ast.DataValue:
+		a.apply(n, "Value", nil, n.Value)
+		a.apply(n, "Count", nil, n.Count)
+
+	case *ast.Instruction:
+		a.apply(n, "Destination", nil, n.Destination)
+		a.apply(n, "Source", nil, n.Source)
+
+	case *ast.OrgDirective:
+		a.apply(n, "Address", nil, n.Address)
+
+	case *ast.ResDirective:
+		a.apply(n, "Count", nil, n.Count)
+
+	case *ast.FitDirective:
+		a.apply(n, "Address", nil, n.Address)
+
+	default:
+		panic(fmt.Sprintf("Apply: unexpected node type %T", n))
+	}
+
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(abort)
+	}
+
+	a.cursor = saved
+}
+
+// An iterator controls iteration over a slice of nodes.
+type iterator struct {
+	index, step int
+}
+
+func (a *application) applyList(parent ast.Node, name string) {
+	// avoid heap-allocating a new iterator for each applyList call; reuse a.iter instead
+	saved := a.iter
+	a.iter.index = 0
+	for {
+		// must reload parent.name each time, since cursor modifications might change it
+		v := reflect.Indirect(reflect.ValueOf(parent)).FieldByName(name)
+		if a.iter.index >= v.Len() {
+			break
+		}
+
+		// element x may be nil in a bad AST - be cautious
+		var x ast.Node
+		if e := v.Index(a.iter.index); e.IsValid() {
+			x, _ = e.Interface().(ast.Node)
+		}
+
+		a.iter.step = 1
+		a.apply(parent, name, &a.iter, x)
+		a.iter.index += a.iter.step
+	}
+	a.iter = saved
+}
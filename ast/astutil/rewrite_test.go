@@ -0,0 +1,167 @@
+package astutil_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/ast/astutil"
+	"github.com/bweir/lame/parser"
+)
+
+func parse(t *testing.T, src string) *ast.Object {
+	t.Helper()
+	object, err := parser.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return object
+}
+
+// body returns the statement types of the first method of object, with
+// the names of called methods.
+func body(object *ast.Object) string {
+	var parts []string
+	for _, stmt := range object.Blocks[0].(*ast.PubBlock).Body {
+		part := reflect.TypeOf(stmt).Elem().Name()
+		if s, ok := stmt.(*ast.ExpressionStatement); ok {
+			if call, ok := s.X.(*ast.CallExpression); ok {
+				part = call.Function.(*ast.Identifier).Name
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func call(name string) ast.Statement {
+	return &ast.ExpressionStatement{X: &ast.CallExpression{Function: &ast.Identifier{Name: name}}}
+}
+
+func TestApply(t *testing.T) {
+	const src = "PUB main\n  a\n  b()\n  return\n  c()\n"
+
+	var tests = []struct {
+		name string
+		pre  astutil.ApplyFunc
+		exp  string
+	}{
+		{
+			name: "replace",
+			pre: func(c *astutil.Cursor) bool {
+				if id, ok := c.Node().(*ast.Identifier); ok && id.Name == "b" {
+					c.Replace(&ast.Identifier{Name: "renamed"})
+				}
+				return true
+			},
+			exp: "ExpressionStatement renamed ReturnStatement c",
+		},
+		{
+			name: "delete",
+			pre: func(c *astutil.Cursor) bool {
+				if _, ok := c.Node().(*ast.ReturnStatement); ok {
+					c.Delete()
+				}
+				return true
+			},
+			exp: "ExpressionStatement b c",
+		},
+		{
+			name: "insert",
+			pre: func(c *astutil.Cursor) bool {
+				if _, ok := c.Node().(*ast.ReturnStatement); ok {
+					c.InsertBefore(call("before"))
+					c.InsertAfter(call("after"))
+				}
+				return true
+			},
+			exp: "ExpressionStatement b before ReturnStatement after c",
+		},
+		{
+			name: "replace statement",
+			pre: func(c *astutil.Cursor) bool {
+				if c.Name() == "Body" && c.Index() == 0 {
+					c.Replace(call("first"))
+				}
+				return true
+			},
+			exp: "first b ReturnStatement c",
+		},
+	}
+
+	for _, tt := range tests {
+		object := parse(t, src)
+		result := astutil.Apply(object, tt.pre, nil)
+		if result != ast.Node(object) {
+			t.Errorf("%s: root changed", tt.name)
+		}
+		if got := body(object); got != tt.exp {
+			t.Errorf("%s: mismatch:\n  exp=%s\n  got=%s", tt.name, tt.exp, got)
+		}
+	}
+}
+
+// Ensure inserted nodes are not walked, and the remaining nodes are.
+func TestApply_InsertNotWalked(t *testing.T) {
+	object := parse(t, "PUB main\n  a()\n  b()\n")
+
+	var seen []string
+	astutil.Apply(object, func(c *astutil.Cursor) bool {
+		if id, ok := c.Node().(*ast.Identifier); ok {
+			seen = append(seen, id.Name)
+			if id.Name == "a" {
+				return true
+			}
+		}
+		if _, ok := c.Node().(*ast.ExpressionStatement); ok && c.Index() == 0 {
+			c.InsertAfter(call("inserted"))
+			c.InsertBefore(call("inserted"))
+		}
+		return true
+	}, nil)
+
+	if got := strings.Join(seen, " "); got != "a b" {
+		t.Errorf("exp=%q got=%q", "a b", got)
+	}
+	if got := body(object); got != "inserted a inserted b" {
+		t.Errorf("exp=%q got=%q", "inserted a inserted b", got)
+	}
+}
+
+// Ensure post can stop the traversal and pre can skip children.
+func TestApply_Control(t *testing.T) {
+	object := parse(t, "PUB main\n  a := b + c\n  d\n")
+
+	var seen []string
+	astutil.Apply(object, func(c *astutil.Cursor) bool {
+		if id, ok := c.Node().(*ast.Identifier); ok {
+			seen = append(seen, id.Name)
+		}
+		_, binary := c.Node().(*ast.BinaryExpression)
+		return !binary
+	}, func(c *astutil.Cursor) bool {
+		_, stmt := c.Node().(*ast.ExpressionStatement)
+		return !stmt
+	})
+
+	if got := strings.Join(seen, " "); got != "a" {
+		t.Errorf("exp=%q got=%q", "a", got)
+	}
+}
+
+// Ensure the root can be replaced.
+func TestApply_ReplaceRoot(t *testing.T) {
+	x := &ast.Identifier{Name: "x"}
+	y := &ast.Identifier{Name: "y"}
+
+	result := astutil.Apply(x, func(c *astutil.Cursor) bool {
+		if c.Node() == ast.Node(x) {
+			c.Replace(y)
+		}
+		return true
+	}, nil)
+	if result != ast.Node(y) {
+		t.Errorf("exp=%v got=%v", y, result)
+	}
+}
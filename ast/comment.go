@@ -0,0 +1,196 @@
+package ast
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bweir/lame/token"
+)
+
+// A Comment is a single comment. Text holds the comment without its
+// delimiters. Doc is set for ” and {{ }} doc comments, and Block for
+// comments in braces.
+type Comment struct {
+	From, To token.Pos
+	Text     string
+	Doc      bool
+	Block    bool
+}
+
+func (c *Comment) Pos() token.Pos { return c.From }
+func (c *Comment) End() token.Pos { return c.To }
+
+// A CommentGroup is a sequence of comments of the same kind with no
+// other tokens and no empty lines between them. A comment after code
+// starts a group of its own.
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (g *CommentGroup) Pos() token.Pos { return g.List[0].Pos() }
+func (g *CommentGroup) End() token.Pos { return g.List[len(g.List)-1].End() }
+
+// IsDoc reports whether g is a group of doc comments.
+func (g *CommentGroup) IsDoc() bool { return g.List[0].Doc }
+
+// Text returns the text of the comments in g, one line per line of
+// comment, with common leading indentation and surrounding blank lines
+// removed.
+func (g *CommentGroup) Text() string {
+	var lines []string
+	for _, c := range g.List {
+		lines = append(lines, strings.Split(c.Text, "\n")...)
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	indent := -1
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = line[indent:]
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// A CommentMap links comment groups to the nodes they describe. A group
+// after code on the same line trails the largest node that ends there. A
+// group on a line of its own leads the next node in its block. A group
+// after the last node of a block is internal to the block, unless it
+// starts at the beginning of a line and another block follows, which it
+// then leads.
+type CommentMap struct {
+	Leading  map[Node][]*CommentGroup
+	Trailing map[Node][]*CommentGroup
+	Internal map[Node][]*CommentGroup
+}
+
+// NewCommentMap links the comments of object to its nodes. The file is
+// used to find the lines of nodes and comments.
+func NewCommentMap(file *token.File, object *Object) CommentMap {
+	m := CommentMap{
+		Leading:  make(map[Node][]*CommentGroup),
+		Trailing: make(map[Node][]*CommentGroup),
+		Internal: make(map[Node][]*CommentGroup),
+	}
+
+	// Nodes in pre-order are sorted by position, with outer nodes before
+	// the inner nodes that start at the same position.
+	var nodes []Node
+	Inspect(object, func(n Node) bool {
+		if n != nil && n != Node(object) {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+	line := func(p token.Pos) int { return file.Position(p).Line }
+
+	for _, g := range object.Comments {
+		if n := trailed(nodes, g, line); n != nil {
+			m.Trailing[n] = append(m.Trailing[n], g)
+			continue
+		}
+
+		// The block that contains g, which runs up to the next block.
+		var block, next Node = object, nil
+		for i, b := range object.Blocks {
+			if b.Pos() > g.Pos() {
+				next = b
+				break
+			}
+			if i+1 == len(object.Blocks) || object.Blocks[i+1].Pos() > g.Pos() {
+				block = b
+			}
+		}
+
+		i := sort.Search(len(nodes), func(i int) bool { return nodes[i].Pos() >= g.End() })
+		switch {
+		case i < len(nodes) && (next == nil || nodes[i].Pos() < next.Pos()):
+			m.Leading[nodes[i]] = append(m.Leading[nodes[i]], g)
+		case next != nil && file.Position(g.Pos()).Column == 1:
+			m.Leading[next] = append(m.Leading[next], g)
+		default:
+			m.Internal[block] = append(m.Internal[block], g)
+		}
+	}
+	return m
+}
+
+// trailed returns the largest node that ends before g on the line where g
+// starts, or nil.
+func trailed(nodes []Node, g *CommentGroup, line func(token.Pos) int) Node {
+	var found Node
+	for _, n := range nodes {
+		if n.Pos() >= g.Pos() {
+			break
+		}
+		if n.End() > g.Pos() || line(n.End()) != line(g.Pos()) {
+			continue
+		}
+		if found == nil || n.End() > found.End() || (n.End() == found.End() && n.Pos() < found.Pos()) {
+			found = n
+		}
+	}
+	return found
+}
+
+// Doc returns the doc comment of n, or nil. This is the doc comment
+// group before n or, as is usual in Spin, the first doc comment group
+// after a method header. Otherwise a doc comment trailing n is used.
+func (m CommentMap) Doc(n Node) *CommentGroup {
+	if g := lastDoc(m.Leading[n]); g != nil {
+		return g
+	}
+
+	var body []Statement
+	switch n := n.(type) {
+	case *PubBlock:
+		body = n.Body
+	case *PriBlock:
+		body = n.Body
+	}
+	if body != nil {
+		for _, g := range m.Leading[body[0]] {
+			if g.IsDoc() {
+				return g
+			}
+		}
+	}
+	for _, g := range m.Internal[n] {
+		if g.IsDoc() && (body == nil || g.Pos() < body[0].Pos()) {
+			return g
+		}
+	}
+
+	return lastDoc(m.Trailing[n])
+}
+
+func lastDoc(groups []*CommentGroup) *CommentGroup {
+	for i := len(groups) - 1; i >= 0; i-- {
+		if groups[i].IsDoc() {
+			return groups[i]
+		}
+	}
+	return nil
+}
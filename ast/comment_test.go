@@ -0,0 +1,115 @@
+package ast_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/parser"
+)
+
+const commentSource = `' header
+
+'' Pin constants
+CON
+  LED = 16 ' the led
+  ' first
+  ' second
+  BTN = 3
+
+{{ Blink
+   the led }}
+PUB main | x
+  '' Starts blinking.
+  x := 1 ' set
+  repeat
+    x++
+  ' end of main
+
+{ block }
+PRI helper
+  return
+`
+
+// Ensure comments are grouped and linked to the nearest node.
+func TestNewCommentMap(t *testing.T) {
+	p := parser.NewParser(strings.NewReader(commentSource))
+	object, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := p.File()
+	cmap := ast.NewCommentMap(file, object)
+
+	// describe returns the kind and first line of the source of n.
+	describe := func(n ast.Node) string {
+		text := commentSource[file.Offset(n.Pos()):file.Offset(n.End())]
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[:i]
+		}
+		return reflect.TypeOf(n).Elem().Name() + " " + text
+	}
+
+	got := make(map[string]string)
+	for kind, m := range map[string]map[ast.Node][]*ast.CommentGroup{
+		"leading":  cmap.Leading,
+		"trailing": cmap.Trailing,
+		"internal": cmap.Internal,
+	} {
+		for n, groups := range m {
+			for _, g := range groups {
+				got[strings.TrimSpace(g.Text())] = kind + " " + describe(n)
+			}
+		}
+	}
+
+	exp := map[string]string{
+		"header":           "leading ConBlock CON",
+		"Pin constants":    "leading ConBlock CON",
+		"the led":          "trailing ConstantDeclaration LED = 16",
+		"first\nsecond":    "leading ConstantDeclaration BTN = 3",
+		"Blink\n  the led": "leading PubBlock PUB main | x",
+		"Starts blinking.": "leading ExpressionStatement x := 1",
+		"set":              "trailing ExpressionStatement x := 1",
+		"end of main":      "internal PubBlock PUB main | x",
+		"block":            "leading PriBlock PRI helper",
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("mismatch:\n  exp=%q\n  got=%q", exp, got)
+	}
+	if n := len(object.Comments); n != len(exp) {
+		t.Errorf("groups: exp=%d got=%d", len(exp), n)
+	}
+
+	// Doc comments are found before a block or after a method header.
+	for i, tt := range []struct {
+		block ast.Block
+		doc   string
+	}{
+		{block: object.Blocks[0], doc: "Pin constants\n"},
+		{block: object.Blocks[1], doc: "Blink\n  the led\n"},
+		{block: object.Blocks[2], doc: ""},
+	} {
+		var doc string
+		if g := cmap.Doc(tt.block); g != nil {
+			doc = g.Text()
+		}
+		if doc != tt.doc {
+			t.Errorf("%d. doc: exp=%q got=%q", i, tt.doc, doc)
+		}
+	}
+}
+
+// Ensure doc comments after a method header document the method.
+func TestCommentMap_Doc_AfterHeader(t *testing.T) {
+	p := parser.NewParser(strings.NewReader("PUB start\n  '' Starts the driver.\n  '' Call once.\n  init\n"))
+	object, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap := ast.NewCommentMap(p.File(), object)
+	if g := cmap.Doc(object.Blocks[0]); g == nil || g.Text() != "Starts the driver.\nCall once.\n" {
+		t.Errorf("unexpected doc: %#v", g)
+	}
+}
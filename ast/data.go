@@ -0,0 +1,115 @@
+package ast
+
+import "github.com/bweir/lame/token"
+
+// Data entries
+type DataEntry interface {
+	Node
+	dataEntryNode()
+}
+
+// DAT block definitions
+
+type (
+	// A Label names the address of the data that follows it. Size is the
+	// size of that data (BYTE, WORD or LONG), which is used when Spin code
+	// indexes through the label. A label on a line of its own takes the
+	// size of the next entry. Local labels keep their leading colon, e.g.
+	// ":loop", and are scoped to the previous global label.
+	Label struct {
+		From, To token.Pos
+		Name     string
+		Size     token.Type
+	}
+
+	// A DataDirective stores Values using the given Size, aligning the
+	// current address to Size first. A directive with no values only
+	// aligns.
+	DataDirective struct {
+		From, To token.Pos
+		Size     token.Type // BYTE, WORD or LONG
+		Values   []*DataValue
+	}
+
+	// A DataValue is one entry of a DataDirective, e.g. the 5[10] in
+	// "byte 5[10]" or the word $FF in "byte word $FF". Strings store one
+	// element per character.
+	DataValue struct {
+		From, To token.Pos
+		Size     token.Type // override of the directive size, or ""
+		Value    Expression
+		Count    Expression // or nil
+	}
+
+	// A FileDirective includes the bytes of the file at Path.
+	FileDirective struct {
+		From, To token.Pos
+		Path     string
+	}
+
+	// An Instruction is a PASM instruction. Condition and Effects hold
+	// upper-case names, e.g. IF_Z and WC. Immediate is set if the source
+	// operand is prefixed with #. References to local labels are
+	// identifiers with a leading colon.
+	Instruction struct {
+		From, To    token.Pos
+		Condition   string // or ""
+		Opcode      string
+		Destination Expression // or nil
+		Source      Expression // or nil
+		Immediate   bool
+		Effects     []string
+	}
+
+	// An OrgDirective sets the cog address of the instructions that
+	// follow. Address defaults to 0.
+	OrgDirective struct {
+		From, To token.Pos
+		Address  Expression // or nil
+	}
+
+	// A ResDirective reserves Count longs of cog memory without storing
+	// anything in the object. Count defaults to 1.
+	ResDirective struct {
+		From, To token.Pos
+		Count    Expression // or nil
+	}
+
+	// A FitDirective checks that the instructions so far fit below the
+	// cog address Address, which defaults to $1F0.
+	FitDirective struct {
+		From, To token.Pos
+		Address  Expression // or nil
+	}
+)
+
+func (d *Label) Pos() token.Pos         { return d.From }
+func (d *DataDirective) Pos() token.Pos { return d.From }
+func (d *DataValue) Pos() token.Pos     { return d.From }
+func (d *FileDirective) Pos() token.Pos { return d.From }
+func (d *Instruction) Pos() token.Pos   { return d.From }
+func (d *OrgDirective) Pos() token.Pos  { return d.From }
+func (d *ResDirective) Pos() token.Pos  { return d.From }
+func (d *FitDirective) Pos() token.Pos  { return d.From }
+
+func (d *Label) End() token.Pos         { return d.To }
+func (d *DataDirective) End() token.Pos { return d.To }
+func (d *DataValue) End() token.Pos     { return d.To }
+func (d *FileDirective) End() token.Pos { return d.To }
+func (d *Instruction) End() token.Pos   { return d.To }
+func (d *OrgDirective) End() token.Pos  { return d.To }
+func (d *ResDirective) End() token.Pos  { return d.To }
+func (d *FitDirective) End() token.Pos  { return d.To }
+
+// IsLocal reports whether l is a local label such as ":loop".
+func (l *Label) IsLocal() bool { return len(l.Name) > 0 && l.Name[0] == ':' }
+
+func (*Label) declarationNode() {}
+
+func (*Label) dataEntryNode()         {}
+func (*DataDirective) dataEntryNode() {}
+func (*FileDirective) dataEntryNode() {}
+func (*Instruction) dataEntryNode()   {}
+func (*OrgDirective) dataEntryNode()  {}
+func (*ResDirective) dataEntryNode()  {}
+func (*FitDirective) dataEntryNode()  {}
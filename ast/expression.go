@@ -0,0 +1,213 @@
+package ast
+
+import "github.com/bweir/lame/token"
+
+// Expressions
+type Expression interface {
+	Node
+	expressionNode()
+}
+
+// Expression definitions
+
+type (
+	// An Identifier is a name such as a constant, variable or method.
+	Identifier struct {
+		From, To token.Pos
+		Name     string
+	}
+
+	// A NumberLiteral is an integer or float literal. Kind is one of the
+	// *_NUMBER token types and Value holds the literal without its prefix.
+	NumberLiteral struct {
+		From, To token.Pos
+		Kind     token.Type
+		Value    string
+	}
+
+	// A StringLiteral is a quoted string with its escapes resolved.
+	StringLiteral struct {
+		From, To token.Pos
+		Value    string
+	}
+
+	// A BooleanLiteral is TRUE or FALSE.
+	BooleanLiteral struct {
+		From, To token.Pos
+		Value    bool
+	}
+
+	// A UnaryExpression is a prefix operator applied to an operand,
+	// e.g. -x, ||x, @x, ++x or NOT x.
+	UnaryExpression struct {
+		From, To token.Pos
+		Operator token.Type
+		X        Expression
+	}
+
+	// A PostfixExpression is an operand followed by a postfix operator,
+	// e.g. x++, x--, x~, x~~ or x?.
+	PostfixExpression struct {
+		From, To token.Pos
+		Operator token.Type
+		X        Expression
+	}
+
+	// A BinaryExpression is two operands joined by a binary operator.
+	BinaryExpression struct {
+		From, To token.Pos
+		Operator token.Type
+		X, Y     Expression
+	}
+
+	// An AssignmentExpression assigns Value to Target. Operator is ASSIGN
+	// or one of the compound *_ASSIGN token types. Assignments are
+	// expressions in Spin and yield the assigned value.
+	AssignmentExpression struct {
+		From, To token.Pos
+		Operator token.Type
+		Target   Expression
+		Value    Expression
+	}
+
+	// A ParenExpression is a parenthesized expression.
+	ParenExpression struct {
+		From, To token.Pos
+		X        Expression
+	}
+
+	// A MemoryExpression reads main memory directly, e.g.
+	// byte[base] or word[base][index].
+	MemoryExpression struct {
+		From, To token.Pos
+		Size     token.Type // BYTE, WORD or LONG
+		Base     Expression
+		Index    Expression // or nil
+	}
+
+	// An IndexExpression indexes an array variable or object array.
+	IndexExpression struct {
+		From, To token.Pos
+		X        Expression
+		Index    Expression
+	}
+
+	// A SelectorExpression selects a method of an object, e.g.
+	// obj.method or obj[i].method.
+	SelectorExpression struct {
+		From, To token.Pos
+		X        Expression
+		Name     *Identifier
+	}
+
+	// A CallExpression calls a method, e.g. method(args) or
+	// obj.method(args).
+	CallExpression struct {
+		From, To  token.Pos
+		Function  Expression
+		Arguments []Expression
+	}
+
+	// An ObjectConstantExpression references a constant of another
+	// object, e.g. obj#CONST.
+	ObjectConstantExpression struct {
+		From, To token.Pos
+		Object   *Identifier
+		Name     *Identifier
+	}
+
+	// A StringExpression is a string(...) expression, which stores its
+	// arguments as a zero-terminated string and yields its address.
+	StringExpression struct {
+		From, To  token.Pos
+		Arguments []Expression
+	}
+
+	// A ConstantExpression is a constant(...) expression, which must be
+	// evaluated at compile time.
+	ConstantExpression struct {
+		From, To token.Pos
+		X        Expression
+	}
+
+	// A LookupExpression is a lookup, lookupz, lookdown or lookdownz
+	// expression. Function holds the upper-case name.
+	LookupExpression struct {
+		From, To token.Pos
+		Function string
+		Index    Expression
+		List     []Expression
+	}
+
+	// A CurrentAddressExpression is the $ symbol, which stands for the
+	// cog address of the current PASM instruction.
+	CurrentAddressExpression struct{ From, To token.Pos }
+
+	// A RangeExpression is a range of values used in case matches and
+	// lookup lists, e.g. "0".."9".
+	RangeExpression struct {
+		From, To token.Pos
+		Low      Expression
+		High     Expression
+	}
+)
+
+func (e *Identifier) Pos() token.Pos               { return e.From }
+func (e *NumberLiteral) Pos() token.Pos            { return e.From }
+func (e *StringLiteral) Pos() token.Pos            { return e.From }
+func (e *BooleanLiteral) Pos() token.Pos           { return e.From }
+func (e *UnaryExpression) Pos() token.Pos          { return e.From }
+func (e *PostfixExpression) Pos() token.Pos        { return e.From }
+func (e *BinaryExpression) Pos() token.Pos         { return e.From }
+func (e *AssignmentExpression) Pos() token.Pos     { return e.From }
+func (e *ParenExpression) Pos() token.Pos          { return e.From }
+func (e *MemoryExpression) Pos() token.Pos         { return e.From }
+func (e *IndexExpression) Pos() token.Pos          { return e.From }
+func (e *SelectorExpression) Pos() token.Pos       { return e.From }
+func (e *CallExpression) Pos() token.Pos           { return e.From }
+func (e *ObjectConstantExpression) Pos() token.Pos { return e.From }
+func (e *StringExpression) Pos() token.Pos         { return e.From }
+func (e *ConstantExpression) Pos() token.Pos       { return e.From }
+func (e *LookupExpression) Pos() token.Pos         { return e.From }
+func (e *CurrentAddressExpression) Pos() token.Pos { return e.From }
+func (e *RangeExpression) Pos() token.Pos          { return e.From }
+
+func (e *Identifier) End() token.Pos               { return e.To }
+func (e *NumberLiteral) End() token.Pos            { return e.To }
+func (e *StringLiteral) End() token.Pos            { return e.To }
+func (e *BooleanLiteral) End() token.Pos           { return e.To }
+func (e *UnaryExpression) End() token.Pos          { return e.To }
+func (e *PostfixExpression) End() token.Pos        { return e.To }
+func (e *BinaryExpression) End() token.Pos         { return e.To }
+func (e *AssignmentExpression) End() token.Pos     { return e.To }
+func (e *ParenExpression) End() token.Pos          { return e.To }
+func (e *MemoryExpression) End() token.Pos         { return e.To }
+func (e *IndexExpression) End() token.Pos          { return e.To }
+func (e *SelectorExpression) End() token.Pos       { return e.To }
+func (e *CallExpression) End() token.Pos           { return e.To }
+func (e *ObjectConstantExpression) End() token.Pos { return e.To }
+func (e *StringExpression) End() token.Pos         { return e.To }
+func (e *ConstantExpression) End() token.Pos       { return e.To }
+func (e *LookupExpression) End() token.Pos         { return e.To }
+func (e *CurrentAddressExpression) End() token.Pos { return e.To }
+func (e *RangeExpression) End() token.Pos          { return e.To }
+
+func (*Identifier) expressionNode()               {}
+func (*NumberLiteral) expressionNode()            {}
+func (*StringLiteral) expressionNode()            {}
+func (*BooleanLiteral) expressionNode()           {}
+func (*UnaryExpression) expressionNode()          {}
+func (*PostfixExpression) expressionNode()        {}
+func (*BinaryExpression) expressionNode()         {}
+func (*AssignmentExpression) expressionNode()     {}
+func (*ParenExpression) expressionNode()          {}
+func (*MemoryExpression) expressionNode()         {}
+func (*IndexExpression) expressionNode()          {}
+func (*SelectorExpression) expressionNode()       {}
+func (*CallExpression) expressionNode()           {}
+func (*ObjectConstantExpression) expressionNode() {}
+func (*StringExpression) expressionNode()         {}
+func (*ConstantExpression) expressionNode()       {}
+func (*LookupExpression) expressionNode()         {}
+func (*CurrentAddressExpression) expressionNode() {}
+func (*RangeExpression) expressionNode()          {}
@@ -0,0 +1,265 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bweir/lame/token"
+)
+
+// JSONVersion is the version of the JSON format written by EncodeJSON.
+// It changes whenever a node or field is renamed or removed.
+const JSONVersion = 1
+
+// nodeTypes lists the node types by kind, for decoding.
+var nodeTypes = make(map[string]reflect.Type)
+
+func init() {
+	for _, n := range []Node{
+		&Object{}, &Comment{}, &CommentGroup{},
+		&ConBlock{}, &DatBlock{}, &ObjBlock{}, &PriBlock{}, &PubBlock{}, &VarBlock{}, &BadBlock{},
+		&ConStatement{}, &ConstantDeclaration{}, &ObjectDeclaration{}, &VariableDeclaration{}, &LocalDeclaration{}, &BadDeclaration{},
+		&Label{}, &DataDirective{}, &DataValue{}, &FileDirective{}, &Instruction{}, &OrgDirective{}, &ResDirective{}, &FitDirective{},
+		&Identifier{}, &NumberLiteral{}, &StringLiteral{}, &BooleanLiteral{},
+		&UnaryExpression{}, &PostfixExpression{}, &BinaryExpression{}, &AssignmentExpression{}, &ParenExpression{},
+		&MemoryExpression{}, &IndexExpression{}, &SelectorExpression{}, &CallExpression{}, &ObjectConstantExpression{},
+		&StringExpression{}, &ConstantExpression{}, &LookupExpression{}, &CurrentAddressExpression{}, &RangeExpression{}, &BadExpression{},
+		&ExpressionStatement{}, &IfStatement{}, &ElseStatement{}, &RepeatStatement{}, &RepeatRangeStatement{}, &RepeatWhileStatement{},
+		&CaseStatement{}, &CaseArm{}, &ReturnStatement{}, &AbortStatement{}, &NextStatement{}, &QuitStatement{}, &BadStatement{},
+	} {
+		t := reflect.TypeOf(n).Elem()
+		nodeTypes[t.Name()] = t
+	}
+}
+
+// A jsonDocument is the top level of the JSON format.
+type jsonDocument struct {
+	Version  int             `json:"version"`
+	Filename string          `json:"filename,omitempty"`
+	Root     json.RawMessage `json:"root"`
+}
+
+// A jsonPosition is the start or end of a node in the JSON format.
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+// EncodeJSON writes the tree of node to w as JSON, in the format
+// described in docs/ast-json.md. If file is not nil, positions include
+// lines and columns as well as byte offsets.
+func EncodeJSON(w io.Writer, file *token.File, node Node) error {
+	e := &jsonEncoder{file: file}
+	e.value(reflect.ValueOf(node))
+
+	doc := jsonDocument{Version: JSONVersion, Root: e.buf.Bytes()}
+	if file != nil {
+		doc.Filename = file.Name
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+type jsonEncoder struct {
+	buf  bytes.Buffer
+	file *token.File
+}
+
+func (e *jsonEncoder) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+		} else if n, ok := v.Interface().(Node); ok && v.Kind() == reflect.Ptr {
+			e.node(n, v.Elem())
+		} else {
+			e.value(v.Elem())
+		}
+
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return
+		}
+		e.buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.value(v.Index(i))
+		}
+		e.buf.WriteByte(']')
+
+	default:
+		b, _ := json.Marshal(v.Interface())
+		e.buf.Write(b)
+	}
+}
+
+func (e *jsonEncoder) node(n Node, s reflect.Value) {
+	e.buf.WriteString(`{"node":`)
+	e.value(reflect.ValueOf(s.Type().Name()))
+	if n.Pos().IsValid() {
+		e.buf.WriteString(`,"start":`)
+		e.position(n.Pos())
+	}
+	if n.End().IsValid() {
+		e.buf.WriteString(`,"end":`)
+		e.position(n.End())
+	}
+
+	for i := 0; i < s.NumField(); i++ {
+		f := s.Type().Field(i)
+		if f.Name == "From" || f.Name == "To" {
+			continue
+		}
+		e.buf.WriteString(`,"` + jsonName(f.Name) + `":`)
+		e.value(s.Field(i))
+	}
+	e.buf.WriteByte('}')
+}
+
+// position writes p, whose offset is one less than its value.
+func (e *jsonEncoder) position(p token.Pos) {
+	pos := jsonPosition{Offset: int(p) - 1}
+	if e.file != nil {
+		position := e.file.Position(p)
+		pos.Line, pos.Column = position.Line, position.Column
+	}
+	b, _ := json.Marshal(pos)
+	e.buf.Write(b)
+}
+
+// jsonName returns the JSON key of a node field: the field name with a
+// lower case first letter.
+func jsonName(field string) string {
+	r, n := utf8.DecodeRuneInString(field)
+	return string(unicode.ToLower(r)) + field[n:]
+}
+
+// DecodeJSON reads a tree written by EncodeJSON. Unknown kinds and fields
+// are errors, so that a tree is never silently decoded in part.
+func DecodeJSON(r io.Reader) (Node, error) {
+	var doc jsonDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.Version != JSONVersion {
+		return nil, fmt.Errorf("unsupported AST version %d, expected %d", doc.Version, JSONVersion)
+	}
+	n, err := decodeNode(doc.Root)
+	if err != nil || !n.IsValid() {
+		return nil, err
+	}
+	return n.Interface().(Node), nil
+}
+
+// decodeNode decodes a node object, returning a pointer to the node, or
+// the zero Value for null.
+func decodeNode(data json.RawMessage) (reflect.Value, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return reflect.Value{}, err
+	}
+	if fields == nil {
+		return reflect.Value{}, nil
+	}
+
+	var kind string
+	if err := json.Unmarshal(fields["node"], &kind); err != nil {
+		return reflect.Value{}, fmt.Errorf("missing node kind")
+	}
+	t, ok := nodeTypes[kind]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown node kind %q", kind)
+	}
+	delete(fields, "node")
+
+	v := reflect.New(t)
+	for _, f := range []string{"start", "end"} {
+		raw, ok := fields[f]
+		if !ok {
+			continue
+		}
+		var pos jsonPosition
+		if err := json.Unmarshal(raw, &pos); err != nil {
+			return reflect.Value{}, fmt.Errorf("%s.%s: %s", kind, f, err)
+		}
+		// Nodes such as comment groups derive their range from their
+		// children and have no fields for it.
+		name := map[string]string{"start": "From", "end": "To"}[f]
+		if field := v.Elem().FieldByName(name); field.IsValid() {
+			field.Set(reflect.ValueOf(token.Pos(pos.Offset + 1)))
+		}
+		delete(fields, f)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		raw, ok := fields[jsonName(f.Name)]
+		if !ok {
+			continue
+		}
+		if err := decodeValue(raw, v.Elem().Field(i)); err != nil {
+			return reflect.Value{}, fmt.Errorf("%s.%s: %s", kind, jsonName(f.Name), err)
+		}
+		delete(fields, jsonName(f.Name))
+	}
+	for name := range fields {
+		return reflect.Value{}, fmt.Errorf("unknown field %q in %s", name, kind)
+	}
+	return v, nil
+}
+
+// article returns name after "a", or "an" if it starts with a vowel.
+func article(name string) string {
+	if name != "" && strings.ContainsRune("AEIOU", unicode.ToUpper(rune(name[0]))) {
+		return "an " + name
+	}
+	return "a " + name
+}
+
+// decodeValue decodes data into the field v.
+func decodeValue(data json.RawMessage, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		n, err := decodeNode(data)
+		if err != nil || !n.IsValid() {
+			return err
+		}
+		if !n.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("%s is not %s", n.Elem().Type().Name(), article(v.Type().Name()))
+		}
+		v.Set(n)
+		return nil
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Interface && v.Type().Elem().Kind() != reflect.Ptr {
+			break
+		}
+		var list []json.RawMessage
+		if err := json.Unmarshal(data, &list); err != nil || list == nil {
+			return err
+		}
+		s := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, raw := range list {
+			if err := decodeValue(raw, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return json.Unmarshal(data, v.Addr().Interface())
+}
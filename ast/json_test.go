@@ -0,0 +1,104 @@
+package ast_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/parser"
+)
+
+// Ensure the JSON format of a small object is stable.
+func TestEncodeJSON(t *testing.T) {
+	p := parser.NewFileParser("a.spin", strings.NewReader("CON\n  A = -1\n"))
+	object, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf, got bytes.Buffer
+	if err := ast.EncodeJSON(&buf, p.File(), object); err != nil {
+		t.Fatal(err)
+	} else if err := json.Compact(&got, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"version":1,"filename":"a.spin","root":{"node":"Object",` +
+		`"start":{"offset":0,"line":1,"column":1},"end":{"offset":12,"line":2,"column":9},` +
+		`"blocks":[{"node":"ConBlock","start":{"offset":0,"line":1,"column":1},"end":{"offset":12,"line":2,"column":9},` +
+		`"declarations":[{"node":"ConstantDeclaration","start":{"offset":6,"line":2,"column":3},"end":{"offset":12,"line":2,"column":9},` +
+		`"name":"A","value":{"node":"UnaryExpression","start":{"offset":10,"line":2,"column":7},"end":{"offset":12,"line":2,"column":9},` +
+		`"operator":"SUBTRACT","x":{"node":"NumberLiteral","start":{"offset":11,"line":2,"column":8},"end":{"offset":12,"line":2,"column":9},` +
+		`"kind":"DECIMAL_NUMBER","value":"1"}}}]}],"comments":null}}`
+	if got.String() != exp {
+		t.Errorf("mismatch:\n  exp=%s\n  got=%s", exp, got.String())
+	}
+}
+
+// Ensure every test object survives a round trip through JSON.
+func TestDecodeJSON_Files(t *testing.T) {
+	err := filepath.Walk("../test", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".spin" {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		p := parser.NewFileParser(path, f)
+		object, _ := p.Parse()
+
+		var buf bytes.Buffer
+		if err := ast.EncodeJSON(&buf, p.File(), object); err != nil {
+			t.Errorf("%s: encode: %s", path, err)
+			return nil
+		}
+		decoded, err := ast.DecodeJSON(&buf)
+		if err != nil {
+			t.Errorf("%s: decode: %s", path, err)
+		} else if !reflect.DeepEqual(decoded, object) {
+			t.Errorf("%s: decoded tree differs", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure malformed trees are rejected.
+func TestDecodeJSON_Errors(t *testing.T) {
+	for i, tt := range []struct {
+		src string
+		err string
+	}{
+		{src: `{"version":2,"root":null}`, err: `unsupported AST version 2, expected 1`},
+		{src: `{"version":1,"root":{"node":"Nope"}}`, err: `unknown node kind "Nope"`},
+		{src: `{"version":1,"root":{"node":"Identifier","nam":"x"}}`, err: `unknown field "nam" in Identifier`},
+		{src: `{"version":1,"root":{"node":"ExpressionStatement","x":{"node":"QuitStatement"}}}`, err: `ExpressionStatement.x: QuitStatement is not an Expression`},
+		{src: `{"version":1,"root":{"node":"IfStatement","body":[{"node":"Identifier","name":"x"}]}}`, err: `IfStatement.body: Identifier is not a Statement`},
+	} {
+		_, err := ast.DecodeJSON(strings.NewReader(tt.src))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d. error mismatch: exp=%q got=%v", i, tt.err, err)
+		}
+	}
+}
+
+// Ensure statements and blocks are written with their fields.
+func TestSExpr(t *testing.T) {
+	object := parse(t, "PUB main | x\n  if x\n    x := 1\n  quit\n")
+	exp := `(Object :blocks ((PubBlock :name "main" :locals ((LocalDeclaration :name "x")) ` +
+		`:body ((IfStatement :keyword IF :condition x :body ((ExpressionStatement :x (ASSIGN x 1)))) (QuitStatement)))))`
+	if got := ast.SExpr(object); got != exp {
+		t.Errorf("mismatch:\n  exp=%s\n  got=%s", exp, got)
+	}
+}
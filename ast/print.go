@@ -0,0 +1,95 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/bweir/lame/token"
+)
+
+// Fprint prints the tree of x to w, one node or field per line, leaving
+// out fields with zero values. If file is not nil, each node is shown
+// with its source range.
+func Fprint(w io.Writer, file *token.File, x interface{}) error {
+	p := &printer{w: w, file: file}
+	p.print(0, "", reflect.ValueOf(x))
+	return p.err
+}
+
+type printer struct {
+	w    io.Writer
+	file *token.File
+	err  error
+}
+
+func (p *printer) line(depth int, format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, strings.Repeat("  ", depth)+format+"\n", args...)
+}
+
+// span formats the source range of n as line:column-line:column.
+func (p *printer) span(n Node) string {
+	from, to := p.file.Position(n.Pos()), p.file.Position(n.End())
+	return fmt.Sprintf("%d:%d-%d:%d", from.Line, from.Column, to.Line, to.Column)
+}
+
+func (p *printer) print(depth int, label string, v reflect.Value) {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		name := v.Elem().Type().Name()
+		if n, ok := v.Interface().(Node); ok && p.file != nil {
+			name += " " + p.span(n)
+		}
+		p.line(depth, "%s%s", label, name)
+
+		s := v.Elem()
+		for i := 0; i < s.NumField(); i++ {
+			switch f := s.Type().Field(i); f.Name {
+			case "From", "To":
+			default:
+				p.print(depth+1, f.Name+": ", s.Field(i))
+			}
+		}
+
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return
+		}
+		p.line(depth, "%s", strings.TrimSuffix(label, " "))
+		for i := 0; i < v.Len(); i++ {
+			p.print(depth+1, "", v.Index(i))
+		}
+
+	case reflect.String:
+		if v.Len() == 0 {
+			return
+		}
+		if v.Type() == reflect.TypeOf(token.Type("")) {
+			p.line(depth, "%s%s", label, v.String())
+		} else {
+			p.line(depth, "%s%q", label, v.String())
+		}
+
+	case reflect.Bool:
+		if v.Bool() {
+			p.line(depth, "%strue", label)
+		}
+
+	default:
+		p.line(depth, "%s%v", label, v.Interface())
+	}
+}
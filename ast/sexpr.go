@@ -0,0 +1,127 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SExpr formats the tree of n as a single-line S-expression, which is
+// compact enough to compare tree shapes in tests. Expressions are written
+// as (OPERATOR operands...) with identifiers and numbers bare, e.g.
+// (ADD a (MULTIPLY b 2)). Other nodes are written as (Kind :field value
+// ...), leaving out fields with zero values.
+func SExpr(n Node) string {
+	var b strings.Builder
+	sexpr(&b, reflect.ValueOf(n))
+	return b.String()
+}
+
+func sexpr(b *strings.Builder, v reflect.Value) {
+	list := func(head string, xs ...Node) {
+		b.WriteString("(" + head)
+		for _, x := range xs {
+			b.WriteByte(' ')
+			sexpr(b, reflect.ValueOf(x))
+		}
+		b.WriteByte(')')
+	}
+	expressions := func(xs []Expression) []Node {
+		nodes := make([]Node, len(xs))
+		for i, x := range xs {
+			nodes[i] = x
+		}
+		return nodes
+	}
+
+	if !v.IsValid() || (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		b.WriteString("nil")
+		return
+	}
+
+	switch x := v.Interface().(type) {
+	case *Identifier:
+		b.WriteString(x.Name)
+	case *NumberLiteral:
+		b.WriteString(x.Value)
+	case *StringLiteral:
+		fmt.Fprintf(b, "%q", x.Value)
+	case *BooleanLiteral:
+		fmt.Fprint(b, x.Value)
+	case *UnaryExpression:
+		list(string(x.Operator), x.X)
+	case *PostfixExpression:
+		list("post-"+string(x.Operator), x.X)
+	case *BinaryExpression:
+		list(string(x.Operator), x.X, x.Y)
+	case *AssignmentExpression:
+		list(string(x.Operator), x.Target, x.Value)
+	case *ParenExpression:
+		list("paren", x.X)
+	case *MemoryExpression:
+		list(string(x.Size), x.Base, x.Index)
+	case *IndexExpression:
+		list("index", x.X, x.Index)
+	case *SelectorExpression:
+		list("select", x.X, x.Name)
+	case *CallExpression:
+		list("call", append([]Node{x.Function}, expressions(x.Arguments)...)...)
+	case *ObjectConstantExpression:
+		list("const", x.Object, x.Name)
+	case *StringExpression:
+		list("string", expressions(x.Arguments)...)
+	case *ConstantExpression:
+		list("constant", x.X)
+	case *LookupExpression:
+		list(x.Function, append([]Node{x.Index}, expressions(x.List)...)...)
+	case *RangeExpression:
+		list("range", x.Low, x.High)
+	case *CurrentAddressExpression:
+		b.WriteString("$")
+	default:
+		sexprFields(b, v)
+	}
+}
+
+// sexprFields writes a node as (Kind :field value ...).
+func sexprFields(b *strings.Builder, v reflect.Value) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	s := v.Elem()
+	b.WriteString("(" + s.Type().Name())
+	for i := 0; i < s.NumField(); i++ {
+		f, fv := s.Type().Field(i), s.Field(i)
+		if f.Name == "From" || f.Name == "To" || fv.IsZero() {
+			continue
+		}
+		b.WriteString(" :" + jsonName(f.Name) + " ")
+
+		switch fv.Kind() {
+		case reflect.Slice:
+			b.WriteByte('(')
+			for j := 0; j < fv.Len(); j++ {
+				if j > 0 {
+					b.WriteByte(' ')
+				}
+				if e := fv.Index(j); e.Kind() == reflect.String {
+					fmt.Fprintf(b, "%q", e.String())
+				} else {
+					sexpr(b, e)
+				}
+			}
+			b.WriteByte(')')
+		case reflect.String:
+			if _, ok := fv.Interface().(string); ok {
+				fmt.Fprintf(b, "%q", fv.String())
+			} else {
+				b.WriteString(fv.String())
+			}
+		case reflect.Bool:
+			b.WriteString("true")
+		default:
+			sexpr(b, fv)
+		}
+	}
+	b.WriteByte(')')
+}
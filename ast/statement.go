@@ -0,0 +1,131 @@
+package ast
+
+import "github.com/bweir/lame/token"
+
+// Method statement definitions
+
+type (
+	// An ExpressionStatement is an expression evaluated for its side
+	// effects, such as an assignment or a method call.
+	ExpressionStatement struct {
+		From, To token.Pos
+		X        Expression
+	}
+
+	// An IfStatement is an if or ifnot statement. Keyword is IF, IFNOT,
+	// ELSEIF or ELSEIFNOT. Else is nil, an *IfStatement for an elseif
+	// branch, or an *ElseStatement.
+	IfStatement struct {
+		From, To  token.Pos
+		Keyword   token.Type
+		Condition Expression
+		Body      []Statement
+		Else      Statement
+	}
+
+	// An ElseStatement is the final else branch of an IfStatement.
+	ElseStatement struct {
+		From, To token.Pos
+		Body     []Statement
+	}
+
+	// A RepeatStatement loops forever, or Count times if Count is set.
+	RepeatStatement struct {
+		From, To token.Pos
+		Count    Expression // or nil
+		Body     []Statement
+	}
+
+	// A RepeatRangeStatement loops Variable from Start to Stop.
+	RepeatRangeStatement struct {
+		From, To token.Pos
+		Variable Expression
+		Start    Expression
+		Stop     Expression
+		Step     Expression // or nil
+		Body     []Statement
+	}
+
+	// A RepeatWhileStatement loops while or until a condition holds.
+	// Keyword is WHILE or UNTIL. If Post is set, the condition follows
+	// the body and is checked after each iteration.
+	RepeatWhileStatement struct {
+		From, To  token.Pos
+		Keyword   token.Type
+		Condition Expression
+		Post      bool
+		Body      []Statement
+	}
+
+	// A CaseStatement runs the first arm that matches Value.
+	CaseStatement struct {
+		From, To token.Pos
+		Value    Expression
+		Arms     []*CaseArm
+	}
+
+	// A CaseArm is one arm of a CaseStatement. Matches is empty for the
+	// other arm.
+	CaseArm struct {
+		From, To token.Pos
+		Matches  []Expression
+		Other    bool
+		Body     []Statement
+	}
+
+	// A ReturnStatement returns from a method, optionally with a value.
+	ReturnStatement struct {
+		From, To token.Pos
+		Value    Expression // or nil
+	}
+
+	// An AbortStatement aborts a method, optionally with a value.
+	AbortStatement struct {
+		From, To token.Pos
+		Value    Expression // or nil
+	}
+
+	// A NextStatement skips to the next iteration of a repeat loop.
+	NextStatement struct{ From, To token.Pos }
+
+	// A QuitStatement exits a repeat loop.
+	QuitStatement struct{ From, To token.Pos }
+)
+
+func (s *ExpressionStatement) Pos() token.Pos  { return s.From }
+func (s *IfStatement) Pos() token.Pos          { return s.From }
+func (s *ElseStatement) Pos() token.Pos        { return s.From }
+func (s *RepeatStatement) Pos() token.Pos      { return s.From }
+func (s *RepeatRangeStatement) Pos() token.Pos { return s.From }
+func (s *RepeatWhileStatement) Pos() token.Pos { return s.From }
+func (s *CaseStatement) Pos() token.Pos        { return s.From }
+func (s *CaseArm) Pos() token.Pos              { return s.From }
+func (s *ReturnStatement) Pos() token.Pos      { return s.From }
+func (s *AbortStatement) Pos() token.Pos       { return s.From }
+func (s *NextStatement) Pos() token.Pos        { return s.From }
+func (s *QuitStatement) Pos() token.Pos        { return s.From }
+
+func (s *ExpressionStatement) End() token.Pos  { return s.To }
+func (s *IfStatement) End() token.Pos          { return s.To }
+func (s *ElseStatement) End() token.Pos        { return s.To }
+func (s *RepeatStatement) End() token.Pos      { return s.To }
+func (s *RepeatRangeStatement) End() token.Pos { return s.To }
+func (s *RepeatWhileStatement) End() token.Pos { return s.To }
+func (s *CaseStatement) End() token.Pos        { return s.To }
+func (s *CaseArm) End() token.Pos              { return s.To }
+func (s *ReturnStatement) End() token.Pos      { return s.To }
+func (s *AbortStatement) End() token.Pos       { return s.To }
+func (s *NextStatement) End() token.Pos        { return s.To }
+func (s *QuitStatement) End() token.Pos        { return s.To }
+
+func (*ExpressionStatement) statementNode()  {}
+func (*IfStatement) statementNode()          {}
+func (*ElseStatement) statementNode()        {}
+func (*RepeatStatement) statementNode()      {}
+func (*RepeatRangeStatement) statementNode() {}
+func (*RepeatWhileStatement) statementNode() {}
+func (*CaseStatement) statementNode()        {}
+func (*ReturnStatement) statementNode()      {}
+func (*AbortStatement) statementNode()       {}
+func (*NextStatement) statementNode()        {}
+func (*QuitStatement) statementNode()        {}
@@ -0,0 +1,259 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, in source order. It starts
+// by calling v.Visit(node); node must not be nil. If the visitor w
+// returned by v.Visit(node) is not nil, Walk is invoked recursively with
+// visitor w for each of the non-nil children of node, followed by a call
+// of w.Visit(nil). Comments are not visited; see CommentMap.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// Objects and blocks
+	case *Object:
+		for _, b := range n.Blocks {
+			Walk(v, b)
+		}
+
+	case *ConBlock:
+		walkDeclarations(v, n.Declarations)
+
+	case *DatBlock:
+		for _, e := range n.Entries {
+			Walk(v, e)
+		}
+
+	case *ObjBlock:
+		walkDeclarations(v, n.Declarations)
+
+	case *PriBlock:
+		walkMethod(v, n.Parameters, n.Result, n.Locals, n.Body)
+
+	case *PubBlock:
+		walkMethod(v, n.Parameters, n.Result, n.Locals, n.Body)
+
+	case *VarBlock:
+		walkDeclarations(v, n.Declarations)
+
+	case *BadBlock, *BadDeclaration, *BadStatement, *BadExpression, *ConStatement:
+		// nothing to do
+
+	// Declarations
+	case *ConstantDeclaration:
+		walkExpression(v, n.Value)
+
+	case *ObjectDeclaration:
+		walkExpression(v, n.Count)
+
+	case *VariableDeclaration:
+		walkExpression(v, n.Count)
+
+	case *LocalDeclaration:
+		walkExpression(v, n.Count)
+
+	// Expressions
+	case *Identifier, *NumberLiteral, *StringLiteral, *BooleanLiteral, *CurrentAddressExpression:
+		// nothing to do
+
+	case *UnaryExpression:
+		Walk(v, n.X)
+
+	case *PostfixExpression:
+		Walk(v, n.X)
+
+	case *BinaryExpression:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+
+	case *AssignmentExpression:
+		Walk(v, n.Target)
+		Walk(v, n.Value)
+
+	case *ParenExpression:
+		Walk(v, n.X)
+
+	case *MemoryExpression:
+		Walk(v, n.Base)
+		walkExpression(v, n.Index)
+
+	case *IndexExpression:
+		Walk(v, n.X)
+		Walk(v, n.Index)
+
+	case *SelectorExpression:
+		Walk(v, n.X)
+		Walk(v, n.Name)
+
+	case *CallExpression:
+		Walk(v, n.Function)
+		walkExpressions(v, n.Arguments)
+
+	case *ObjectConstantExpression:
+		Walk(v, n.Object)
+		Walk(v, n.Name)
+
+	case *StringExpression:
+		walkExpressions(v, n.Arguments)
+
+	case *ConstantExpression:
+		Walk(v, n.X)
+
+	case *LookupExpression:
+		Walk(v, n.Index)
+		walkExpressions(v, n.List)
+
+	case *RangeExpression:
+		Walk(v, n.Low)
+		Walk(v, n.High)
+
+	// Statements
+	case *ExpressionStatement:
+		Walk(v, n.X)
+
+	case *IfStatement:
+		Walk(v, n.Condition)
+		walkStatements(v, n.Body)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+
+	case *ElseStatement:
+		walkStatements(v, n.Body)
+
+	case *RepeatStatement:
+		walkExpression(v, n.Count)
+		walkStatements(v, n.Body)
+
+	case *RepeatRangeStatement:
+		Walk(v, n.Variable)
+		Walk(v, n.Start)
+		Walk(v, n.Stop)
+		walkExpression(v, n.Step)
+		walkStatements(v, n.Body)
+
+	case *RepeatWhileStatement:
+		if n.Post {
+			walkStatements(v, n.Body)
+			Walk(v, n.Condition)
+		} else {
+			Walk(v, n.Condition)
+			walkStatements(v, n.Body)
+		}
+
+	case *CaseStatement:
+		Walk(v, n.Value)
+		for _, arm := range n.Arms {
+			Walk(v, arm)
+		}
+
+	case *CaseArm:
+		walkExpressions(v, n.Matches)
+		walkStatements(v, n.Body)
+
+	case *ReturnStatement:
+		walkExpression(v, n.Value)
+
+	case *AbortStatement:
+		walkExpression(v, n.Value)
+
+	case *NextStatement, *QuitStatement:
+		// nothing to do
+
+	// DAT entries
+	case *Label, *FileDirective:
+		// nothing to do
+
+	case *DataDirective:
+		for _, value := range n.Values {
+			Walk(v, value)
+		}
+
+	case *DataValue:
+		Walk(v, n.Value)
+		walkExpression(v, n.Count)
+
+	case *Instruction:
+		walkExpression(v, n.Destination)
+		walkExpression(v, n.Source)
+
+	case *OrgDirective:
+		walkExpression(v, n.Address)
+
+	case *ResDirective:
+		walkExpression(v, n.Count)
+
+	case *FitDirective:
+		walkExpression(v, n.Address)
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkDeclarations(v Visitor, list []Declaration) {
+	for _, d := range list {
+		Walk(v, d)
+	}
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		Walk(v, s)
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+// walkExpression walks x if it is set.
+func walkExpression(v Visitor, x Expression) {
+	if x != nil {
+		Walk(v, x)
+	}
+}
+
+func walkMethod(v Visitor, parameters []*Identifier, result *Identifier, locals []*LocalDeclaration, body []Statement) {
+	for _, p := range parameters {
+		Walk(v, p)
+	}
+	if result != nil {
+		Walk(v, result)
+	}
+	for _, l := range locals {
+		Walk(v, l)
+	}
+	walkStatements(v, body)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,163 @@
+package ast_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/parser"
+)
+
+func parse(t *testing.T, src string) *ast.Object {
+	t.Helper()
+	object, err := parser.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return object
+}
+
+// Ensure Inspect visits nodes in source order.
+func TestInspect(t *testing.T) {
+	object := parse(t, "CON\n  a = 1\nPUB main(x) : r | i\n  repeat\n    r += x\n  while i < 2\n")
+
+	var got []string
+	ast.Inspect(object, func(n ast.Node) bool {
+		if n != nil {
+			got = append(got, reflect.TypeOf(n).Elem().Name())
+		}
+		return true
+	})
+
+	exp := []string{
+		"Object",
+		"ConBlock", "ConstantDeclaration", "NumberLiteral",
+		"PubBlock", "Identifier", "Identifier", "LocalDeclaration",
+		"RepeatWhileStatement",
+		"ExpressionStatement", "AssignmentExpression", "Identifier", "Identifier",
+		"BinaryExpression", "Identifier", "NumberLiteral",
+	}
+	if strings.Join(got, " ") != strings.Join(exp, " ") {
+		t.Errorf("mismatch:\n  exp=%v\n  got=%v", exp, got)
+	}
+}
+
+// Ensure Inspect stops descending when f returns false.
+func TestInspect_Prune(t *testing.T) {
+	object := parse(t, "PUB main\n  a := b + c\n  d\n")
+
+	var names []string
+	ast.Inspect(object, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BinaryExpression:
+			return false
+		case *ast.Identifier:
+			names = append(names, n.Name)
+		}
+		return true
+	})
+	if got := strings.Join(names, " "); got != "a d" {
+		t.Errorf("exp=%q got=%q", "a d", got)
+	}
+}
+
+type counter struct {
+	visits, ends int
+}
+
+func (c *counter) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		c.ends++
+	} else {
+		c.visits++
+	}
+	return c
+}
+
+// countNodes counts the nodes in the tree of v by reflection. Comments
+// are not walked and so not counted.
+func countNodes(v reflect.Value) int {
+	if _, ok := v.Interface().(*ast.CommentGroup); ok {
+		return 0
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return countNodes(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return 0
+		}
+		n := 0
+		if _, ok := v.Interface().(ast.Node); ok {
+			n = 1
+		}
+		return n + countNodes(v.Elem())
+	case reflect.Slice:
+		n := 0
+		for i := 0; i < v.Len(); i++ {
+			n += countNodes(v.Index(i))
+		}
+		return n
+	case reflect.Struct:
+		n := 0
+		for i := 0; i < v.NumField(); i++ {
+			n += countNodes(v.Field(i))
+		}
+		return n
+	}
+	return 0
+}
+
+// Ensure Walk reaches every node of the test objects.
+func TestWalk_Files(t *testing.T) {
+	err := filepath.Walk("../test", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".spin" {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		// Objects with errors are walked too.
+		object, _ := parser.NewFileParser(path, f).Parse()
+
+		c := &counter{}
+		ast.Walk(c, object)
+		if exp := countNodes(reflect.ValueOf(object)); c.visits != exp {
+			t.Errorf("%s: visited %d nodes, expected %d", path, c.visits, exp)
+		}
+		if c.ends != c.visits {
+			t.Errorf("%s: %d visits, but %d ends", path, c.visits, c.ends)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ExampleInspect() {
+	src := "PUB main\n  led.on(1)\n  wait(100)\n"
+	object, _ := parser.NewParser(strings.NewReader(src)).Parse()
+
+	ast.Inspect(object, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpression); ok {
+			fmt.Printf("call with %d arguments\n", len(call.Arguments))
+		}
+		return true
+	})
+
+	// Output:
+	// call with 1 arguments
+	// call with 1 arguments
+}
@@ -0,0 +1,144 @@
+package checker
+
+import (
+	"github.com/bweir/lame/ast"
+)
+
+// call resolves the call x in scope s, and checks that it calls a method
+// with the right number of arguments.
+func (c *checker) call(x *ast.CallExpression, s *Scope) {
+	switch f := x.Function.(type) {
+	case *ast.Identifier:
+		c.use(f, s)
+		if sym := c.info.Uses[f]; sym != nil {
+			switch sym.Kind {
+			case Method:
+				c.arity(x, f.Name, sym, len(x.Arguments))
+			case Builtin:
+			default:
+				c.errorf(f.Pos(), "not-method", "%s %s is not a method", sym.Kind, f.Name)
+			}
+		}
+	case *ast.SelectorExpression:
+		c.selector(f, s, len(x.Arguments))
+	default:
+		c.resolve(f, s)
+	}
+	for _, arg := range x.Arguments {
+		c.resolve(arg, s)
+	}
+}
+
+// selector resolves the method x of another object in scope s. args is
+// the number of arguments x is called with, or -1 if it is not called.
+func (c *checker) selector(x *ast.SelectorExpression, s *Scope, args int) {
+	d, indexed := c.object(x.X, s)
+	if d == nil {
+		return
+	}
+	name := d.Name + "." + x.Name.Name
+	if d.Count != nil && !indexed {
+		c.errorf(x.Pos(), "missing-index", "object array %s used without an index", d.Name)
+	}
+
+	info := c.imported(d)
+	if info == nil {
+		return
+	}
+	sym := info.Scope.Lookup(x.Name.Name)
+	if sym == nil || sym.Kind != Method {
+		c.errorf(x.Name.Pos(), "undefined", "undefined: %s", name)
+		return
+	}
+	c.info.Uses[x.Name] = sym
+	if _, ok := sym.Decl.(*ast.PriBlock); ok {
+		c.errorf(x.Name.Pos(), "private", "%s is private to %q", name, d.Path)
+		return
+	}
+	c.arity(x, name, sym, args)
+}
+
+// objectConstant resolves the constant x of another object in scope s.
+func (c *checker) objectConstant(x *ast.ObjectConstantExpression, s *Scope) {
+	c.use(x.Object, s)
+	sym := c.info.Uses[x.Object]
+	if sym == nil {
+		return
+	}
+	d, ok := sym.Decl.(*ast.ObjectDeclaration)
+	if !ok {
+		c.errorf(x.Object.Pos(), "not-object", "%s %s is not an object", sym.Kind, x.Object.Name)
+		return
+	}
+	info := c.imported(d)
+	if info == nil {
+		return
+	}
+	if sym := info.Scope.Lookup(x.Name.Name); sym != nil && sym.Kind == Constant {
+		c.info.Uses[x.Name] = sym
+		return
+	}
+	c.errorf(x.Name.Pos(), "undefined", "undefined: %s#%s", x.Object.Name, x.Name.Name)
+}
+
+// object resolves x, the object before a dot, in scope s. It returns the
+// declaration of the object, or nil if x does not name one, and whether
+// x indexes an object array.
+func (c *checker) object(x ast.Expression, s *Scope) (d *ast.ObjectDeclaration, indexed bool) {
+	if ix, ok := x.(*ast.IndexExpression); ok {
+		c.resolve(ix.Index, s)
+		x, indexed = ix.X, true
+	}
+	id, ok := x.(*ast.Identifier)
+	if !ok {
+		c.resolve(x, s)
+		return nil, false
+	}
+	c.use(id, s)
+	sym := c.info.Uses[id]
+	if sym == nil {
+		return nil, false
+	}
+	if d, ok = sym.Decl.(*ast.ObjectDeclaration); !ok {
+		c.errorf(id.Pos(), "not-object", "%s %s is not an object", sym.Kind, id.Name)
+		return nil, false
+	}
+	if d.Count == nil && indexed {
+		c.errorf(id.Pos(), "not-array", "object %s is not an array", id.Name)
+	}
+	return d, indexed
+}
+
+// imported returns the info of the object declared by d, or nil.
+func (c *checker) imported(d *ast.ObjectDeclaration) *Info {
+	if c.conf.Import == nil {
+		return nil
+	}
+	return c.conf.Import(d)
+}
+
+// arity checks that the method sym, called name at node, is called with
+// as many arguments as it has parameters. args is -1 for a method used
+// without parentheses, which is a call with no arguments.
+func (c *checker) arity(node ast.Node, name string, sym *Symbol, args int) {
+	want := len(parameters(sym))
+	switch {
+	case args < 0 && want > 0:
+		c.errorf(node.Pos(), "method-value", "%s takes %d arguments but is used without parentheses", name, want)
+	case args >= 0 && args < want:
+		c.errorf(node.Pos(), "arity", "not enough arguments in call to %s: have %d, want %d", name, args, want)
+	case args > want:
+		c.errorf(node.Pos(), "arity", "too many arguments in call to %s: have %d, want %d", name, args, want)
+	}
+}
+
+// parameters returns the parameters of the method sym.
+func parameters(sym *Symbol) []*ast.Identifier {
+	switch m := sym.Decl.(type) {
+	case *ast.PubBlock:
+		return m.Parameters
+	case *ast.PriBlock:
+		return m.Parameters
+	}
+	return nil
+}
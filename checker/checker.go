@@ -0,0 +1,272 @@
+// Package checker resolves the names of an object. It builds the scopes
+// of the object and its methods, links every identifier to the symbol it
+// refers to, and reports names that are undefined or declared twice.
+//
+// Spin names are case-insensitive: tile_w, TILE_W and Tile_W are the same
+// name. Scopes nest as follows:
+//
+//	Universe    registers, built-in constants, methods and variables
+//	object      CON, VAR, DAT and OBJ names, and method names
+//	method      parameters, result and locals
+//	label       local labels, such as :loop, after a global DAT label
+//
+// Names after a dot or # (gfx.Sprite, gfx#SX) are resolved in the object
+// named by gfx, if a Config imports it. Calls are checked for the number
+// of arguments, and calls to other objects for the visibility of the
+// method.
+package checker
+
+import (
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/diagnostic"
+	"github.com/bweir/lame/token"
+)
+
+// Info holds the names resolved by Check.
+type Info struct {
+	// Scope is the object scope, inside Universe.
+	Scope *Scope
+
+	// Scopes maps methods to the scope of their parameters, result and
+	// locals, and global DAT labels to the scope of the local labels that
+	// follow them.
+	Scopes map[ast.Node]*Scope
+
+	// Defs maps declaring nodes to the symbols they declare.
+	Defs map[ast.Node]*Symbol
+
+	// Uses maps identifiers to the symbols they refer to. Identifiers
+	// that could not be resolved are not in the map.
+	Uses map[*ast.Identifier]*Symbol
+}
+
+// A Config configures how objects are checked.
+type Config struct {
+	// Import returns the info of the object named by an OBJ declaration,
+	// or nil if it is not known. The names of objects that are not known
+	// are not checked. If Import is nil, no objects are known.
+	Import func(d *ast.ObjectDeclaration) *Info
+
+	// Shadow allows the parameters, results and locals of methods to
+	// shadow the names of the object, which Spin does not allow.
+	Shadow bool
+}
+
+// A checker holds the state of checking one object.
+type checker struct {
+	conf        *Config
+	file        *token.File
+	info        *Info
+	diagnostics diagnostic.List
+}
+
+// Check resolves the names of object, which was parsed from file, without
+// importing other objects. The info is complete even if there are errors.
+func Check(file *token.File, object *ast.Object) (*Info, diagnostic.List) {
+	return (&Config{}).Check(file, object)
+}
+
+// Check resolves the names of object, which was parsed from file, and the
+// names it uses in the objects that conf imports.
+func (conf *Config) Check(file *token.File, object *ast.Object) (*Info, diagnostic.List) {
+	c := &checker{
+		conf: conf,
+		file: file,
+		info: &Info{
+			Scope:  NewScope(Universe, object),
+			Scopes: make(map[ast.Node]*Scope),
+			Defs:   make(map[ast.Node]*Symbol),
+			Uses:   make(map[*ast.Identifier]*Symbol),
+		},
+	}
+
+	// Object names may be used before they are declared, so they are all
+	// declared before anything is resolved.
+	leading := NewScope(c.info.Scope, nil)
+	for _, b := range object.Blocks {
+		c.declare(b, leading)
+	}
+	labels := leading
+	for _, b := range object.Blocks {
+		labels = c.resolveBlock(b, labels)
+	}
+
+	c.diagnostics.Sort()
+	return c.info, c.diagnostics
+}
+
+// errorf reports an error at pos.
+func (c *checker) errorf(pos token.Pos, code, format string, args ...interface{}) {
+	c.diagnostics.Add(diagnostic.Error, c.file.Position(pos), code, format, args...)
+}
+
+// insert declares sym in s, reporting it if the name is taken in s or,
+// because Spin does not allow shadowing, in any scope around s.
+func (c *checker) insert(s *Scope, sym *Symbol) {
+	c.info.Defs[sym.Decl] = sym
+	prev := s.LookupParent(sym.Name)
+	if prev == nil || c.shadows(s, prev) {
+		s.Insert(sym)
+		return
+	}
+	if prev.Decl == nil {
+		c.errorf(sym.Decl.Pos(), "redeclared", "%s redeclares the built-in %s %s", sym.Name, prev.Kind, prev.Name)
+		return
+	}
+	c.errorf(sym.Decl.Pos(), "redeclared", "%s redeclared, first declared at line %d",
+		sym.Name, c.file.Position(prev.Decl.Pos()).Line)
+}
+
+// shadows reports whether a name declared in s may shadow prev, which
+// Config.Shadow allows for object names in method scopes.
+func (c *checker) shadows(s *Scope, prev *Symbol) bool {
+	if !c.conf.Shadow || s.Lookup(prev.Name) != nil || c.info.Scope.Lookup(prev.Name) != prev {
+		return false
+	}
+	switch s.Node.(type) {
+	case *ast.PubBlock, *ast.PriBlock:
+		return true
+	}
+	return false
+}
+
+// declare declares the object names of block b. Local labels before the
+// first global label are declared in leading.
+func (c *checker) declare(b ast.Block, leading *Scope) {
+	object := c.info.Scope
+	switch b := b.(type) {
+	case *ast.ConBlock:
+		for _, d := range b.Declarations {
+			if d, ok := d.(*ast.ConstantDeclaration); ok {
+				c.insert(object, &Symbol{Name: d.Name, Kind: Constant, Decl: d})
+			}
+		}
+
+	case *ast.VarBlock:
+		for _, d := range b.Declarations {
+			if d, ok := d.(*ast.VariableDeclaration); ok {
+				c.insert(object, &Symbol{Name: d.Name, Kind: Variable, Decl: d})
+			}
+		}
+
+	case *ast.ObjBlock:
+		for _, d := range b.Declarations {
+			if d, ok := d.(*ast.ObjectDeclaration); ok {
+				c.insert(object, &Symbol{Name: d.Name, Kind: Object, Decl: d})
+			}
+		}
+
+	case *ast.DatBlock:
+		labels := leading
+		for _, e := range b.Entries {
+			l, ok := e.(*ast.Label)
+			if !ok {
+				continue
+			}
+			if l.IsLocal() {
+				c.insert(labels, &Symbol{Name: l.Name, Kind: Label, Decl: l})
+				continue
+			}
+			c.insert(object, &Symbol{Name: l.Name, Kind: Label, Decl: l})
+			labels = NewScope(object, l)
+			c.info.Scopes[l] = labels
+		}
+
+	case *ast.PubBlock:
+		c.insert(object, &Symbol{Name: b.Name, Kind: Method, Decl: b})
+
+	case *ast.PriBlock:
+		c.insert(object, &Symbol{Name: b.Name, Kind: Method, Decl: b})
+	}
+}
+
+// resolveBlock resolves the names used in block b. DAT entries resolve
+// local labels in labels; the label scope in effect after b is returned.
+func (c *checker) resolveBlock(b ast.Block, labels *Scope) *Scope {
+	switch b := b.(type) {
+	case *ast.ConBlock, *ast.VarBlock, *ast.ObjBlock:
+		c.resolve(b, c.info.Scope)
+
+	case *ast.DatBlock:
+		for _, e := range b.Entries {
+			if l, ok := e.(*ast.Label); ok && !l.IsLocal() {
+				labels = c.info.Scopes[l]
+			}
+			c.resolve(e, labels)
+		}
+
+	case *ast.PubBlock:
+		c.resolveMethod(b, b.Parameters, b.Result, b.Locals, b.Body)
+
+	case *ast.PriBlock:
+		c.resolveMethod(b, b.Parameters, b.Result, b.Locals, b.Body)
+	}
+	return labels
+}
+
+// resolveMethod declares the parameters, result and locals of method m,
+// and resolves the names used in its body.
+func (c *checker) resolveMethod(m ast.Block, params []*ast.Identifier, result *ast.Identifier, locals []*ast.LocalDeclaration, body []ast.Statement) {
+	s := NewScope(c.info.Scope, m)
+	c.info.Scopes[m] = s
+
+	for _, p := range params {
+		c.insert(s, &Symbol{Name: p.Name, Kind: Parameter, Decl: p})
+	}
+	var sym *Symbol
+	if result != nil {
+		sym = &Symbol{Name: result.Name, Kind: Result, Decl: result}
+		c.insert(s, sym)
+	}
+	for _, l := range locals {
+		c.resolve(l, s)
+		c.insert(s, &Symbol{Name: l.Name, Kind: Local, Decl: l})
+	}
+
+	// Every method has a RESULT, which a named result is another name
+	// for.
+	if s.LookupParent("RESULT") == nil {
+		if sym == nil {
+			sym = &Symbol{Name: "result", Kind: Result, Decl: m}
+			s.Insert(sym)
+		} else {
+			s.symbols["RESULT"] = sym
+		}
+	}
+
+	for _, stmt := range body {
+		c.resolve(stmt, s)
+	}
+}
+
+// resolve resolves the identifiers used in node in scope s.
+func (c *checker) resolve(node ast.Node, s *Scope) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			c.use(n, s)
+			if sym := c.info.Uses[n]; sym != nil && sym.Kind == Method {
+				c.arity(n, n.Name, sym, -1)
+			}
+		case *ast.CallExpression:
+			c.call(n, s)
+			return false
+		case *ast.SelectorExpression:
+			c.selector(n, s, -1)
+			return false
+		case *ast.ObjectConstantExpression:
+			c.objectConstant(n, s)
+			return false
+		}
+		return true
+	})
+}
+
+// use resolves the identifier x in scope s.
+func (c *checker) use(x *ast.Identifier, s *Scope) {
+	if sym := s.LookupParent(x.Name); sym != nil {
+		c.info.Uses[x] = sym
+		return
+	}
+	c.errorf(x.Pos(), "undefined", "undefined: %s", x.Name)
+}
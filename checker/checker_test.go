@@ -0,0 +1,116 @@
+package checker_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/checker"
+	"github.com/bweir/lame/parser"
+)
+
+// check parses and checks src, failing the test on syntax errors.
+func check(t *testing.T, name, src string) (*ast.Object, *checker.Info, string) {
+	t.Helper()
+	p := parser.NewFileParser(name, strings.NewReader(src))
+	object, err := p.Parse()
+	if err != nil {
+		t.Fatalf("%q: %s", src, err)
+	}
+	info, diagnostics := checker.Check(p.File(), object)
+	return object, info, diagnostics.Error()
+}
+
+// Ensure undefined and duplicate names are reported.
+func TestCheck_Errors(t *testing.T) {
+	var tests = []struct {
+		src  string
+		diag string
+	}{
+		{src: "CON\nA = 1\nVAR\nlong b\nPUB main\n  b := A", diag: "no errors"},
+		{src: "PUB main\n  x := 1", diag: `main.spin:2:3: error: undefined: x [undefined]`},
+		{src: "CON\nA = B", diag: `main.spin:2:5: error: undefined: B [undefined]`},
+		{src: "CON\nA = 1\nDAT\na long 0", diag: `main.spin:4:1: error: a redeclared, first declared at line 2 [redeclared]`},
+		{src: "VAR\nlong x\nPUB x", diag: `main.spin:3:1: error: x redeclared, first declared at line 2 [redeclared]`},
+		{src: "PUB main(a, A)", diag: `main.spin:1:13: error: A redeclared, first declared at line 1 [redeclared]`},
+		{src: "VAR\nlong v\nPUB main | V", diag: `main.spin:3:12: error: V redeclared, first declared at line 2 [redeclared]`},
+		{src: "CON\nouta = 1", diag: `main.spin:2:1: error: outa redeclares the built-in register OUTA [redeclared]`},
+		{src: "DAT\na long 0\n:x long 0\n:x long 0", diag: `main.spin:4:1: error: :x redeclared, first declared at line 3 [redeclared]`},
+		{src: "DAT\na\n:x jmp #:x\nb jmp #:x", diag: `main.spin:4:8: error: undefined: :x [undefined]`},
+		{src: "PUB main\n  gfx.Sprite(1)\n  return gfx#SX", diag: "main.spin:2:3: error: undefined: gfx [undefined]\nmain.spin:3:10: error: undefined: gfx [undefined]"},
+		{src: "PUB main\n  f(1)\nPRI f(a, b)", diag: `main.spin:2:3: error: not enough arguments in call to f: have 1, want 2 [arity]`},
+		{src: "PUB main\n  f(1, 2)\nPRI f", diag: `main.spin:2:3: error: too many arguments in call to f: have 2, want 0 [arity]`},
+		{src: "PUB main : r\n  r := f\nPRI f(a)", diag: `main.spin:2:8: error: f takes 1 arguments but is used without parentheses [method-value]`},
+		{src: "CON\nA = 1\nPUB main\n  A(1)", diag: `main.spin:4:3: error: constant A is not a method [not-method]`},
+		{src: "VAR\nlong v\nPUB main\n  v.Sprite", diag: `main.spin:4:3: error: variable v is not an object [not-object]`},
+
+		// Names are case-insensitive, may be used before they are
+		// declared, and every method has a RESULT.
+		{src: "PUB main\n  RESULT := Tile_W + b\nDAT\ntile_w byte 0\nVAR\nbyte B", diag: "no errors"},
+		{src: "PUB main : r\n  r := result := cnt", diag: "no errors"},
+		{src: "DAT\na\n:x jmp #:x\nb\n:x jmp #:x", diag: "no errors"},
+		{src: "PUB main : r\n  r := f(1) + g + cogid\n  cognew(g, @r)\nPRI f(a)\nPRI g", diag: "no errors"},
+	}
+
+	for i, tt := range tests {
+		_, _, diag := check(t, "main.spin", tt.src)
+		if diag != tt.diag {
+			t.Errorf("%d. %q mismatch:\nexp=%s\ngot=%s", i, tt.src, tt.diag, diag)
+		}
+	}
+}
+
+// Ensure every identifier in LameText resolves to its declaration.
+func TestCheck_LameText(t *testing.T) {
+	src, err := os.ReadFile("../test/LameText.spin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object, info, diag := check(t, "LameText.spin", string(src))
+	if diag != "no errors" {
+		t.Fatal(diag)
+	}
+
+	uses := make(map[string]string)
+	ast.Inspect(object, func(n ast.Node) bool {
+		if x, ok := n.(*ast.Identifier); ok {
+			if sym := info.Uses[x]; sym != nil {
+				uses[x.Name] = sym.Kind.String()
+			}
+		}
+		return true
+	})
+	for name, kind := range map[string]string{
+		"tile_w":  "label",
+		"gfx":     "object",
+		"NL":      "constant",
+		"NEGX":    "constant",
+		"strsize": "built-in",
+		"Char":    "method",
+		"value":   "parameter",
+		"result":  "result",
+		"dx":      "local",
+	} {
+		if uses[name] != kind {
+			t.Errorf("%s: expected %s, got %q", name, kind, uses[name])
+		}
+	}
+
+	// Sprite in gfx.Sprite belongs to LameGFX, and is not resolved.
+	if _, ok := uses["Sprite"]; ok {
+		t.Error("unexpected use of Sprite")
+	}
+
+	box := info.Scope.Lookup("BOX")
+	if box == nil || box.Kind != checker.Method {
+		t.Fatalf("unexpected symbol for Box: %v", box)
+	}
+	var names []string
+	for _, sym := range info.Scopes[box.Decl].Symbols() {
+		names = append(names, sym.Name)
+	}
+	if got := strings.Join(names, " "); got != "stringvar x y w h c dx dy result" {
+		t.Errorf("unexpected Box scope: %s", got)
+	}
+}
@@ -0,0 +1,144 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bweir/lame/ast"
+)
+
+// A Kind is the kind of thing a symbol names.
+type Kind int
+
+const (
+	Constant  Kind = iota // CON constant
+	Variable              // VAR variable
+	Label                 // DAT label
+	Object                // OBJ object
+	Method                // PUB or PRI method
+	Parameter             // method parameter
+	Result                // method result
+	Local                 // method local
+	Register              // cog register, such as OUTA
+	Builtin               // built-in method or variable, such as WAITCNT
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Constant:
+		return "constant"
+	case Variable:
+		return "variable"
+	case Label:
+		return "label"
+	case Object:
+		return "object"
+	case Method:
+		return "method"
+	case Parameter:
+		return "parameter"
+	case Result:
+		return "result"
+	case Local:
+		return "local"
+	case Register:
+		return "register"
+	case Builtin:
+		return "built-in"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// A Symbol is a declared name. Decl is the node that declares it:
+//
+//	Constant   *ast.ConstantDeclaration
+//	Variable   *ast.VariableDeclaration
+//	Label      *ast.Label
+//	Object     *ast.ObjectDeclaration
+//	Method     *ast.PubBlock or *ast.PriBlock
+//	Parameter  *ast.Identifier
+//	Result     *ast.Identifier, or the method for the implicit RESULT
+//	Local      *ast.LocalDeclaration
+//
+// Built-in symbols have no Decl.
+type Symbol struct {
+	Name string
+	Kind Kind
+	Decl ast.Node
+}
+
+// A Scope maps names to symbols. Names are case-insensitive.
+type Scope struct {
+	Parent  *Scope
+	Node    ast.Node // the node that opens the scope, or nil
+	symbols map[string]*Symbol
+	order   []*Symbol
+}
+
+// NewScope returns an empty scope inside parent.
+func NewScope(parent *Scope, node ast.Node) *Scope {
+	return &Scope{Parent: parent, Node: node, symbols: make(map[string]*Symbol)}
+}
+
+// Lookup returns the symbol named name in s, or nil.
+func (s *Scope) Lookup(name string) *Symbol {
+	return s.symbols[strings.ToUpper(name)]
+}
+
+// LookupParent returns the symbol named name in s or the nearest scope
+// around it that declares it, or nil.
+func (s *Scope) LookupParent(name string) *Symbol {
+	for ; s != nil; s = s.Parent {
+		if sym := s.Lookup(name); sym != nil {
+			return sym
+		}
+	}
+	return nil
+}
+
+// Insert adds sym to s. If s already has a symbol of the same name, it
+// is returned and sym is not added.
+func (s *Scope) Insert(sym *Symbol) *Symbol {
+	key := strings.ToUpper(sym.Name)
+	if prev, ok := s.symbols[key]; ok {
+		return prev
+	}
+	s.symbols[key] = sym
+	s.order = append(s.order, sym)
+	return nil
+}
+
+// Symbols returns the symbols of s in the order they were inserted.
+func (s *Scope) Symbols() []*Symbol {
+	return append([]*Symbol(nil), s.order...)
+}
+
+// Universe is the scope of the names built into Spin. It is the parent
+// of every object scope, and must not be modified.
+var Universe = NewScope(nil, nil)
+
+func init() {
+	for _, name := range []string{
+		"PAR", "CNT", "INA", "INB", "OUTA", "OUTB", "DIRA", "DIRB",
+		"CTRA", "CTRB", "FRQA", "FRQB", "PHSA", "PHSB", "VCFG", "VSCL",
+	} {
+		Universe.Insert(&Symbol{Name: name, Kind: Register})
+	}
+	for _, name := range []string{
+		"POSX", "NEGX", "PI",
+		"RCFAST", "RCSLOW", "XINPUT", "XTAL1", "XTAL2", "XTAL3",
+		"PLL1X", "PLL2X", "PLL4X", "PLL8X", "PLL16X",
+	} {
+		Universe.Insert(&Symbol{Name: name, Kind: Constant})
+	}
+	for _, name := range []string{
+		"CLKFREQ", "CLKMODE", "CHIPVER", "CLKSET", "REBOOT",
+		"COGNEW", "COGINIT", "COGSTOP", "COGID",
+		"LOCKNEW", "LOCKRET", "LOCKSET", "LOCKCLR",
+		"WAITCNT", "WAITPEQ", "WAITPNE", "WAITVID",
+		"BYTEFILL", "WORDFILL", "LONGFILL", "BYTEMOVE", "WORDMOVE", "LONGMOVE",
+		"STRSIZE", "STRCOMP", "FLOAT", "ROUND", "TRUNC",
+	} {
+		Universe.Insert(&Symbol{Name: name, Kind: Builtin})
+	}
+}
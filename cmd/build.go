@@ -2,76 +2,133 @@ package cmd
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/bweir/lame/compiler"
+	"github.com/bweir/lame/parser"
 	"github.com/spf13/cobra"
+)
+
+var (
+	buildOutput string
 
-	"github.com/bweir/lame/lexer"
-	"github.com/bweir/lame/token"
+	compilerDialect     string
+	compilerTarget      string
+	compilerSearchPaths []string
 )
 
 func init() {
 	rootCmd.AddCommand(buildCmd)
+
+	buildCmd.Flags().StringVarP(&buildOutput, "output", "o", "", "write the image to this file")
+	addCompilerFlags(buildCmd)
+}
+
+// addCompilerFlags adds the flags read by compilerInput to cmd.
+func addCompilerFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&compilerDialect, "dialect", "spin", "source dialect: spin or lame")
+	cmd.Flags().StringVar(&compilerTarget, "target", "p1", "target machine: p1")
+	cmd.Flags().StringArrayVarP(&compilerSearchPaths, "include", "I", nil, "search this directory for objects")
 }
 
 var buildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Build a Spin object",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("building ...")
+	Long: `Build a Spin object.
 
-		text, err := ioutil.ReadFile(args[0])
+The objects named in OBJ blocks are found next to the object that names
+them, then in each -I directory, and then in each directory listed in the
+LAME_PATH environment variable.
+
+Build lists the methods that the program cannot reach from the first PUB
+method of the top object, or from the methods started with COGNEW or
+COGINIT or whose address is taken with @. It also lists unused data at
+the end of the DAT of an object, where no label in that object has its
+address taken, is indexed or is used by the DAT blocks. They are left out
+of the layout, and of the sizes reported by lame size.
+
+Only DAT blocks are compiled so far: objects with Spin methods, or that
+use other objects, are checked, but no image is written.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, opts := compilerInput(args[0])
+		r, err := compiler.Compile(name, opts)
 		if err != nil {
-			fmt.Println("File reading error", err)
-			return
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		printDiagnostics(r.Diagnostics)
+		if r.Diagnostics.HasErrors() {
+			os.Exit(1)
+		}
+		writeDead(os.Stdout, r.Dead)
 
-		scanner := lexer.NewScanner(strings.NewReader(string(text)))
-		var tok token.Token
-
-		indent := 0
-		for tok.Type != token.EOF {
-			tok = scanner.Scan()
-			if tok.Type == token.PRI || tok.Type == token.PUB {
-				fmt.Printf("\n")
-			} else if tok.Type == token.INDENT {
-				indent++
-			} else if tok.Type == token.DEDENT {
-				indent--
-			}
-			fmt.Printf(
-				"%s %12s(%3d, %3d): %s'%s'\n",
-				tok.State[0:2],
-				tok.Type,
-				tok.Line+1,
-				tok.Column+1,
-				strings.Repeat("  ", indent),
-				tok.Literal,
-			)
-			if tok.Type == token.ILLEGAL {
-				fmt.Printf("Invalid token '%s' encountered on line %d, col %d\n", tok.Literal, tok.Line, tok.Column)
+		if buildOutput != "" && r.Image != nil {
+			if err := os.WriteFile(buildOutput, r.Image, 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
 			}
-			// if tok == PUB {
-			// 	print_now = true
-			// }
-			// if tok == NEWLINE {
-			// 	print_now = false
-			// }
-			// if tok == DOC_COMMENT {
-			// 	fmt.Println()
-			// 	fmt.Println(lit)
-			// } else if print_now {
-			// 	if isBlock(tok) {
-			// 		fmt.Printf("### ")
-			// 	} else {
-			// 		fmt.Printf(lit)
-			// 	}
-			// }
 		}
-
 	},
 }
+
+// writeDead writes the methods that cannot be reached, and the unused
+// DAT, left out of the layout of the program.
+func writeDead(w io.Writer, d *compiler.Dead) {
+	for _, m := range d.Methods {
+		fmt.Fprintf(w, "%s: unreachable method %s\n", m.Source.File.Position(m.Method.Pos()), m.Name)
+	}
+	for _, data := range d.Data {
+		var names []string
+		for _, label := range data.Labels {
+			names = append(names, label.Name)
+		}
+		fmt.Fprintf(w, "%s: %d bytes of unused DAT: %s\n", data.Source.File.Position(data.Labels[0].Pos()), data.Size, strings.Join(names, ", "))
+	}
+}
+
+// compilerInput returns the name that the compiler reads the file named
+// by a command argument as, and the options set by the compiler flags.
+// The search paths are those of -I and then of LAME_PATH. Source read
+// from stdin is compiled as an in-memory file in the current directory.
+func compilerInput(name string) (string, *compiler.Options) {
+	opts := &compiler.Options{SearchPaths: compilerSearchPaths}
+	if path := os.Getenv("LAME_PATH"); path != "" {
+		opts.SearchPaths = append(opts.SearchPaths, filepath.SplitList(path)...)
+	}
+
+	switch compilerDialect {
+	case "spin":
+		opts.Dialect = compiler.Spin
+	case "lame":
+		opts.Dialect = compiler.Lame
+	default:
+		fmt.Fprintf(os.Stderr, "unknown dialect %q, expected spin or lame\n", compilerDialect)
+		os.Exit(1)
+	}
+	switch compilerTarget {
+	case "p1":
+		opts.Target = compiler.P1
+	default:
+		fmt.Fprintf(os.Stderr, "unknown target %q, expected p1\n", compilerTarget)
+		os.Exit(1)
+	}
+
+	if name == "-" {
+		_, text := readSource(name)
+		opts.FS = &parser.Overlay{FS: osFS{}, Files: map[string][]byte{stdinName: text}}
+		name = stdinName
+	}
+	return name, opts
+}
+
+// osFS is the file system of the operating system. Unlike os.DirFS, it
+// opens absolute paths and paths outside the current directory, such as
+// those of -I and LAME_PATH.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) { return os.Open(name) }
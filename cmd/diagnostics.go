@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/diagnostic"
+	"github.com/bweir/lame/parser"
+	"github.com/bweir/lame/token"
+)
+
+// parseFile parses the text of a file and prints its diagnostics. It
+// exits if the file has errors.
+func parseFile(filename string, text []byte) (*ast.Object, *token.File) {
+	p := parser.NewFileParser(filename, bytes.NewReader(text))
+	object, err := p.Parse()
+	printDiagnostics(p.Diagnostics())
+	if err != nil {
+		os.Exit(1)
+	}
+	return object, p.File()
+}
+
+// printDiagnostics prints diagnostics to stderr, one per line.
+func printDiagnostics(list diagnostic.List) {
+	for _, d := range list {
+		fmt.Fprintln(os.Stderr, d)
+	}
+}
@@ -2,59 +2,47 @@ package cmd
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"strings"
 
-	"github.com/bweir/lame/lexer"
-	"github.com/bweir/lame/token"
+	"github.com/bweir/lame/compiler"
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	rootCmd.AddCommand(docCmd)
+
+	addCompilerFlags(docCmd)
 }
 
 var docCmd = &cobra.Command{
 	Use:   "doc",
 	Short: "Render object documentation",
-	Args:  cobra.ExactArgs(1),
+	Long: `Render object documentation.
+
+The documentation of an object is followed by that of every object it
+uses, which are found as by lame build.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		text, err := ioutil.ReadFile(args[0])
+		name, opts := compilerInput(args[0])
+		tree, err := compiler.Load(name, opts)
 		if err != nil {
-			fmt.Println("File reading error", err)
-			return
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		printDiagnostics(tree.Diagnostics)
+		if tree.Diagnostics.HasErrors() {
+			os.Exit(1)
 		}
 
-		scanner := lexer.NewScanner(strings.NewReader(string(text)))
-		var tok token.Token
-
-		indent := 0
-		for tok.Type != token.EOF {
-			tok = scanner.Scan()
-			if tok.Type == token.PRI || tok.Type == token.PUB {
-				fmt.Printf("\n")
-			} else if tok.Type == token.INDENT {
-				indent++
-			} else if tok.Type == token.DEDENT {
-				indent--
-			}
-			fmt.Printf(
-				"%-3s %-16s (%4d, %4d): %s'%s'\n",
-				tok.State[0:3],
-				tok.Type,
-				tok.Line+1,
-				tok.Column+1,
-				strings.Repeat("  ", indent),
-				tok.Literal,
-			)
-			if tok.Type == token.ILLEGAL {
-				fmt.Printf("Invalid token '%s' (line %d, col %d)\n", tok.Literal, tok.Line, tok.Column)
-				os.Exit(1)
-			} else if tok.Type == token.UNEXPECTED_EOF {
-				fmt.Printf("Unexpected end-of-file (line %d, col %d)\n", tok.Line, tok.Column)
-				os.Exit(1)
+		// Sources lists the top object last.
+		for i := len(tree.Sources) - 1; i >= 0; i-- {
+			s := tree.Sources[i]
+			if i < len(tree.Sources)-1 {
+				fmt.Println()
 			}
+			fmt.Printf("# %s\n", s.Name)
+			tokens, _ := scanTokens(s.Text)
+			writeTokenTable(os.Stdout, tokens)
 		}
 	},
 }
@@ -2,14 +2,15 @@ package cmd
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
 
-	"github.com/bweir/lame/lexer"
-	"github.com/bweir/lame/parser"
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/compiler"
+	"github.com/bweir/lame/diagnostic"
 	"github.com/bweir/lame/token"
 )
 
@@ -17,6 +18,19 @@ func init() {
 	rootCmd.AddCommand(dumpCmd)
 	dumpCmd.AddCommand(tokensCmd)
 	dumpCmd.AddCommand(astCmd)
+	dumpCmd.AddCommand(constantsCmd)
+	dumpCmd.AddCommand(layoutCmd)
+	dumpCmd.AddCommand(stackCmd)
+
+	tokensCmd.Flags().StringVar(&tokensFormat, "format", "table", "output format: table, json, jsonl or csv")
+	tokensCmd.Flags().StringSliceVar(&tokensFilter.types, "type", nil, "only dump tokens of these types, e.g. IDENTIFIER,COMMENT")
+	tokensCmd.Flags().StringSliceVar(&tokensFilter.states, "state", nil, "only dump tokens scanned in these lexer states, e.g. FUNCTION")
+
+	astCmd.Flags().StringVar(&astFormat, "format", "tree", "output format: tree, json or sexpr")
+
+	addCompilerFlags(constantsCmd)
+	addCompilerFlags(layoutCmd)
+	addCompilerFlags(stackCmd)
 }
 
 var dumpCmd = &cobra.Command{
@@ -24,69 +38,279 @@ var dumpCmd = &cobra.Command{
 	Short: "Dump compiler outputs.",
 }
 
+var (
+	tokensFormat string
+	tokensFilter tokenFilter
+)
+
 var tokensCmd = &cobra.Command{
 	Use:   "tokens",
 	Short: "Dump tokens.",
-	Args:  cobra.ExactArgs(1),
+	Long: `Dump the tokens of a file.
+
+Formats:
+  table  human-readable table (default)
+  json   JSON array of tokens
+  jsonl  one JSON token per line
+  csv    CSV with a header row
+
+Each token in the json, jsonl and csv formats has its type, literal, raw
+source text, lexer state, and start and end positions. Positions have a
+zero-based byte offset and one-based line and column, as in the AST JSON
+format.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		text, err := ioutil.ReadFile(args[0])
-		if err != nil {
-			fmt.Println("File reading error", err)
-			return
-		}
-
-		scanner := lexer.NewScanner(strings.NewReader(string(text)))
-		var tok token.Token
-
-		indent := 0
-		for tok.Type != token.EOF {
-			tok = scanner.Scan()
-			if tok.Type == token.PRI || tok.Type == token.PUB {
-				fmt.Printf("\n")
-			} else if tok.Type == token.INDENT {
-				indent++
-			} else if tok.Type == token.DEDENT {
-				indent--
-			}
-			fmt.Printf(
-				"%-3s %-20s (%4d, %4d): %s'%s'\n",
-				tok.State[0:3],
-				tok.Type,
-				tok.Line+1,
-				tok.Column+1,
-				strings.Repeat("  ", indent),
-				tok.Literal,
-			)
+		if err := tokensFilter.validate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		filename, text := readSource(args[0])
+
+		var err error
+		tokens, file := scanTokens(text)
+		file.Name = filename
+
+		var diagnostics diagnostic.List
+		var selected []token.Token
+		var records []tokenRecord
+		for _, tok := range tokens {
 			if tok.Type == token.ILLEGAL {
-				fmt.Printf("Invalid token '%s' (line %d, col %d)\n", tok.Literal, tok.Line, tok.Column)
-				os.Exit(1)
+				diagnostics.Add(diagnostic.Error, file.Position(tok.Pos), "illegal-token", "invalid token %q", tok.Literal)
 			} else if tok.Type == token.UNEXPECTED_EOF {
-				fmt.Printf("Unexpected end-of-file (line %d, col %d)\n", tok.Line, tok.Column)
-				os.Exit(1)
+				diagnostics.Add(diagnostic.Error, file.Position(tok.Pos), "unexpected-eof", "unexpected end of file")
+			}
+			if tokensFilter.match(tok) {
+				selected = append(selected, tok)
+				records = append(records, newTokenRecord(file, text, tok))
 			}
 		}
+
+		switch tokensFormat {
+		case "table":
+			writeTokenTable(os.Stdout, selected)
+		case "json":
+			err = writeTokenJSON(os.Stdout, records)
+		case "jsonl":
+			err = writeTokenJSONL(os.Stdout, records)
+		case "csv":
+			err = writeTokenCSV(os.Stdout, records)
+		default:
+			err = fmt.Errorf("unknown format %q, expected table, json, jsonl or csv", tokensFormat)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		printDiagnostics(diagnostics)
+		if diagnostics.HasErrors() {
+			os.Exit(1)
+		}
 	},
 }
 
+var astFormat string
+
 var astCmd = &cobra.Command{
 	Use:   "ast",
-	Short: "Dump AST.",
-	Args:  cobra.ExactArgs(1),
+	Short: "Dump AST with source locations.",
+	Long: `Dump the AST of a file.
+
+Formats:
+  tree   indented tree of nodes with their source ranges (default)
+  json   JSON tree that can be decoded with ast.DecodeJSON, see docs/ast-json.md
+  sexpr  single-line S-expression`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filename, text := readSource(args[0])
+
+		object, file := parseFile(filename, text)
+
+		var err error
+		switch astFormat {
+		case "tree":
+			err = ast.Fprint(os.Stdout, file, object)
+		case "json":
+			err = ast.EncodeJSON(os.Stdout, file, object)
+		case "sexpr":
+			_, err = fmt.Println(ast.SExpr(object))
+		default:
+			err = fmt.Errorf("unknown format %q, expected tree, json or sexpr", astFormat)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var constantsCmd = &cobra.Command{
+	Use:   "constants",
+	Short: "Dump folded constant values.",
+	Long: `Dump the values known at compile time in an object and every object
+it uses: constants, constant(...) expressions, DAT operands, array counts,
+and the largest constant subexpressions of methods.
+
+Each line shows where the expression is, then the name of the constant
+or the source of the expression, and its value. Floats are shown as
+floats, and other values as signed 32-bit integers.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, opts := compilerInput(args[0])
+		r, err := compiler.Check(name, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		if r.Values != nil {
+			// Sources lists the top object last.
+			for i := len(r.Tree.Sources) - 1; i >= 0; i-- {
+				writeConstants(os.Stdout, r.Tree.Sources[i], r.Values)
+			}
+		}
+		printDiagnostics(r.Diagnostics)
+		if r.Diagnostics.HasErrors() {
+			os.Exit(1)
+		}
+	},
+}
+
+// writeConstants writes the values of the expressions of s, in source
+// order.
+func writeConstants(w io.Writer, s *compiler.Source, values map[ast.Expression]compiler.Value) {
+	ast.Inspect(s.Object, func(n ast.Node) bool {
+		if d, ok := n.(*ast.ConstantDeclaration); ok {
+			if v, ok := values[d.Value]; ok {
+				fmt.Fprintf(w, "%s: %s = %s\n", s.File.Position(d.Pos()), d.Name, v)
+			}
+			return false
+		}
+		if x, ok := n.(ast.Expression); ok {
+			if v, ok := values[x]; ok {
+				text := s.Text[s.File.Offset(x.Pos()):s.File.Offset(x.End())]
+				fmt.Fprintf(w, "%s: %s = %s\n", s.File.Position(x.Pos()), strings.Join(strings.Fields(string(text)), " "), v)
+				return false
+			}
+		}
+		return true
+	})
+}
+
+var layoutCmd = &cobra.Command{
+	Use:   "layout",
+	Short: "Dump the memory layout.",
+	Long: `Dump the hub memory layout of an object and every object it uses.
+
+Each object shows the address and size of its image, its DAT labels, and
+its VAR variables as offsets in the VAR of an instance. VAR variables are
+laid out as longs, then words, then bytes. Then come the addresses of the
+VAR of each object instance, and the totals of the program.
+
+Spin methods are not compiled yet, so their code is not counted.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		text, err := ioutil.ReadFile(args[0])
+		name, opts := compilerInput(args[0])
+		r, err := compiler.Check(name, opts)
 		if err != nil {
-			fmt.Println("File reading error", err)
-			return
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 
-		parser := parser.NewParser(strings.NewReader(string(text)))
-		object, err := parser.Parse()
+		if r.Layout != nil {
+			writeLayout(os.Stdout, r.Layout)
+		}
+		printDiagnostics(r.Diagnostics)
+		if r.Diagnostics.HasErrors() {
+			os.Exit(1)
+		}
+	},
+}
 
+// writeLayout writes the objects, instances and totals of l.
+func writeLayout(w io.Writer, l *compiler.Layout) {
+	for _, o := range l.Objects {
+		fmt.Fprintf(w, "# %s\n", o.Source.Name)
+		fmt.Fprintf(w, "$%04X  image  %d bytes: tables %d, DAT %d, code %d\n", o.Address, o.Size, o.Tables, o.DAT, o.Code)
+		for _, label := range o.Labels {
+			fmt.Fprintf(w, "$%04X  %s\n", o.Address+label.Offset, label.Decl.Name)
+		}
+		fmt.Fprintf(w, "+%-4d  VAR  %d bytes\n", 0, o.Var)
+		for _, v := range o.Variables {
+			fmt.Fprintf(w, "+%-4d  %s %s  %d bytes\n", v.Offset, strings.ToLower(string(v.Decl.Size)), v.Decl.Name, v.Size)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "# instances")
+	for _, in := range l.Instances {
+		fmt.Fprintf(w, "$%04X  %s  %d bytes\n", in.Var, in, in.Object.Var)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "# program")
+	fmt.Fprintf(w, "$0000  image  %d bytes\n", l.Image)
+	fmt.Fprintf(w, "$%04X  VAR    %d bytes\n", l.VarBase, l.Var)
+	fmt.Fprintf(w, "$%04X  stack  %d bytes free\n", l.StackBase, l.Free)
+}
+
+var stackCmd = &cobra.Command{
+	Use:   "stack",
+	Short: "Dump estimated stack use.",
+	Long: `Dump the estimated worst-case stack use of every method, and of every
+cog started with COGNEW or COGINIT.
+
+A method uses its call frame, of return information, RESULT, parameters
+and locals, and the most its body pushes, including the methods it calls.
+Methods that are recursive, or call recursive methods, have no bound and
+are marked with a +. Each cog shows the method it starts and the size of
+the VAR array given as its stack, if it is known.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, opts := compilerInput(args[0])
+		r, err := compiler.Check(name, opts)
 		if err != nil {
-			fmt.Println("Something happen", err)
-			return
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 
-		fmt.Printf("victory %q", object)
+		if r.Stack != nil {
+			writeStack(os.Stdout, r.Stack)
+		}
+		printDiagnostics(r.Diagnostics)
+		if r.Diagnostics.HasErrors() {
+			os.Exit(1)
+		}
 	},
 }
+
+// writeStack writes the stack use of the methods and cogs of st.
+func writeStack(w io.Writer, st *compiler.Stack) {
+	var source *compiler.Source
+	for _, m := range st.Methods {
+		if m.Source != source {
+			source = m.Source
+			fmt.Fprintf(w, "# %s\n", source.Name)
+		}
+		bound := ""
+		if !m.Bounded {
+			bound = "+"
+		}
+		fmt.Fprintf(w, "%s: %s  frame %d, stack %d%s bytes\n", source.File.Position(m.Method.Pos()), m.Name, m.Frame, m.Bytes, bound)
+	}
+
+	if len(st.Cogs) > 0 {
+		fmt.Fprintln(w, "# cogs")
+	}
+	for _, c := range st.Cogs {
+		bound := ""
+		if !c.Method.Bounded {
+			bound = "+"
+		}
+		stack := "unknown stack"
+		if c.Stack != nil {
+			stack = fmt.Sprintf("%s %d bytes", c.Stack.Name, c.Bytes)
+		}
+		fmt.Fprintf(w, "%s: %s  needs %d%s bytes, %s\n", c.Source.File.Position(c.Call.Pos()), c.Method.Name, c.Method.Bytes, bound, stack)
+	}
+}
@@ -2,17 +2,22 @@ package cmd
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"strings"
 
-	"github.com/bweir/lame/lexer"
-	"github.com/bweir/lame/token"
+	"github.com/bweir/lame/printer"
 	"github.com/spf13/cobra"
 )
 
+var (
+	fmtIndent   int
+	fmtKeywords string
+)
+
 func init() {
 	rootCmd.AddCommand(fmtCmd)
+
+	fmtCmd.Flags().IntVar(&fmtIndent, "indent", 4, "spaces per indentation level")
+	fmtCmd.Flags().StringVar(&fmtKeywords, "keywords", "spin", "keyword case: spin (upper-case blocks), upper or lower")
 }
 
 var fmtCmd = &cobra.Command{
@@ -20,53 +25,25 @@ var fmtCmd = &cobra.Command{
 	Short: "Format code",
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		text, err := ioutil.ReadFile(args[0])
-		if err != nil {
-			fmt.Println("File reading error", err)
-			return
+		config := &printer.Config{Indent: fmtIndent}
+		switch fmtKeywords {
+		case "spin":
+			config.Case = printer.SpinCase
+		case "upper":
+			config.Case = printer.UpperCase
+		case "lower":
+			config.Case = printer.LowerCase
+		default:
+			fmt.Fprintf(os.Stderr, "unknown keyword case %q, expected spin, upper or lower\n", fmtKeywords)
+			os.Exit(1)
 		}
 
-		scanner := lexer.NewScanner(strings.NewReader(string(text)))
-		var tok token.Token
-
-		indent := 0
-		lineStart := true
-		for tok.Type != token.EOF {
-			tok = scanner.Scan()
-			if tok.Type == token.ILLEGAL {
-				fmt.Printf("Invalid token '%s' (line %d, col %d)\n", tok.Literal, tok.Line, tok.Column)
-				os.Exit(1)
-			} else if tok.Type == token.UNEXPECTED_EOF {
-				fmt.Printf("Unexpected end-of-file (line %d, col %d)\n", tok.Line, tok.Column)
-				os.Exit(1)
-			}
-			if tok.Type == token.INDENT {
-				indent += 4
-			} else if tok.Type == token.DEDENT {
-				indent -= 4
-			} else if tok.Type == token.NEWLINE {
-				fmt.Printf("\n")
-				lineStart = true
-			} else {
-				if lineStart {
-					fmt.Printf(strings.Repeat(" ", indent))
-					lineStart = false
-				}
-				if tok.Type == token.DOC_COMMENT {
-					temp := strings.Split(tok.Literal, "\n")
-					for i := 0; i < len(temp); i++ {
-						fmt.Printf("'' %s\n", temp[i])
-					}
-				} else if tok.Type == token.STRING {
-					fmt.Printf("\"%s\"", tok.Literal)
-				} else if tok.Type == token.INDENT {
-					fmt.Printf("      ")
-				} else {
-					fmt.Printf("%s", tok.Literal)
-				}
+		filename, text := readSource(args[0])
+		object, file := parseFile(filename, text)
 
-			}
+		if err := config.Fprint(os.Stdout, file, object); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		fmt.Printf("\n")
 	},
 }
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/compiler"
+	"github.com/spf13/cobra"
+)
+
+var graphFormat string
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+	graphCmd.AddCommand(graphCallsCmd)
+	graphCmd.AddCommand(graphObjectsCmd)
+
+	graphCmd.PersistentFlags().StringVar(&graphFormat, "format", "dot", "output format: dot or json")
+	addCompilerFlags(graphCallsCmd)
+	addCompilerFlags(graphObjectsCmd)
+}
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print program graphs",
+	Long: `Print the graphs of an object and every object it uses, as Graphviz DOT
+or JSON. Render DOT with, for example, dot -Tsvg.`,
+}
+
+var graphCallsCmd = &cobra.Command{
+	Use:   "calls",
+	Short: "Print the call graph",
+	Long: `Print which method calls which, inside and across objects.
+
+Every method is a node with its file and line, grouped by object. A call
+is an edge from the caller to the callee, drawn once however many times
+it is made. Methods started in a new cog by COGNEW or COGINIT are
+highlighted, and the edge from the method that starts them is dashed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r := checkGraph(args[0])
+		writeGraph(newCallGraph(r.Calls))
+	},
+}
+
+var graphObjectsCmd = &cobra.Command{
+	Use:   "objects",
+	Short: "Print the object graph",
+	Long: `Print the OBJ tree of an object.
+
+Every object is a node with its file and the number of its instances in
+the program. An OBJ declaration is an edge from the object that declares
+it to the object it names, with the name, count and line of the
+declaration. An object used several times is drawn once.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r := checkGraph(args[0])
+		writeGraph(newObjectGraph(r))
+	},
+}
+
+// checkGraph checks the object at path, exiting if it has errors.
+func checkGraph(path string) *compiler.Result {
+	name, opts := compilerInput(path)
+	r, err := compiler.Check(name, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	printDiagnostics(r.Diagnostics)
+	if r.Diagnostics.HasErrors() {
+		os.Exit(1)
+	}
+	return r
+}
+
+// A graph is written as DOT or as JSON.
+type graph interface {
+	writeDOT(w io.Writer) error
+}
+
+func writeGraph(g graph) {
+	var err error
+	switch graphFormat {
+	case "dot":
+		err = g.writeDOT(os.Stdout)
+	case "json":
+		var b []byte
+		b, err = json.MarshalIndent(g, "", "  ")
+		if err == nil {
+			_, err = os.Stdout.Write(append(b, '\n'))
+		}
+	default:
+		err = fmt.Errorf("unknown format %q, expected dot or json", graphFormat)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type callGraph struct {
+	Methods []methodNode `json:"methods"`
+	Calls   []callEdge   `json:"calls"`
+}
+
+type methodNode struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Public bool   `json:"public"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Cog    bool   `json:"cog"` // started by COGNEW or COGINIT
+}
+
+type callEdge struct {
+	Caller int  `json:"caller"`
+	Callee int  `json:"callee"`
+	Cog    bool `json:"cog"` // starts the callee in a new cog
+}
+
+func newCallGraph(calls *compiler.CallGraph) *callGraph {
+	g := &callGraph{Methods: []methodNode{}, Calls: []callEdge{}}
+	ids := make(map[*compiler.CallNode]int)
+	for i, m := range calls.Methods {
+		_, public := m.Method.(*ast.PubBlock)
+		ids[m] = i
+		g.Methods = append(g.Methods, methodNode{
+			ID:     i,
+			Name:   m.Name,
+			Public: public,
+			File:   m.Source.Name,
+			Line:   m.Source.File.Position(m.Method.Pos()).Line,
+			Cog:    m.Cog,
+		})
+	}
+	for _, c := range calls.Calls {
+		g.Calls = append(g.Calls, callEdge{Caller: ids[c.Caller], Callee: ids[c.Callee], Cog: c.Cog})
+	}
+	return g
+}
+
+func (g *callGraph) writeDOT(w io.Writer) error {
+	fmt.Fprintln(w, "digraph calls {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for i, m := range g.Methods {
+		if i == 0 || m.File != g.Methods[i-1].File {
+			if i > 0 {
+				fmt.Fprintln(w, "\t}")
+			}
+			fmt.Fprintf(w, "\tsubgraph cluster_%d {\n\t\tlabel=%s;\n", i, strconv.Quote(m.File))
+		}
+		attrs := ""
+		if m.Cog {
+			attrs = ", style=\"bold,filled\", fillcolor=lightblue"
+		}
+		fmt.Fprintf(w, "\t\tm%d [label=%s%s];\n", m.ID, strconv.Quote(fmt.Sprintf("%s\n%s:%d", m.Name, m.File, m.Line)), attrs)
+	}
+	if len(g.Methods) > 0 {
+		fmt.Fprintln(w, "\t}")
+	}
+	for _, c := range g.Calls {
+		attrs := ""
+		if c.Cog {
+			attrs = " [style=dashed, label=cog]"
+		}
+		fmt.Fprintf(w, "\tm%d -> m%d%s;\n", c.Caller, c.Callee, attrs)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+type objectGraph struct {
+	Objects []objectNode `json:"objects"`
+	Uses    []objectEdge `json:"uses"`
+}
+
+type objectNode struct {
+	ID        int    `json:"id"`
+	File      string `json:"file"`
+	Instances int    `json:"instances"`
+}
+
+type objectEdge struct {
+	Parent int    `json:"parent"`
+	Child  int    `json:"child"`
+	Name   string `json:"name"`
+	Count  int    `json:"count"` // instances per instance of the parent
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+}
+
+func newObjectGraph(r *compiler.Result) *objectGraph {
+	instances := make(map[*compiler.Source]int)
+	for _, in := range r.Layout.Instances {
+		instances[in.Object.Source]++
+	}
+
+	// Layout lists the top object first, and the others as they are used.
+	g := &objectGraph{Objects: []objectNode{}, Uses: []objectEdge{}}
+	ids := make(map[*compiler.Source]int)
+	for i, o := range r.Layout.Objects {
+		ids[o.Source] = i
+		g.Objects = append(g.Objects, objectNode{ID: i, File: o.Source.Name, Instances: instances[o.Source]})
+	}
+	for _, o := range r.Layout.Objects {
+		s := o.Source
+		for _, b := range s.Object.Blocks {
+			b, ok := b.(*ast.ObjBlock)
+			if !ok {
+				continue
+			}
+			for _, d := range b.Declarations {
+				d, ok := d.(*ast.ObjectDeclaration)
+				if !ok || s.Children[d] == nil {
+					continue
+				}
+				count := 1
+				if d.Count != nil {
+					v, _ := r.Constant(d.Count)
+					count = int(v.Bits)
+				}
+				g.Uses = append(g.Uses, objectEdge{
+					Parent: ids[s],
+					Child:  ids[s.Children[d]],
+					Name:   d.Name,
+					Count:  count,
+					File:   s.Name,
+					Line:   s.File.Position(d.Pos()).Line,
+				})
+			}
+		}
+	}
+	return g
+}
+
+func (g *objectGraph) writeDOT(w io.Writer) error {
+	fmt.Fprintln(w, "digraph objects {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for _, o := range g.Objects {
+		plural := "s"
+		if o.Instances == 1 {
+			plural = ""
+		}
+		label := fmt.Sprintf("%s\n%d instance%s", o.File, o.Instances, plural)
+		fmt.Fprintf(w, "\to%d [label=%s];\n", o.ID, strconv.Quote(label))
+	}
+	for _, u := range g.Uses {
+		label := u.Name
+		if u.Count != 1 {
+			label = fmt.Sprintf("%s[%d]", u.Name, u.Count)
+		}
+		label = fmt.Sprintf("%s\n%s:%d", label, u.File, u.Line)
+		fmt.Fprintf(w, "\to%d -> o%d [label=%s];\n", u.Parent, u.Child, strconv.Quote(label))
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
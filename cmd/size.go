@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bweir/lame/compiler"
+	"github.com/bweir/lame/diagnostic"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sizeJSON     bool
+	sizeMaxBytes int
+)
+
+func init() {
+	rootCmd.AddCommand(sizeCmd)
+
+	sizeCmd.Flags().BoolVar(&sizeJSON, "json", false, "print the sizes as JSON")
+	sizeCmd.Flags().IntVar(&sizeMaxBytes, "max-bytes", 0, "fail if the program uses more than this many bytes of hub RAM")
+	addCompilerFlags(sizeCmd)
+}
+
+var sizeCmd = &cobra.Command{
+	Use:   "size",
+	Short: "Report memory usage",
+	Long: `Report the hub memory used by an object and every object it uses.
+
+For each object, size prints the bytes of its image, of which the method
+code and DAT, and of the VAR of all its instances. For the program, it
+prints the totals and the bytes left free for the stack. The program uses
+the bytes of its image and of VAR; with --max-bytes, the command fails if
+they are over the budget.
+
+As in lame build, the methods that the program cannot reach, and unused
+data at the end of DAT, are not counted.
+
+Spin methods are not compiled yet, so their code is counted as 0 bytes.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, opts := compilerInput(args[0])
+		r, err := compiler.Compile(name, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		// Size reports the layout, which is known even if no image is
+		// written yet.
+		var diagnostics diagnostic.List
+		for _, d := range r.Diagnostics {
+			if d.Code != "not-implemented" {
+				diagnostics = append(diagnostics, d)
+			}
+		}
+		printDiagnostics(diagnostics)
+		if r.Diagnostics.HasErrors() {
+			os.Exit(1)
+		}
+
+		report := newSizeReport(r.Layout)
+		if sizeJSON {
+			err = writeSizeJSON(os.Stdout, report)
+		} else {
+			err = writeSizeTable(os.Stdout, report)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		if sizeMaxBytes > 0 && report.Total.Used > sizeMaxBytes {
+			fmt.Fprintf(os.Stderr, "program uses %d bytes, over the budget of %d bytes\n", report.Total.Used, sizeMaxBytes)
+			os.Exit(1)
+		}
+	},
+}
+
+// A sizeReport is the memory used by each object and by the program.
+type sizeReport struct {
+	Objects []objectSize `json:"objects"`
+	Total   programSize  `json:"total"`
+}
+
+type objectSize struct {
+	Name      string `json:"name"`
+	Instances int    `json:"instances"`
+	Image     int    `json:"image"`
+	Code      int    `json:"code"`
+	DAT       int    `json:"dat"`
+	Var       int    `json:"var"` // of every instance
+}
+
+type programSize struct {
+	Image int `json:"image"`
+	Code  int `json:"code"`
+	DAT   int `json:"dat"`
+	Var   int `json:"var"`
+	Used  int `json:"used"` // image and VAR
+	Free  int `json:"free"` // left for the stack
+}
+
+func newSizeReport(l *compiler.Layout) *sizeReport {
+	instances := make(map[*compiler.ObjectLayout]int)
+	for _, in := range l.Instances {
+		instances[in.Object]++
+	}
+
+	report := &sizeReport{Objects: []objectSize{}}
+	for _, o := range l.Objects {
+		size := objectSize{
+			Name:      o.Source.Name,
+			Instances: instances[o],
+			Image:     o.Size,
+			Code:      o.Code,
+			DAT:       o.DAT,
+			Var:       o.Var * instances[o],
+		}
+		report.Objects = append(report.Objects, size)
+		report.Total.Code += size.Code
+		report.Total.DAT += size.DAT
+	}
+	report.Total.Image = l.Image
+	report.Total.Var = l.Var
+	report.Total.Used = l.Image + l.Var
+	report.Total.Free = l.Free
+	return report
+}
+
+func writeSizeTable(w io.Writer, report *sizeReport) error {
+	width := len("object")
+	for _, o := range report.Objects {
+		if len(o.Name) > width {
+			width = len(o.Name)
+		}
+	}
+	row := fmt.Sprintf("%%-%ds  %%9v  %%6v  %%6v  %%6v  %%6v\n", width)
+	fmt.Fprintf(w, row, "object", "instances", "image", "code", "DAT", "VAR")
+	for _, o := range report.Objects {
+		fmt.Fprintf(w, row, o.Name, o.Instances, o.Image, o.Code, o.DAT, o.Var)
+	}
+	t := report.Total
+	fmt.Fprintf(w, row, "total", "", t.Image, t.Code, t.DAT, t.Var)
+	_, err := fmt.Fprintf(w, "\n%d bytes used, %d bytes free for the stack\n", t.Used, t.Free)
+	return err
+}
+
+func writeSizeJSON(w io.Writer, report *sizeReport) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
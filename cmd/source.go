@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// stdinName is the file name that diagnostics show for source read from
+// stdin.
+const stdinName = "<stdin>"
+
+// readSource reads the file named by a command argument, or stdin if the
+// name is "-". It exits if the file cannot be read.
+func readSource(name string) (filename string, text []byte) {
+	var err error
+	if name == "-" {
+		filename = stdinName
+		text, err = io.ReadAll(os.Stdin)
+	} else {
+		filename = name
+		text, err = os.ReadFile(name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return filename, text
+}
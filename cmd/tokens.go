@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bweir/lame/lexer"
+	"github.com/bweir/lame/token"
+	"github.com/bweir/lame/token/state"
+)
+
+// A tokenRecord is a token in the machine-readable formats of
+// lame dump tokens. Positions are as in the AST JSON format.
+type tokenRecord struct {
+	Type    token.Type    `json:"type"`
+	Literal string        `json:"literal"`
+	Raw     string        `json:"raw"`
+	State   string        `json:"state"`
+	Start   tokenPosition `json:"start"`
+	End     tokenPosition `json:"end"`
+}
+
+type tokenPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// scanTokens scans text up to and including the EOF token.
+func scanTokens(text []byte) (tokens []token.Token, file *token.File) {
+	scanner := lexer.NewScanner(strings.NewReader(string(text)))
+	for {
+		tok := scanner.Scan()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens, scanner.File()
+		}
+	}
+}
+
+func newTokenRecord(file *token.File, text []byte, tok token.Token) tokenRecord {
+	position := func(p token.Pos) tokenPosition {
+		pos := file.Position(p)
+		return tokenPosition{Offset: file.Offset(p), Line: pos.Line, Column: pos.Column}
+	}
+	return tokenRecord{
+		Type:    tok.Type,
+		Literal: tok.Literal,
+		Raw:     string(text[file.Offset(tok.Pos):file.Offset(tok.End)]),
+		State:   string(tok.State),
+		Start:   position(tok.Pos),
+		End:     position(tok.End),
+	}
+}
+
+// writeTokenTable writes tokens as a table, indenting them by block.
+func writeTokenTable(w io.Writer, tokens []token.Token) {
+	indent := 0
+	for _, tok := range tokens {
+		if tok.Type == token.PRI || tok.Type == token.PUB {
+			fmt.Fprintf(w, "\n")
+		} else if tok.Type == token.INDENT {
+			indent++
+		} else if tok.Type == token.DEDENT {
+			indent--
+		}
+		fmt.Fprintf(w,
+			"%-3s %-20s (%4d, %4d): %s'%s'\n",
+			tok.State[0:3],
+			tok.Type,
+			tok.Line+1,
+			tok.Column+1,
+			strings.Repeat("  ", indent),
+			tok.Literal,
+		)
+	}
+}
+
+func writeTokenJSON(w io.Writer, records []tokenRecord) error {
+	if records == nil {
+		records = []tokenRecord{}
+	}
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func writeTokenJSONL(w io.Writer, records []tokenRecord) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTokenCSV(w io.Writer, records []tokenRecord) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"type", "literal", "raw", "state",
+		"start_offset", "start_line", "start_column",
+		"end_offset", "end_line", "end_column",
+	})
+	for _, r := range records {
+		cw.Write([]string{
+			string(r.Type), r.Literal, r.Raw, r.State,
+			strconv.Itoa(r.Start.Offset), strconv.Itoa(r.Start.Line), strconv.Itoa(r.Start.Column),
+			strconv.Itoa(r.End.Offset), strconv.Itoa(r.End.Line), strconv.Itoa(r.End.Column),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tokenFilter selects tokens by type and lexer state. An empty list
+// selects all.
+type tokenFilter struct {
+	types, states []string
+}
+
+// validate returns an error if a type or state of f is not a token type
+// or lexer state.
+func (f tokenFilter) validate() error {
+	types := make([]string, len(token.Types))
+	for i, t := range token.Types {
+		types[i] = string(t)
+	}
+	states := make([]string, len(state.States))
+	for i, s := range state.States {
+		states[i] = string(s)
+	}
+	for _, name := range f.types {
+		if !matchName(types, name) {
+			return fmt.Errorf("unknown token type %q", name)
+		}
+	}
+	for _, name := range f.states {
+		if !matchName(states, name) {
+			return fmt.Errorf("unknown lexer state %q, expected one of %s", name, strings.Join(states, ", "))
+		}
+	}
+	return nil
+}
+
+func (f tokenFilter) match(tok token.Token) bool {
+	return matchName(f.types, string(tok.Type)) && matchName(f.states, string(tok.State))
+}
+
+func matchName(names []string, name string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
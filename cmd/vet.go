@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/compiler"
+	"github.com/bweir/lame/vet"
+	"github.com/spf13/cobra"
+)
+
+// vetChecks holds the flags that switch the checks of vet on and off.
+var vetChecks = make(map[*vet.Check]*bool)
+
+func init() {
+	rootCmd.AddCommand(vetCmd)
+
+	for _, c := range vet.Checks {
+		vetChecks[c] = vetCmd.Flags().Bool(c.Name, true, "report "+c.Doc)
+		vetCmd.Long += fmt.Sprintf("\n  %-18s %s", c.Name, c.Doc)
+	}
+	addCompilerFlags(vetCmd)
+}
+
+var vetCmd = &cobra.Command{
+	Use:   "vet",
+	Short: "Report suspicious constructs",
+	Long: `Report suspicious constructs in an object and every object it uses.
+
+Vet reports code that compiles, but is unlikely to do what was meant. The
+object must compile without errors first. Every check is on by default,
+and is switched off with --name=false; its name is the code of the
+warnings it reports. The command fails if there are any warnings.
+
+Checks:`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, opts := compilerInput(args[0])
+		r, err := compiler.Check(name, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		printDiagnostics(r.Diagnostics)
+		if r.Diagnostics.HasErrors() {
+			os.Exit(1)
+		}
+
+		conf := &vet.Config{
+			Checks: []*vet.Check{},
+			Constant: func(x ast.Expression) (int32, bool) {
+				v, ok := r.Constant(x)
+				return v.Bits, ok
+			},
+		}
+		for _, c := range vet.Checks {
+			if *vetChecks[c] {
+				conf.Checks = append(conf.Checks, c)
+			}
+		}
+		var objects []*vet.Object
+		for _, s := range r.Tree.Sources {
+			objects = append(objects, &vet.Object{File: s.File, Object: s.Object, Info: r.Infos[s]})
+		}
+
+		diagnostics := conf.Vet(objects)
+		printDiagnostics(diagnostics)
+		if len(diagnostics) > 0 {
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,297 @@
+// Package compiler is the Go API of the Lame compiler. It parses, checks
+// and compiles objects without printing or exiting, so that it can be
+// embedded in other tools.
+//
+// The functions of this package do not share state, so they are safe to
+// call from many goroutines at once. Calls may share an Options if its FS
+// is safe for concurrent use.
+//
+// Check and Compile load the objects named in OBJ blocks with Load, and
+// check every object in the tree.
+//
+// The compiler is not finished: Compile assembles the DAT blocks of an
+// object, but does not yet generate bytecode for Spin methods.
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/checker"
+	"github.com/bweir/lame/diagnostic"
+	"github.com/bweir/lame/parser"
+	"github.com/bweir/lame/token"
+)
+
+// A Dialect is the language of the source.
+type Dialect int
+
+const (
+	Spin Dialect = iota // Parallax Spin
+	Lame                // Spin with the Lame extensions
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case Spin:
+		return "spin"
+	case Lame:
+		return "lame"
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+// A Target is the machine that code is generated for.
+type Target int
+
+const (
+	P1 Target = iota // Propeller 1
+)
+
+func (t Target) String() string {
+	switch t {
+	case P1:
+		return "p1"
+	}
+	return fmt.Sprintf("Target(%d)", int(t))
+}
+
+// Options control how objects are found and compiled. A nil *Options
+// uses the defaults.
+type Options struct {
+	// FS is the file system that source files are read from. Names are
+	// paths in FS. If FS is nil, names are paths of the operating system.
+	FS fs.FS
+
+	// Dialect is the language of the source. The default is Spin. Both
+	// dialects are parsed alike so far; they differ in how the names in
+	// OBJ blocks are found, as described by Load, and the Lame dialect
+	// lets method parameters, results and locals shadow object names.
+	Dialect Dialect
+
+	// SearchPaths lists the library roots: the directories searched for
+	// the objects named in OBJ blocks, after the directory of the object
+	// that names them or the project root.
+	SearchPaths []string
+
+	// Target is the machine to generate code for. The default is P1.
+	Target Target
+}
+
+// A Result is the outcome of parsing, checking or compiling an object.
+// Diagnostics holds every problem found, including warnings; Err
+// reports whether there were errors.
+type Result struct {
+	Name        string
+	File        *token.File
+	Object      *ast.Object
+	Diagnostics diagnostic.List
+
+	// Tree holds the object and every object it uses. It is set by Check
+	// and Compile.
+	Tree *Tree
+
+	// Infos holds the names resolved in every object in the tree. It is
+	// set by Check and Compile if the tree has no syntax errors.
+	Infos map[*Source]*checker.Info
+
+	// Values holds the values of the expressions of every object in the
+	// tree that are known at compile time: constants, constant(...)
+	// expressions, DAT operands and array counts, and the largest
+	// constant subexpressions of methods. Literals are left out, except
+	// as the values of constants. It is set by Check and Compile if there
+	// are no errors in names.
+	Values map[ast.Expression]Value
+
+	// Layout is the memory layout of the program, set by Check and
+	// Compile if there are no errors.
+	Layout *Layout
+
+	// Stack holds the estimated stack use of every method, and of every
+	// cog started with COGNEW or COGINIT. It is set with Layout.
+	Stack *Stack
+
+	// Calls is the graph of the calls between the methods of every
+	// object in the tree. It is set with Layout.
+	Calls *CallGraph
+
+	// Dead holds the methods and DAT that the program cannot reach. It is
+	// set by Compile if there are no errors, which leaves them out of
+	// Layout.
+	Dead *Dead
+
+	// Image is the compiled object, set by Compile if there are no
+	// errors. Only objects without Spin methods or OBJ blocks can be
+	// compiled so far; their image holds the DAT blocks.
+	Image []byte
+}
+
+// Err returns the errors of r as a diagnostic.List, or nil.
+func (r *Result) Err() error {
+	return r.Diagnostics.Err()
+}
+
+// Constant returns the value of x if it is known at compile time: the
+// values in r.Values, and those of literals.
+func (r *Result) Constant(x ast.Expression) (Value, bool) {
+	if v, ok := r.Values[x]; ok {
+		return v, true
+	}
+	return literal(x)
+}
+
+// Parse parses the object name. The error is not nil if the object could
+// not be read or the options are invalid; syntax errors are reported in
+// the result.
+func Parse(name string, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	text, err := opts.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.NewFileParser(name, bytes.NewReader(text))
+	object, _ := p.Parse()
+	return &Result{Name: name, File: p.File(), Object: object, Diagnostics: p.Diagnostics()}, nil
+}
+
+// Check loads the object name and the objects it uses, and checks them
+// for errors that do not stop them from parsing: names that are undefined
+// or declared twice, and then constants and DAT blocks that cannot be
+// evaluated.
+func Check(name string, opts *Options) (*Result, error) {
+	r, _, err := check(name, opts)
+	return r, err
+}
+
+// Compile checks the object name and, if there are no errors, compiles
+// it into an image.
+func Compile(name string, opts *Options) (*Result, error) {
+	r, u, err := check(name, opts)
+	if err != nil || r.Diagnostics.HasErrors() {
+		return r, err
+	}
+
+	r.Dead = dead(r.Tree, u.units, r.Calls)
+	r.Layout = layout(r.Tree, u.units, r.Dead)
+	for _, b := range r.Object.Blocks {
+		switch b.(type) {
+		case *ast.PubBlock, *ast.PriBlock:
+			r.Diagnostics.Add(diagnostic.Warning, r.File.Position(b.Pos()), "not-implemented",
+				"Spin methods are not compiled yet, so there is no image")
+			return r, nil
+		}
+	}
+	if len(r.Tree.Sources) > 1 {
+		for _, b := range r.Object.Blocks {
+			if b, ok := b.(*ast.ObjBlock); ok {
+				r.Diagnostics.Add(diagnostic.Warning, r.File.Position(b.Pos()), "not-implemented",
+					"objects that use other objects are not compiled yet, so there is no image")
+				return r, nil
+			}
+		}
+	}
+	r.Image = u.image
+	return r, nil
+}
+
+// check loads and checks the object name, and returns the unit used to
+// check it.
+func check(name string, opts *Options) (*Result, *unit, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	tree, err := Load(name, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	root := tree.Root
+	r := &Result{Name: root.Name, File: root.File, Object: root.Object, Diagnostics: tree.Diagnostics, Tree: tree}
+	if r.Diagnostics.HasErrors() {
+		return r, nil, nil
+	}
+
+	// The constants and DAT blocks are only evaluated if every name
+	// resolves, so that undefined names are reported once. Sources lists
+	// the objects an object uses before it, so their names are known when
+	// it is checked.
+	units := make(map[*Source]*unit)
+	values := make(map[ast.Expression]Value)
+	r.Infos = make(map[*Source]*checker.Info)
+	for _, s := range tree.Sources {
+		s := s
+		conf := &checker.Config{
+			Import: func(d *ast.ObjectDeclaration) *checker.Info {
+				if u := units[s.Children[d]]; u != nil {
+					return u.info
+				}
+				return nil
+			},
+			Shadow: opts.Dialect == Lame,
+		}
+		info, diagnostics := conf.Check(s.File, s.Object)
+		r.Infos[s] = info
+		r.Diagnostics = append(r.Diagnostics, diagnostics...)
+		units[s] = newUnit(s, info, opts, units, values)
+	}
+	if r.Diagnostics.HasErrors() {
+		return r, nil, nil
+	}
+
+	// The top object is checked last, so its unit is returned.
+	var u *unit
+	for _, s := range tree.Sources {
+		u = units[s]
+		u.assemble()
+		u.fold()
+		r.Diagnostics = append(r.Diagnostics, u.diagnostics...)
+	}
+	r.Values = values
+	if !r.Diagnostics.HasErrors() {
+		r.Layout = layout(tree, units, nil)
+		var diagnostics diagnostic.List
+		r.Stack, diagnostics = stack(tree, units, r.Layout)
+		r.Diagnostics = append(r.Diagnostics, diagnostics...)
+		r.Calls = callGraph(tree, units)
+	}
+	return r, u, nil
+}
+
+func (o *Options) validate() error {
+	if o.Dialect != Spin && o.Dialect != Lame {
+		return fmt.Errorf("unknown dialect %s", o.Dialect)
+	}
+	if o.Target != P1 {
+		return fmt.Errorf("unknown target %s", o.Target)
+	}
+	return nil
+}
+
+// readFile reads the named file from the file system of the options.
+func (o *Options) readFile(name string) ([]byte, error) {
+	if o.FS == nil {
+		return os.ReadFile(name)
+	}
+	return fs.ReadFile(o.FS, name)
+}
+
+// join joins the path name to the directory of the file from.
+func (o *Options) join(from, name string) string {
+	if o.FS == nil {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(filepath.Dir(from), name)
+	}
+	return path.Join(path.Dir(from), name)
+}
@@ -0,0 +1,186 @@
+package compiler_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bweir/lame/compiler"
+	"github.com/bweir/lame/diagnostic"
+)
+
+// Ensure DAT blocks compile to the expected image.
+func TestCompile(t *testing.T) {
+	var tests = []struct {
+		src   string
+		image []byte
+	}{
+		{src: "DAT\nbyte 1, 2\nword $0403\nlong 5", image: []byte{1, 2, 3, 4, 5, 0, 0, 0}},
+		{src: "DAT\nbyte 1\nlong 2", image: []byte{1, 0, 0, 0, 2, 0, 0, 0}},
+		{src: "DAT\nbyte 7[3], word $0102", image: []byte{7, 7, 7, 2, 1}},
+		{src: "DAT\nbyte \"ab\", 0", image: []byte{'a', 'b', 0}},
+		{src: "DAT\nword \"a\"[2]", image: []byte{'a', 0, 'a', 0}},
+		{src: "DAT\nfile \"data.bin\"", image: []byte{0xDE, 0xAD}},
+		{src: "CON\nB = A * 2\nA = 3\nDAT\nbyte A, B, constant(B + 1)", image: []byte{3, 6, 7}},
+		{src: "DAT\nlong -1 >> 28, 1 << 4, -16 ~> 2, 3 >< 2", image: []byte{15, 0, 0, 0, 16, 0, 0, 0, 0xFC, 0xFF, 0xFF, 0xFF, 3, 0, 0, 0}},
+		{src: "DAT\nbyte 7 // 4, -7 / 2, 5 #> 9, 5 <# 9, |< 3, >| 8", image: []byte{3, 0xFD, 9, 5, 8, 4}},
+		{src: "DAT\nbyte 1 == 1, 1 <> 1, true, not false", image: []byte{0xFF, 0, 0xFF, 0xFF}},
+		{src: "DAT\nlong 1.0", image: []byte{0, 0, 0x80, 0x3F}},
+
+		// Labels hold cog addresses, and @ takes the address in the image.
+		{src: "DAT\nbyte 1\na long a, b, @b\nb long 0", image: []byte{
+			1, 0, 0, 0,
+			1, 0, 0, 0, 4, 0, 0, 0, 16, 0, 0, 0,
+			0, 0, 0, 0,
+		}},
+
+		// Instructions, with local labels, registers, $ and call/ret.
+		{src: "DAT\n org 0\nentry mov outa, #0\n:loop jmp #:loop\n call #send\n jmp #$\nsend\nsend_ret ret\nx res 2\ny long y", image: []byte{
+			0x00, 0xE8, 0xFF, 0xA0,
+			0x01, 0x00, 0x7C, 0x5C,
+			0x04, 0x08, 0xFC, 0x5C,
+			0x03, 0x00, 0x7C, 0x5C,
+			0x00, 0x00, 0x7C, 0x5C,
+			0x07, 0x00, 0x00, 0x00,
+		}},
+		{src: "DAT\n org 16\na long a", image: []byte{16, 0, 0, 0}},
+	}
+
+	for i, tt := range tests {
+		opts := &compiler.Options{FS: fstest.MapFS{
+			"main.spin": {Data: []byte(tt.src)},
+			"data.bin":  {Data: []byte{0xDE, 0xAD}},
+		}}
+		r, err := compiler.Compile("main.spin", opts)
+		if err != nil {
+			t.Fatalf("%d. %s", i, err)
+		} else if err := r.Err(); err != nil {
+			t.Errorf("%d. %q: %s", i, tt.src, err)
+		} else if !bytes.Equal(r.Image, tt.image) {
+			t.Errorf("%d. %q image mismatch:\nexp=% X\ngot=% X", i, tt.src, tt.image, r.Image)
+		}
+	}
+}
+
+// Ensure problems are reported with stable codes.
+func TestCheck_Errors(t *testing.T) {
+	var tests = []struct {
+		src  string
+		diag string
+	}{
+		{src: "PUB main\n  x :=", diag: `main.spin:2:7: error: found "", expected expression [unexpected-token]`},
+		{src: "CON\nA = B\nB = A", diag: `main.spin:2:1: error: constant A refers to itself [constant-cycle]`},
+		{src: "CON\nA = 1 / (2 - 2)", diag: `main.spin:2:9: error: division by zero [division-by-zero]`},
+		{src: "CON\nA = $1_0000_0000", diag: `main.spin:2:5: error: number 1_0000_0000 does not fit in 32 bits [out-of-range]`},
+		{src: "DAT\nlong foo", diag: `main.spin:2:6: error: undefined: foo [undefined]`},
+		{src: "DAT\na jmp #:b", diag: `main.spin:2:8: error: undefined: :b [undefined]`},
+		{src: "DAT\nmov $200, #1", diag: `main.spin:2:5: error: destination $200 out of range $0..$1FF [out-of-range]`},
+		{src: "DAT\ncall #send\nsend ret", diag: `main.spin:2:7: error: undefined: send_ret [undefined]`},
+		{src: "DAT\nfile \"missing.bin\"", diag: `main.spin:2:1: error: open missing.bin: file does not exist [file-not-found]`},
+		{src: "DAT\norg 500\nfit 10", diag: `main.spin:2:5: error: org address $1F4 out of range $0..$1F0 [out-of-range]`},
+		{src: "DAT\nlong 0[20]\nfit 10", diag: `main.spin:3:1: error: cog code ends at $14, past the fit limit $A [fit]`},
+		{src: "DAT\nlong x\nx long 1 + \"ab\"", diag: `main.spin:3:12: error: string "ab" is not a single character [not-constant]`},
+		{src: "CON\nA = B\nB = C\nC = 1 / 0\nDAT\nlong A", diag: `main.spin:4:9: error: division by zero [division-by-zero]`},
+		{src: "PUB main\n  x := 1", diag: `main.spin:2:3: error: undefined: x [undefined]`},
+		{src: "DAT\na long 0\nA long 1", diag: `main.spin:3:1: error: A redeclared, first declared at line 2 [redeclared]`},
+	}
+
+	for i, tt := range tests {
+		opts := &compiler.Options{FS: fstest.MapFS{"main.spin": {Data: []byte(tt.src)}}}
+		r, err := compiler.Check("main.spin", opts)
+		if err != nil {
+			t.Fatalf("%d. %s", i, err)
+		}
+		if got := r.Diagnostics.Error(); got != tt.diag {
+			t.Errorf("%d. %q mismatch:\nexp=%s\ngot=%s", i, tt.src, tt.diag, got)
+		}
+	}
+}
+
+// Ensure objects with methods compile with a warning that Spin methods
+// are not compiled yet.
+func TestCompile_Methods(t *testing.T) {
+	r, err := compiler.Compile("../test/dat/pasm.spin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Diagnostics) != 1 || r.Diagnostics[0].Severity != diagnostic.Warning || r.Diagnostics[0].Code != "not-implemented" {
+		t.Fatalf("unexpected diagnostics: %v", r.Diagnostics)
+	}
+	if r.Image != nil || r.Err() != nil {
+		t.Fatalf("unexpected result: %d bytes, %v", len(r.Image), r.Err())
+	}
+}
+
+// Ensure objects that use other objects compile with a warning that they
+// are not compiled yet, rather than to the image of the top object alone.
+func TestCompile_Objects(t *testing.T) {
+	opts := &compiler.Options{FS: fstest.MapFS{
+		"main.spin":  {Data: []byte("OBJ\n  c : \"child\"\nDAT\nlong 1")},
+		"child.spin": {Data: []byte("DAT\nlong 2")},
+	}}
+	r, err := compiler.Compile("main.spin", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := r.Diagnostics.Error(), "main.spin:1:1: warning: objects that use other objects are not compiled yet, so there is no image [not-implemented]"; got != exp {
+		t.Fatalf("unexpected diagnostics:\nexp=%s\ngot=%s", exp, got)
+	}
+	if r.Image != nil || r.Err() != nil {
+		t.Fatalf("unexpected result: %d bytes, %v", len(r.Image), r.Err())
+	}
+}
+
+// Ensure bad options and unreadable files are returned as errors.
+func TestCompile_Options(t *testing.T) {
+	fsys := fstest.MapFS{"main.spin": {Data: []byte("DAT\nlong 1")}}
+	for _, tt := range []struct {
+		name string
+		opts *compiler.Options
+		err  string
+	}{
+		{name: "main.spin", opts: &compiler.Options{FS: fsys, Target: 2}, err: "unknown target Target(2)"},
+		{name: "main.spin", opts: &compiler.Options{FS: fsys, Dialect: 5}, err: "unknown dialect Dialect(5)"},
+		{name: "other.spin", opts: &compiler.Options{FS: fsys}, err: "open other.spin: file does not exist"},
+	} {
+		if _, err := compiler.Compile(tt.name, tt.opts); err == nil || err.Error() != tt.err {
+			t.Errorf("error mismatch: exp=%q got=%v", tt.err, err)
+		}
+	}
+}
+
+// Ensure many objects can be compiled at once.
+func TestCompile_Concurrent(t *testing.T) {
+	files := fstest.MapFS{}
+	for i := 0; i < 8; i++ {
+		var src strings.Builder
+		for j := 0; j <= i; j++ {
+			fmt.Fprintf(&src, "DAT\nx%d long x%d, @x%d\n", j, j, j)
+		}
+		files[string(rune('a'+i))+".spin"] = &fstest.MapFile{Data: []byte(src.String())}
+	}
+	opts := &compiler.Options{FS: files, Dialect: compiler.Lame}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := i % 8
+			r, err := compiler.Compile(string(rune('a'+n))+".spin", opts)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			} else if len(r.Image) != 8*(n+1) {
+				t.Errorf("%d: unexpected image size %d", n, len(r.Image))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
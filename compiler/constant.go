@@ -0,0 +1,676 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/checker"
+	"github.com/bweir/lame/diagnostic"
+	"github.com/bweir/lame/token"
+)
+
+// A unit holds the state of checking one object.
+type unit struct {
+	opts        *Options
+	source      *Source
+	name        string
+	file        *token.File
+	object      *ast.Object
+	info        *checker.Info
+	diagnostics diagnostic.List
+	constants   map[*ast.ConstantDeclaration]*constant
+	units       map[*Source]*unit        // every unit of the tree, for obj#NAME
+	values      map[ast.Expression]Value // shared by every unit of the tree
+	image       []byte                   // the DAT blocks
+	labels      map[*ast.Label]int       // DAT offsets of the labels
+}
+
+// A Value is the value of a constant expression. Floats are held as their
+// IEEE 754 single precision bits, which is how Spin stores them.
+type Value struct {
+	Bits  int32
+	Float bool
+}
+
+func (v Value) String() string {
+	if v.Float {
+		return strconv.FormatFloat(float64(v.float()), 'g', -1, 32)
+	}
+	return strconv.Itoa(int(v.Bits))
+}
+
+func (v Value) float() float32 { return math.Float32frombits(uint32(v.Bits)) }
+
+// kind returns the kind of v, for errors.
+func (v Value) kind() string {
+	if v.Float {
+		return "float"
+	}
+	return "integer"
+}
+
+func integer(i int32) Value { return Value{Bits: i} }
+func float(f float32) Value { return Value{Bits: int32(math.Float32bits(f)), Float: true} }
+func boolean(b bool) Value  { return integer(spinBool(b)) }
+
+// spinBool returns the Spin value of b: -1 for true and 0 for false.
+func spinBool(b bool) int32 {
+	if b {
+		return -1
+	}
+	return 0
+}
+
+// A constant is a CON declaration, evaluated when it is first used.
+type constant struct {
+	decl  *ast.ConstantDeclaration
+	value Value
+	state int // unevaluated, evaluating or evaluated
+	err   error
+}
+
+const (
+	unevaluated = iota
+	evaluating
+	evaluated
+)
+
+// builtins holds the values of the constants built into Spin.
+var builtins = map[string]Value{
+	"POSX":   integer(math.MaxInt32),
+	"NEGX":   integer(math.MinInt32),
+	"PI":     float(math.Pi),
+	"RCFAST": integer(0x001),
+	"RCSLOW": integer(0x002),
+	"XINPUT": integer(0x004),
+	"XTAL1":  integer(0x008),
+	"XTAL2":  integer(0x010),
+	"XTAL3":  integer(0x020),
+	"PLL1X":  integer(0x040),
+	"PLL2X":  integer(0x080),
+	"PLL4X":  integer(0x100),
+	"PLL8X":  integer(0x200),
+	"PLL16X": integer(0x400),
+}
+
+func newUnit(s *Source, info *checker.Info, opts *Options, units map[*Source]*unit, values map[ast.Expression]Value) *unit {
+	u := &unit{
+		opts:      opts,
+		source:    s,
+		name:      s.Name,
+		file:      s.File,
+		object:    s.Object,
+		info:      info,
+		constants: make(map[*ast.ConstantDeclaration]*constant),
+		units:     units,
+		values:    values,
+	}
+	for _, b := range s.Object.Blocks {
+		if b, ok := b.(*ast.ConBlock); ok {
+			for _, d := range b.Declarations {
+				if d, ok := d.(*ast.ConstantDeclaration); ok {
+					u.constants[d] = &constant{decl: d}
+				}
+			}
+		}
+	}
+	return u
+}
+
+// errorf returns an error diagnostic at pos.
+func (u *unit) errorf(pos token.Pos, code, format string, args ...interface{}) error {
+	return diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Pos:      u.file.Position(pos),
+		Message:  fmt.Sprintf(format, args...),
+		Code:     code,
+	}
+}
+
+// report adds err to the diagnostics, unless it has been reported.
+func (u *unit) report(err error) {
+	if err == errReported {
+		return
+	}
+	if d, ok := err.(diagnostic.Diagnostic); ok {
+		u.diagnostics = append(u.diagnostics, d)
+		return
+	}
+	u.diagnostics.Add(diagnostic.Error, u.file.Position(token.NoPos), "error", "%s", err)
+}
+
+// checkConstants evaluates every constant, reporting the errors.
+func (u *unit) checkConstants() {
+	for _, b := range u.object.Blocks {
+		if b, ok := b.(*ast.ConBlock); ok {
+			for _, d := range b.Declarations {
+				if d, ok := d.(*ast.ConstantDeclaration); ok {
+					if _, err := u.constant(u.constants[d]); err != nil {
+						u.report(err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// constant returns the value of c. The error of a constant is returned
+// once, to the first use, so that it is reported once.
+func (u *unit) constant(c *constant) (Value, error) {
+	switch c.state {
+	case evaluating:
+		return Value{}, u.errorf(c.decl.Pos(), "constant-cycle", "constant %s refers to itself", c.decl.Name)
+	case evaluated:
+		if c.err != nil {
+			return Value{}, errReported
+		}
+		return c.value, nil
+	}
+	c.state = evaluating
+	c.value, c.err = u.eval(c.decl.Value, env{})
+	if c.err == nil {
+		u.values[c.decl.Value] = c.value
+	}
+	c.state = evaluated
+	return c.value, c.err
+}
+
+// record records the value of x, unless x is a literal, whose value is
+// plain to see.
+func (u *unit) record(x ast.Expression, v Value) {
+	switch x.(type) {
+	case *ast.NumberLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		return
+	}
+	u.values[x] = v
+}
+
+// errReported is returned for expressions whose error has already been
+// reported, so that it is not reported again.
+var errReported = diagnostic.Diagnostic{Code: "reported"}
+
+// An env is where an expression is evaluated.
+type env struct {
+	// a is the assembler in DAT blocks, which resolves labels, registers
+	// and $; otherwise a is nil.
+	a *assembler
+
+	// method is set in method bodies, where Spin has no float maths:
+	// float literals are plain integers, except inside constant().
+	method bool
+}
+
+// eval evaluates the constant expression x using 32-bit Spin arithmetic.
+func (u *unit) eval(x ast.Expression, e env) (Value, error) {
+	switch x := x.(type) {
+	case *ast.NumberLiteral:
+		v, err := u.number(x)
+		v.Float = v.Float && !e.method
+		return v, err
+
+	case *ast.BooleanLiteral:
+		return boolean(x.Value), nil
+
+	case *ast.StringLiteral:
+		if len(x.Value) != 1 {
+			return Value{}, u.errorf(x.Pos(), "not-constant", "string %q is not a single character", x.Value)
+		}
+		return integer(int32(x.Value[0])), nil
+
+	case *ast.ParenExpression:
+		return u.eval(x.X, e)
+
+	case *ast.ConstantExpression:
+		v, err := u.eval(x.X, env{a: e.a})
+		if err == nil {
+			u.record(x, v)
+		}
+		return v, err
+
+	case *ast.Identifier:
+		if e.a != nil {
+			if v, ok, err := e.a.resolve(x); ok || err != nil {
+				return integer(v), err
+			}
+		}
+		return u.identifier(x)
+
+	case *ast.ObjectConstantExpression:
+		return u.objectConstant(x)
+
+	case *ast.CurrentAddressExpression:
+		if e.a == nil {
+			return Value{}, u.errorf(x.Pos(), "not-constant", "$ is only defined in DAT blocks")
+		}
+		return integer(int32(e.a.cog())), nil
+
+	case *ast.CallExpression:
+		return u.call(x, e)
+
+	case *ast.UnaryExpression:
+		if x.Operator == token.AT && e.a != nil {
+			if id, ok := x.X.(*ast.Identifier); ok {
+				if l, ok := e.a.label(id); ok {
+					return integer(int32(l.hub)), nil
+				}
+			}
+		}
+		v, err := u.eval(x.X, e)
+		if err != nil {
+			return Value{}, err
+		}
+		if v.Float {
+			return u.unaryFloat(x, v.float())
+		}
+		return u.unary(x, v.Bits)
+
+	case *ast.BinaryExpression:
+		v, err := u.eval(x.X, e)
+		if err != nil {
+			return Value{}, err
+		}
+		w, err := u.eval(x.Y, e)
+		if err != nil {
+			return Value{}, err
+		}
+		if v.Float != w.Float {
+			return Value{}, u.errorf(x.Pos(), "float", "cannot mix %s and %s operands", v.kind(), w.kind())
+		}
+		if v.Float {
+			return u.binaryFloat(x, v.float(), w.float())
+		}
+		return u.binary(x, v.Bits, w.Bits)
+	}
+	return Value{}, u.errorf(x.Pos(), "not-constant", "expression is not constant")
+}
+
+// identifier returns the value of the constant named by x.
+func (u *unit) identifier(x *ast.Identifier) (Value, error) {
+	sym := u.info.Uses[x]
+	if sym == nil {
+		return Value{}, u.errorf(x.Pos(), "undefined", "undefined: %s", x.Name)
+	}
+	if sym.Kind == checker.Constant {
+		if d, ok := sym.Decl.(*ast.ConstantDeclaration); ok {
+			return u.constant(u.constants[d])
+		}
+		if v, ok := builtins[strings.ToUpper(sym.Name)]; ok {
+			return v, nil
+		}
+	}
+	return Value{}, u.errorf(x.Pos(), "not-constant", "%s %s is not constant", sym.Kind, x.Name)
+}
+
+// objectConstant returns the value of obj#NAME, a constant of another
+// object.
+func (u *unit) objectConstant(x *ast.ObjectConstantExpression) (Value, error) {
+	var d *ast.ObjectDeclaration
+	if sym := u.info.Uses[x.Object]; sym != nil {
+		d, _ = sym.Decl.(*ast.ObjectDeclaration)
+	}
+	if d == nil {
+		return Value{}, u.errorf(x.Object.Pos(), "not-object", "%s is not an object", x.Object.Name)
+	}
+	child := u.units[u.source.Children[d]]
+	if child == nil {
+		return Value{}, errReported
+	}
+	if sym := child.info.Scope.Lookup(x.Name.Name); sym != nil {
+		if d, ok := sym.Decl.(*ast.ConstantDeclaration); ok {
+			return child.constant(child.constants[d])
+		}
+	}
+	return Value{}, u.errorf(x.Name.Pos(), "undefined", "undefined: %s#%s", x.Object.Name, x.Name.Name)
+}
+
+// call returns the value of the float conversions FLOAT, ROUND and TRUNC,
+// which are evaluated at compile time.
+func (u *unit) call(x *ast.CallExpression, e env) (Value, error) {
+	id, ok := x.Function.(*ast.Identifier)
+	if !ok || u.info.Uses[id] == nil || u.info.Uses[id].Decl != nil || len(x.Arguments) != 1 {
+		return Value{}, u.errorf(x.Pos(), "not-constant", "expression is not constant")
+	}
+	name := strings.ToUpper(id.Name)
+	if name != "FLOAT" && name != "ROUND" && name != "TRUNC" {
+		return Value{}, u.errorf(x.Pos(), "not-constant", "expression is not constant")
+	}
+
+	v, err := u.eval(x.Arguments[0], env{a: e.a})
+	if err != nil {
+		return Value{}, err
+	}
+	if want := name != "FLOAT"; v.Float != want {
+		return Value{}, u.errorf(x.Arguments[0].Pos(), "float", "%s of %s operand", id.Name, v.kind())
+	}
+	var f float64
+	switch name {
+	case "FLOAT":
+		return float(float32(v.Bits)), nil
+	case "ROUND":
+		f = math.Round(float64(v.float()))
+	case "TRUNC":
+		f = math.Trunc(float64(v.float()))
+	}
+	if f < math.MinInt32 || f > math.MaxInt32 || math.IsNaN(f) {
+		return Value{}, u.errorf(x.Pos(), "out-of-range", "%s of %s does not fit in 32 bits", id.Name, v)
+	}
+	return integer(int32(f)), nil
+}
+
+// number returns the value of a number literal.
+func (u *unit) number(x *ast.NumberLiteral) (Value, error) {
+	v, ok := number(x)
+	if !ok {
+		if x.Kind == token.FLOAT_NUMBER {
+			return Value{}, u.errorf(x.Pos(), "out-of-range", "float %s out of range", x.Value)
+		}
+		return Value{}, u.errorf(x.Pos(), "out-of-range", "number %s does not fit in 32 bits", x.Value)
+	}
+	return v, nil
+}
+
+// number returns the value of a number literal, and whether it is in
+// range.
+func number(x *ast.NumberLiteral) (Value, bool) {
+	text := strings.ReplaceAll(x.Value, "_", "")
+	base := 10
+	switch x.Kind {
+	case token.FLOAT_NUMBER:
+		f, err := strconv.ParseFloat(text, 32)
+		return float(float32(f)), err == nil
+	case token.HEXADECIMAL_NUMBER:
+		base = 16
+	case token.BINARY_NUMBER:
+		base = 2
+	case token.QUATERNARY_NUMBER:
+		base = 4
+	}
+	v, err := strconv.ParseUint(text, base, 32)
+	return integer(int32(v)), err == nil
+}
+
+// literal returns the value of x if it is a literal with a value:
+// a number, TRUE or FALSE, or a single character.
+func literal(x ast.Expression) (Value, bool) {
+	switch x := x.(type) {
+	case *ast.NumberLiteral:
+		return number(x)
+	case *ast.BooleanLiteral:
+		return boolean(x.Value), true
+	case *ast.StringLiteral:
+		if len(x.Value) == 1 {
+			return integer(int32(x.Value[0])), true
+		}
+	}
+	return Value{}, false
+}
+
+func (u *unit) unary(x *ast.UnaryExpression, v int32) (Value, error) {
+	switch x.Operator {
+	case token.SUBTRACT:
+		return integer(-v), nil
+	case token.BITWISE_NOT:
+		return integer(^v), nil
+	case token.NOT:
+		return boolean(v == 0), nil
+	case token.ABSOLUTE:
+		if v < 0 {
+			return integer(-v), nil
+		}
+		return integer(v), nil
+	case token.DECODE:
+		return integer(int32(1) << (uint32(v) & 31)), nil
+	case token.ENCODE:
+		return integer(int32(bits.Len32(uint32(v)))), nil
+	case token.SQUARE_ROOT:
+		return integer(int32(math.Sqrt(float64(uint32(v))))), nil
+	case token.BITWISE_SIGN_EXTEND_7:
+		return integer(int32(int8(v))), nil
+	case token.BITWISE_SIGN_EXTEND_15:
+		return integer(int32(int16(v))), nil
+	}
+	return Value{}, u.errorf(x.Pos(), "not-constant", "expression is not constant")
+}
+
+// unaryFloat evaluates the unary operators that Spin defines on floats.
+func (u *unit) unaryFloat(x *ast.UnaryExpression, v float32) (Value, error) {
+	switch x.Operator {
+	case token.SUBTRACT:
+		return float(-v), nil
+	case token.ABSOLUTE:
+		return float(float32(math.Abs(float64(v)))), nil
+	case token.SQUARE_ROOT:
+		if v < 0 {
+			return Value{}, u.errorf(x.Pos(), "out-of-range", "square root of negative float %g", v)
+		}
+		return float(float32(math.Sqrt(float64(v)))), nil
+	}
+	return Value{}, u.errorf(x.Pos(), "float", "operator %q is not defined on floats", u.text(x.Pos(), x.X.Pos()))
+}
+
+func (u *unit) binary(x *ast.BinaryExpression, v, w int32) (Value, error) {
+	n := uint32(w) & 31
+	switch x.Operator {
+	case token.ADD:
+		return integer(v + w), nil
+	case token.SUBTRACT:
+		return integer(v - w), nil
+	case token.MULTIPLY:
+		return integer(v * w), nil
+	case token.MULTIPLY_HIGH:
+		return integer(int32(int64(v) * int64(w) >> 32)), nil
+	case token.DIVIDE, token.MODULO:
+		if w == 0 {
+			return Value{}, u.errorf(x.Y.Pos(), "division-by-zero", "division by zero")
+		}
+		if x.Operator == token.DIVIDE {
+			return integer(v / w), nil
+		}
+		return integer(v % w), nil
+	case token.LIMIT_MINIMUM:
+		if v < w {
+			return integer(w), nil
+		}
+		return integer(v), nil
+	case token.LIMIT_MAXIMUM:
+		if v > w {
+			return integer(w), nil
+		}
+		return integer(v), nil
+	case token.BITWISE_SHIFT_LEFT:
+		return integer(v << n), nil
+	case token.BITWISE_SHIFT_RIGHT:
+		return integer(int32(uint32(v) >> n)), nil
+	case token.BITWISE_SIGNED_SHIFT_RIGHT:
+		return integer(v >> n), nil
+	case token.BITWISE_ROTATE_LEFT:
+		return integer(int32(bits.RotateLeft32(uint32(v), int(n)))), nil
+	case token.BITWISE_ROTATE_RIGHT:
+		return integer(int32(bits.RotateLeft32(uint32(v), -int(n)))), nil
+	case token.BITWISE_REVERSE:
+		if n == 0 {
+			return integer(0), nil
+		}
+		return integer(int32(bits.Reverse32(uint32(v)) >> (32 - n))), nil
+	case token.BITWISE_AND:
+		return integer(v & w), nil
+	case token.BITWISE_OR:
+		return integer(v | w), nil
+	case token.BITWISE_XOR:
+		return integer(v ^ w), nil
+	case token.AND:
+		return boolean(v != 0 && w != 0), nil
+	case token.OR:
+		return boolean(v != 0 || w != 0), nil
+	case token.EQUAL_TO:
+		return boolean(v == w), nil
+	case token.NOT_EQUAL_TO:
+		return boolean(v != w), nil
+	case token.LESS_THAN:
+		return boolean(v < w), nil
+	case token.GREATER_THAN:
+		return boolean(v > w), nil
+	case token.LESS_THAN_EQUAL_TO:
+		return boolean(v <= w), nil
+	case token.GREATER_THAN_EQUAL_TO:
+		return boolean(v >= w), nil
+	}
+	return Value{}, u.errorf(x.Pos(), "not-constant", "expression is not constant")
+}
+
+// binaryFloat evaluates the binary operators that Spin defines on floats.
+// Comparisons return integers.
+func (u *unit) binaryFloat(x *ast.BinaryExpression, v, w float32) (Value, error) {
+	switch x.Operator {
+	case token.ADD:
+		return float(v + w), nil
+	case token.SUBTRACT:
+		return float(v - w), nil
+	case token.MULTIPLY:
+		return float(v * w), nil
+	case token.DIVIDE:
+		if w == 0 {
+			return Value{}, u.errorf(x.Y.Pos(), "division-by-zero", "division by zero")
+		}
+		return float(v / w), nil
+	case token.LIMIT_MINIMUM:
+		return float(float32(math.Max(float64(v), float64(w)))), nil
+	case token.LIMIT_MAXIMUM:
+		return float(float32(math.Min(float64(v), float64(w)))), nil
+	case token.EQUAL_TO:
+		return boolean(v == w), nil
+	case token.NOT_EQUAL_TO:
+		return boolean(v != w), nil
+	case token.LESS_THAN:
+		return boolean(v < w), nil
+	case token.GREATER_THAN:
+		return boolean(v > w), nil
+	case token.LESS_THAN_EQUAL_TO:
+		return boolean(v <= w), nil
+	case token.GREATER_THAN_EQUAL_TO:
+		return boolean(v >= w), nil
+	}
+	return Value{}, u.errorf(x.Pos(), "float", "operator %q is not defined on floats", u.text(x.X.End(), x.Y.Pos()))
+}
+
+// text returns the source between from and to, without surrounding
+// space.
+func (u *unit) text(from, to token.Pos) string {
+	return strings.TrimSpace(string(u.source.Text[u.file.Offset(from):u.file.Offset(to)]))
+}
+
+// fold records the values of the expressions of the object outside CON
+// and DAT blocks that are known at compile time: the counts of VAR, OBJ
+// and local arrays, which must be constant, and the largest constant
+// subexpressions of method bodies.
+func (u *unit) fold() {
+	count := func(x ast.Expression) {
+		if x == nil {
+			return
+		}
+		if v, err := u.eval(x, env{}); err != nil {
+			u.report(err)
+		} else {
+			u.record(x, v)
+		}
+	}
+	declarations := func(ds []ast.Declaration) {
+		for _, d := range ds {
+			switch d := d.(type) {
+			case *ast.VariableDeclaration:
+				count(d.Count)
+			case *ast.ObjectDeclaration:
+				count(d.Count)
+			}
+		}
+	}
+	method := func(locals []*ast.LocalDeclaration, body []ast.Statement) {
+		for _, l := range locals {
+			count(l.Count)
+		}
+		for _, s := range body {
+			u.foldMethod(s)
+		}
+	}
+
+	for _, b := range u.object.Blocks {
+		switch b := b.(type) {
+		case *ast.VarBlock:
+			declarations(b.Declarations)
+		case *ast.ObjBlock:
+			declarations(b.Declarations)
+		case *ast.PubBlock:
+			method(b.Locals, b.Body)
+		case *ast.PriBlock:
+			method(b.Locals, b.Body)
+		}
+	}
+}
+
+// foldMethod records the values of the largest constant subexpressions of
+// the method statement s, reporting those that cannot be evaluated, such
+// as divisions by zero.
+func (u *unit) foldMethod(s ast.Statement) {
+	ast.Inspect(s, func(n ast.Node) bool {
+		x, ok := n.(ast.Expression)
+		if !ok || !u.isConstant(x) {
+			return true
+		}
+		if v, err := u.eval(x, env{method: true}); err != nil {
+			u.report(err)
+		} else {
+			u.record(x, v)
+		}
+		return false
+	})
+}
+
+// isConstant reports whether x can be evaluated at compile time.
+func (u *unit) isConstant(x ast.Expression) bool {
+	switch x := x.(type) {
+	case *ast.NumberLiteral, *ast.BooleanLiteral, *ast.ConstantExpression, *ast.ObjectConstantExpression:
+		return true
+	case *ast.StringLiteral:
+		return len(x.Value) == 1
+	case *ast.ParenExpression:
+		return u.isConstant(x.X)
+	case *ast.Identifier:
+		sym := u.info.Uses[x]
+		return sym != nil && sym.Kind == checker.Constant
+	case *ast.UnaryExpression:
+		return foldable(x) && u.isConstant(x.X)
+	case *ast.BinaryExpression:
+		return u.isConstant(x.X) && u.isConstant(x.Y)
+	case *ast.CallExpression:
+		id, ok := x.Function.(*ast.Identifier)
+		if !ok || len(x.Arguments) != 1 || !u.isConstant(x.Arguments[0]) {
+			return false
+		}
+		sym := u.info.Uses[id]
+		switch strings.ToUpper(id.Name) {
+		case "FLOAT", "ROUND", "TRUNC":
+			return sym != nil && sym.Decl == nil
+		}
+	}
+	return false
+}
+
+// foldable reports whether the unary operator of x can be evaluated at
+// compile time. The others change their operand or read memory.
+func foldable(x ast.Expression) bool {
+	switch x := x.(type) {
+	case *ast.UnaryExpression:
+		switch x.Operator {
+		case token.SUBTRACT, token.BITWISE_NOT, token.NOT, token.ABSOLUTE,
+			token.DECODE, token.ENCODE, token.SQUARE_ROOT:
+			return true
+		}
+	case *ast.BinaryExpression:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package compiler_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/compiler"
+)
+
+// check checks main.spin in files, failing the test if it has errors.
+func check(t *testing.T, files map[string]string) *compiler.Result {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, src := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(src)}
+	}
+	r, err := compiler.Check("main.spin", &compiler.Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// values returns the folded values of the top object of r, in source
+// order, as "text = value".
+func values(r *compiler.Result) string {
+	s := r.Tree.Root
+	var list []string
+	ast.Inspect(s.Object, func(n ast.Node) bool {
+		if x, ok := n.(ast.Expression); ok {
+			if v, ok := r.Values[x]; ok {
+				list = append(list, fmt.Sprintf("%s = %s", s.Text[s.File.Offset(x.Pos()):s.File.Offset(x.End())], v))
+			}
+		}
+		return true
+	})
+	return strings.Join(list, "; ")
+}
+
+// Ensure constants follow 32-bit Spin semantics.
+func TestCheck_Constants(t *testing.T) {
+	var tests = []struct {
+		expr  string
+		value string
+	}{
+		{expr: "POSX + 1", value: "-2147483648"},
+		{expr: "NEGX - 1", value: "2147483647"},
+		{expr: "$FFFF_FFFF * 2", value: "-2"},
+		{expr: "-7 / 2", value: "-3"},
+		{expr: "-7 // 2", value: "-1"},
+		{expr: "-16 ~> 2", value: "-4"},
+		{expr: "-16 >> 28", value: "15"},
+		{expr: "%1101 >< 4", value: "11"},
+		{expr: "|< 5", value: "32"},
+		{expr: ">| 32", value: "6"},
+		{expr: "$8000_0000 ** 4", value: "-2"},
+		{expr: "1 <- 31 -> 30", value: "2"},
+		{expr: "^^ 17", value: "4"},
+		{expr: "3 #> 1 <# 2", value: "2"},
+		{expr: "1 < 2 and not 3 == 4", value: "-1"},
+		{expr: "XTAL1 + PLL16X", value: "1032"},
+
+		// Floats
+		{expr: "1.5 * 2.0", value: "3"},
+		{expr: "float(3) / 2.0", value: "1.5"},
+		{expr: "PI * 2.0", value: "6.2831855"},
+		{expr: "-1.5 #> 0.0", value: "0"},
+		{expr: "|| -2.25", value: "2.25"},
+		{expr: "^^ 2.25", value: "1.5"},
+		{expr: "round(2.5)", value: "3"},
+		{expr: "round(-2.5)", value: "-3"},
+		{expr: "trunc(-2.7)", value: "-2"},
+		{expr: "1.0 < 2.0", value: "-1"},
+		{expr: "trunc(80_000_000.0 / 1000.0)", value: "80000"},
+
+		// Other objects
+		{expr: "lib#A * 2", value: "4"},
+		{expr: "lib#B", value: "10"},
+	}
+
+	for i, tt := range tests {
+		r := check(t, map[string]string{
+			"main.spin": "OBJ\n  lib : \"lib\"\nCON\n  X = " + tt.expr,
+			"lib.spin":  "CON\n  A = 2\n  B = A * 5",
+		})
+		if err := r.Err(); err != nil {
+			t.Errorf("%d. %q: %s", i, tt.expr, err)
+		} else if got, exp := values(r), tt.expr+" = "+tt.value; got != exp {
+			t.Errorf("%d. mismatch:\nexp=%s\ngot=%s", i, exp, got)
+		}
+	}
+}
+
+// Ensure constant errors are reported with stable codes.
+func TestCheck_ConstantErrors(t *testing.T) {
+	var tests = []struct {
+		src  string
+		diag string
+	}{
+		{src: "CON\nX = 1.0 + 1", diag: `main.spin:2:5: error: cannot mix float and integer operands [float]`},
+		{src: "CON\nX = 1.0 & 2.0", diag: `main.spin:2:5: error: operator "&" is not defined on floats [float]`},
+		{src: "CON\nX = !1.0", diag: `main.spin:2:5: error: operator "!" is not defined on floats [float]`},
+		{src: "CON\nX = round(1)", diag: `main.spin:2:11: error: round of integer operand [float]`},
+		{src: "CON\nX = float(1.0)", diag: `main.spin:2:11: error: float of float operand [float]`},
+		{src: "CON\nX = trunc(1.0e20)", diag: `main.spin:2:5: error: trunc of 1e+20 does not fit in 32 bits [out-of-range]`},
+		{src: "CON\nX = lib#C\nOBJ\n  lib : \"lib\"", diag: `main.spin:2:9: error: undefined: lib#C [undefined]`},
+		{src: "CON\nX = bad#D\nOBJ\n  bad : \"bad\"", diag: `bad.spin:2:11: error: division by zero [division-by-zero]`},
+		{src: "CON\nX = cnt", diag: `main.spin:2:5: error: register cnt is not constant [not-constant]`},
+		{src: "VAR\nlong v[cnt]", diag: `main.spin:2:8: error: register cnt is not constant [not-constant]`},
+		{src: "PUB main | x\n  x := 1 / 0", diag: `main.spin:2:12: error: division by zero [division-by-zero]`},
+	}
+
+	for i, tt := range tests {
+		r := check(t, map[string]string{
+			"main.spin": tt.src,
+			"lib.spin":  "CON\n  A = 2",
+			"bad.spin":  "CON\n  D = 1 / 0",
+		})
+		if got := r.Diagnostics.Error(); got != tt.diag {
+			t.Errorf("%d. %q mismatch:\nexp=%s\ngot=%s", i, tt.src, tt.diag, got)
+		}
+	}
+}
+
+// Ensure constant subexpressions of methods are folded. Float literals are
+// plain integers in methods, except in constant().
+func TestCheck_Folding(t *testing.T) {
+	r := check(t, map[string]string{
+		"main.spin": "CON\n  N = 4\nPUB main | x, buf[N * 2]\n  x := 3 * N + x\n  x := -1.0 + 1\n  x := constant(1.0 + 1.0)\n  x := x * (2 + 3)",
+	})
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	exp := "4 = 4; N * 2 = 8; 3 * N = 12; -1.0 + 1 = -1065353215; constant(1.0 + 1.0) = 2; (2 + 3) = 5"
+	if got := values(r); got != exp {
+		t.Errorf("mismatch:\nexp=%s\ngot=%s", exp, got)
+	}
+}
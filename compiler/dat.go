@@ -0,0 +1,320 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/pasm"
+	"github.com/bweir/lame/token"
+)
+
+// An assembler lays out the DAT blocks of an object and assembles them
+// into an image. It makes two passes: the first places the labels, and
+// the second evaluates the values, which may refer to labels further on.
+// Errors are reported in the second pass only.
+type assembler struct {
+	u        *unit
+	emit     bool // the second pass
+	image    []byte
+	hub      int    // image offset of the next entry
+	orgHub   int    // image offset of the last org
+	orgCog   int    // cog address of the last org
+	reserved int    // longs reserved since the last org
+	global   string // the last global label, which scopes local labels
+	pending  []string
+	labels   map[string]label // by upper-case name, ":LOCAL" after its global
+	files    map[*ast.FileDirective]*file
+}
+
+// A file is the text of a file included by a DAT block.
+type file struct {
+	data []byte
+	err  error
+}
+
+// A label is the address of a DAT entry, both in the image and in cog
+// memory.
+type label struct {
+	hub, cog int
+}
+
+// assemble checks the constants and DAT blocks of the object, and
+// assembles the DAT blocks into the image.
+func (u *unit) assemble() {
+	u.checkConstants()
+
+	a := &assembler{
+		u:      u,
+		labels: make(map[string]label),
+		files:  make(map[*ast.FileDirective]*file),
+	}
+	u.labels = make(map[*ast.Label]int)
+	a.run()
+	a.emit = true
+	a.run()
+	u.image = a.image
+}
+
+func (a *assembler) run() {
+	a.hub, a.orgHub, a.orgCog, a.reserved = 0, 0, 0, 0
+	a.global, a.pending = "", nil
+	for _, b := range a.u.object.Blocks {
+		if b, ok := b.(*ast.DatBlock); ok {
+			for _, e := range b.Entries {
+				a.entry(e)
+			}
+		}
+	}
+	a.bind()
+}
+
+// report reports err in the second pass.
+func (a *assembler) report(err error) {
+	if a.emit {
+		a.u.report(err)
+	}
+}
+
+// eval evaluates x, or returns 0 after reporting the error. Floats are
+// returned as their bits.
+func (a *assembler) eval(x ast.Expression) int32 {
+	v, err := a.u.eval(x, env{a: a})
+	if err != nil {
+		a.report(err)
+	} else if a.emit {
+		a.u.record(x, v)
+	}
+	return v.Bits
+}
+
+// evalDefault evaluates x, or returns def if x is nil.
+func (a *assembler) evalDefault(x ast.Expression, def int32) int32 {
+	if x == nil {
+		return def
+	}
+	return a.eval(x)
+}
+
+// cog returns the cog address of the next entry.
+func (a *assembler) cog() int {
+	return a.orgCog + (a.hub-a.orgHub)/4 + a.reserved
+}
+
+// key returns the label table key of name.
+func (a *assembler) key(name string) string {
+	name = strings.ToUpper(name)
+	if strings.HasPrefix(name, ":") {
+		return a.global + name
+	}
+	return name
+}
+
+// label returns the label named by x.
+func (a *assembler) label(x *ast.Identifier) (label, bool) {
+	l, ok := a.labels[a.key(x.Name)]
+	return l, ok
+}
+
+// resolve returns the cog address of the label or register named by x.
+// ok is false if x names neither.
+func (a *assembler) resolve(x *ast.Identifier) (v int32, ok bool, err error) {
+	if l, ok := a.label(x); ok {
+		return int32(l.cog), true, nil
+	}
+	if strings.HasPrefix(x.Name, ":") {
+		return 0, false, a.u.errorf(x.Pos(), "undefined", "undefined: %s", x.Name)
+	}
+	if r, ok := pasm.Registers[strings.ToUpper(x.Name)]; ok {
+		return int32(r), true, nil
+	}
+	return 0, false, nil
+}
+
+// bind places the pending labels at the next entry.
+func (a *assembler) bind() {
+	if !a.emit {
+		for _, key := range a.pending {
+			if _, ok := a.labels[key]; !ok {
+				a.labels[key] = label{hub: a.hub, cog: a.cog()}
+			}
+		}
+	}
+	a.pending = nil
+}
+
+// align pads the image to a multiple of size bytes.
+func (a *assembler) align(size int) {
+	for a.hub%size != 0 {
+		a.write(1, 0)
+	}
+}
+
+// write stores the low size bytes of v, little-endian.
+func (a *assembler) write(size int, v int32) {
+	if a.emit {
+		for i := 0; i < size; i++ {
+			a.image = append(a.image, byte(v>>(8*i)))
+		}
+	}
+	a.hub += size
+}
+
+func (a *assembler) entry(e ast.DataEntry) {
+	switch e := e.(type) {
+	case *ast.Label:
+		if !e.IsLocal() {
+			a.global = strings.ToUpper(e.Name)
+		}
+		a.pending = append(a.pending, a.key(e.Name))
+		if a.emit {
+			a.u.labels[e] = a.labels[a.key(e.Name)].hub
+		}
+
+	case *ast.DataDirective:
+		size := sizes[e.Size]
+		a.align(size)
+		a.bind()
+		for _, v := range e.Values {
+			a.value(v, size)
+		}
+
+	case *ast.FileDirective:
+		a.bind()
+		for _, b := range a.file(e) {
+			a.write(1, int32(b))
+		}
+
+	case *ast.Instruction:
+		a.align(4)
+		a.bind()
+		a.write(4, a.instruction(e))
+
+	case *ast.OrgDirective:
+		address := a.evalDefault(e.Address, 0)
+		if address < 0 || address > 0x1F0 {
+			a.report(a.u.errorf(e.Address.Pos(), "out-of-range", "org address $%X out of range $0..$1F0", address))
+			address = 0
+		}
+		a.orgHub, a.orgCog, a.reserved = a.hub, int(address), 0
+		a.bind()
+
+	case *ast.ResDirective:
+		a.bind()
+		count := a.evalDefault(e.Count, 1)
+		if count < 0 {
+			a.report(a.u.errorf(e.Count.Pos(), "out-of-range", "negative res count %d", count))
+			count = 0
+		}
+		a.reserved += int(count)
+
+	case *ast.FitDirective:
+		a.bind()
+		limit := a.evalDefault(e.Address, 0x1F0)
+		if cog := a.cog(); cog > int(limit) {
+			a.report(a.u.errorf(e.Pos(), "fit", "cog code ends at $%X, past the fit limit $%X", cog, limit))
+		}
+	}
+}
+
+// sizes holds the size in bytes of BYTE, WORD and LONG.
+var sizes = map[token.Type]int{
+	token.BYTE: 1,
+	token.WORD: 2,
+	token.LONG: 4,
+}
+
+// value stores a data value. Strings store one element per character.
+func (a *assembler) value(v *ast.DataValue, size int) {
+	if v.Size != "" {
+		size = sizes[v.Size]
+	}
+
+	count := int32(1)
+	if v.Count != nil {
+		if count = a.eval(v.Count); count < 0 {
+			a.report(a.u.errorf(v.Count.Pos(), "out-of-range", "negative repeat count %d", count))
+			count = 0
+		}
+	}
+
+	if s, ok := v.Value.(*ast.StringLiteral); ok {
+		for i := int32(0); i < count; i++ {
+			for _, c := range []byte(s.Value) {
+				a.write(size, int32(c))
+			}
+		}
+		return
+	}
+
+	// Values may refer to labels further on, so they are only evaluated
+	// in the second pass.
+	var x int32
+	if a.emit {
+		x = a.eval(v.Value)
+	}
+	for i := int32(0); i < count; i++ {
+		a.write(size, x)
+	}
+}
+
+// file returns the bytes of the file included by d, which is read once.
+func (a *assembler) file(d *ast.FileDirective) []byte {
+	f, ok := a.files[d]
+	if !ok {
+		f = &file{}
+		f.data, f.err = a.u.opts.readFile(a.u.opts.join(a.u.name, d.Path))
+		a.files[d] = f
+	}
+	if f.err != nil {
+		a.report(a.u.errorf(d.Pos(), "file-not-found", "%s", f.err))
+	}
+	return f.data
+}
+
+// instruction returns the encoding of in.
+func (a *assembler) instruction(in *ast.Instruction) int32 {
+	if !a.emit {
+		return 0
+	}
+
+	var dest, src int32
+	if in.Destination != nil {
+		dest = a.register(in.Destination, "destination")
+	}
+	if in.Source != nil {
+		src = a.register(in.Source, "source")
+	}
+
+	// call #label stores its return address in the ret instruction of
+	// label_ret.
+	if in.Opcode == "CALL" {
+		target, ok := in.Source.(*ast.Identifier)
+		if !ok || !in.Immediate {
+			a.report(a.u.errorf(in.Source.Pos(), "bad-call", "call needs a #label operand"))
+			return 0
+		}
+		ret := &ast.Identifier{From: target.From, To: target.To, Name: target.Name + "_ret"}
+		l, ok := a.label(ret)
+		if !ok {
+			a.report(a.u.errorf(target.Pos(), "undefined", "undefined: %s", ret.Name))
+			return 0
+		}
+		dest = int32(l.cog)
+	}
+
+	code, err := pasm.Encode(in.Opcode, in.Condition, in.Effects, uint32(dest), uint32(src), in.Immediate)
+	if err != nil {
+		a.report(a.u.errorf(in.Pos(), "bad-instruction", "%s", err))
+	}
+	return int32(code)
+}
+
+// register evaluates the operand x, which must be a cog address.
+func (a *assembler) register(x ast.Expression, what string) int32 {
+	v := a.eval(x)
+	if v < 0 || v > pasm.MaxRegister {
+		a.report(a.u.errorf(x.Pos(), "out-of-range", "%s $%X out of range $0..$1FF", what, v))
+		return 0
+	}
+	return v
+}
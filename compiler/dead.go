@@ -0,0 +1,214 @@
+package compiler
+
+import (
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/checker"
+	"github.com/bweir/lame/token"
+)
+
+// Dead holds the methods and DAT that a program cannot reach, which
+// Compile leaves out of the layout.
+//
+// A program starts in the first PUB method of the top object, and in
+// every method that a live method starts in a cog by COGNEW or COGINIT
+// or takes the address of with @. The methods that none of them call are
+// dead. Unused DAT
+// is only dead where leaving it out cannot move or change anything else:
+// data at the end of the DAT of an object, none of whose labels has its
+// address taken, is indexed or is used by the DAT blocks. A top object
+// without a PUB method has no entry point, and nothing in it is dead.
+type Dead struct {
+	Methods []*CallNode // in the order of CallGraph.Methods
+	Data    []*DeadData // in the order of Tree.Sources
+
+	methods map[ast.Block]bool
+	data    map[*Source]*DeadData
+}
+
+// A DeadData is the unused data at the end of the DAT of an object, from
+// Offset to the end of the DAT.
+type DeadData struct {
+	Source *Source
+	Labels []*ast.Label
+	Offset int // DAT offset of the first label
+	Size   int // bytes
+}
+
+// dead returns the methods and DAT of tree that cannot be reached.
+func dead(tree *Tree, units map[*Source]*unit, calls *CallGraph) *Dead {
+	d := &Dead{methods: make(map[ast.Block]bool), data: make(map[*Source]*DeadData)}
+
+	nodes := make(map[ast.Block]*CallNode)
+	callees := make(map[*CallNode][]*CallNode)
+	for _, m := range calls.Methods {
+		nodes[m.Method] = m
+	}
+	for _, c := range calls.Calls {
+		callees[c.Caller] = append(callees[c.Caller], c.Callee)
+	}
+
+	// Cog edges are in callees, so only method pointers need visit to
+	// look at the body of a method that becomes live.
+	live := make(map[*CallNode]bool)
+	var visit func(m *CallNode)
+	visit = func(m *CallNode) {
+		if m == nil || live[m] {
+			return
+		}
+		live[m] = true
+		for _, callee := range callees[m] {
+			visit(callee)
+		}
+		for _, b := range units[m.Source].methodPointers(m.Method) {
+			visit(nodes[b])
+		}
+	}
+	entry := false
+	for _, m := range calls.Methods {
+		if _, ok := m.Method.(*ast.PubBlock); ok && m.Source == tree.Root {
+			visit(m)
+			entry = true
+			break
+		}
+	}
+	if !entry {
+		return d
+	}
+
+	for _, m := range calls.Methods {
+		if !live[m] {
+			d.Methods = append(d.Methods, m)
+			d.methods[m.Method] = true
+		}
+	}
+	for _, s := range tree.Sources {
+		if data := units[s].deadData(live); data != nil {
+			d.Data = append(d.Data, data)
+			d.data[s] = data
+		}
+	}
+	return d
+}
+
+// methodPointers returns the methods whose address is taken in b, as in
+// @method or @obj.method.
+func (u *unit) methodPointers(b ast.Block) []ast.Block {
+	var methods []ast.Block
+	ast.Inspect(b, func(n ast.Node) bool {
+		x, ok := n.(*ast.UnaryExpression)
+		if !ok || x.Operator != token.AT {
+			return true
+		}
+		y := x.X
+		if sel, ok := y.(*ast.SelectorExpression); ok {
+			y = sel.Name
+		}
+		if id, ok := y.(*ast.Identifier); ok {
+			if sym := u.info.Uses[id]; sym != nil && sym.Kind == checker.Method {
+				methods = append(methods, sym.Decl.(ast.Block))
+			}
+		}
+		return true
+	})
+	return methods
+}
+
+// deadData returns the unused data at the end of the DAT of u, given the
+// live methods, or nil if there is none or leaving it out may not be safe.
+func (u *unit) deadData(live map[*CallNode]bool) *DeadData {
+	var entries []ast.DataEntry
+	for _, b := range u.object.Blocks {
+		if b, ok := b.(*ast.DatBlock); ok {
+			entries = append(entries, b.Entries...)
+		}
+	}
+
+	// Labels used by name may be kept, but an address taken or indexed
+	// may reach any data after its label, as may the DAT blocks.
+	used := make(map[*ast.Label]bool)
+	escapes := false
+	label := func(x ast.Expression) *ast.Label {
+		if id, ok := x.(*ast.Identifier); ok {
+			if sym := u.info.Uses[id]; sym != nil && sym.Kind == checker.Label {
+				return sym.Decl.(*ast.Label)
+			}
+		}
+		return nil
+	}
+	for m := range live {
+		if m.Source != u.source {
+			continue
+		}
+		ast.Inspect(m.Method, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.UnaryExpression:
+				y := x.X
+				if ix, ok := y.(*ast.IndexExpression); ok {
+					y = ix.X
+				}
+				if x.Operator == token.AT_AT || x.Operator == token.AT && label(y) != nil {
+					escapes = true
+				}
+			case *ast.IndexExpression:
+				if label(x.X) != nil {
+					escapes = true
+				}
+			case *ast.Identifier:
+				if l := label(x); l != nil {
+					used[l] = true
+				}
+			}
+			return true
+		})
+	}
+	for _, b := range u.object.Blocks {
+		if b, ok := b.(*ast.DatBlock); ok {
+			ast.Inspect(b, func(n ast.Node) bool {
+				if x, ok := n.(ast.Expression); ok && label(x) != nil {
+					escapes = true
+				}
+				return true
+			})
+		}
+	}
+	if escapes {
+		return nil
+	}
+
+	// The dead data is the data after the last label that is used, or
+	// after the last instruction or directive, starting at a label.
+	start := len(entries)
+loop:
+	for i := len(entries) - 1; i >= 0; i-- {
+		switch e := entries[i].(type) {
+		case *ast.DataDirective, *ast.FileDirective:
+		case *ast.Label:
+			if e.IsLocal() || used[e] {
+				break loop
+			}
+			start = i
+		default:
+			break loop
+		}
+	}
+	if start == len(entries) {
+		return nil
+	}
+
+	data := &DeadData{Source: u.source, Offset: u.labels[entries[start].(*ast.Label)]}
+	data.Size = len(u.image) - data.Offset
+	for i, e := range entries {
+		if e, ok := e.(*ast.Label); ok {
+			if i >= start {
+				data.Labels = append(data.Labels, e)
+			} else if u.labels[e] >= data.Offset {
+				// A label just before shares the data.
+				return nil
+			}
+		}
+	}
+	if data.Size == 0 {
+		return nil
+	}
+	return data
+}
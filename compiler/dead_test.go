@@ -0,0 +1,129 @@
+package compiler_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bweir/lame/compiler"
+)
+
+// compile compiles main.spin from files, which must have no errors.
+func compile(t *testing.T, files map[string]string) *compiler.Result {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, src := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(src)}
+	}
+	r, err := compiler.Compile("main.spin", &compiler.Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	} else if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// dead returns the dead methods and data of r, as "object:name" and
+// "object:labels@offset+size".
+func dead(r *compiler.Result) string {
+	var list []string
+	for _, m := range r.Dead.Methods {
+		list = append(list, m.Source.Name+":"+m.Name)
+	}
+	for _, d := range r.Dead.Data {
+		var labels []string
+		for _, l := range d.Labels {
+			labels = append(labels, l.Name)
+		}
+		list = append(list, fmt.Sprintf("%s:%s@%d+%d", d.Source.Name, strings.Join(labels, ","), d.Offset, d.Size))
+	}
+	return strings.Join(list, " ")
+}
+
+// Ensure methods that cannot be reached from the first PUB, a cog or a
+// method pointer are dead, with unused data at the end of DAT, and are
+// left out of the layout. The address of pointed is only taken in the
+// dead setup, so it is dead too.
+func TestCompile_Dead(t *testing.T) {
+	files := map[string]string{
+		"main.spin": "VAR\n  long stack[16]\nOBJ\n  gfx : \"gfx\"\nPUB main | x\n  cognew(loop, @stack)\n  x := table\n  gfx.Blit(1)\nPRI loop\n  repeat\nPRI unused\n  cleanup\nPRI cleanup\nPRI pointed\nPUB setup | p\n  p := @pointed\nDAT\ntable long 1\nspare long 2, 3\nname byte \"x\", 0",
+		"gfx.spin":  "PUB Blit(x)\n  return x\nPRI clip(x)\n  return x #> 0\nDAT\nbuf long 0[4]",
+	}
+	r := compile(t, files)
+
+	exp := "gfx.spin:clip main.spin:unused main.spin:cleanup main.spin:pointed main.spin:setup gfx.spin:buf@0+16 main.spin:spare,name@4+10"
+	if got := dead(r); got != exp {
+		t.Errorf("unexpected dead code:\nexp=%s\ngot=%s", exp, got)
+	}
+
+	var objects []string
+	for _, o := range r.Layout.Objects {
+		objects = append(objects, fmt.Sprintf("%s methods=%d dat=%d labels=%d size=%d", o.Source.Name, o.Methods, o.DAT, len(o.Labels), o.Size))
+	}
+	if got, exp := strings.Join(objects, " "), "main.spin methods=2 dat=4 labels=1 size=20 gfx.spin methods=1 dat=0 labels=0 size=8"; got != exp {
+		t.Errorf("unexpected layout:\nexp=%s\ngot=%s", exp, got)
+	}
+
+	// Check lays out the whole program, so the image is smaller by the
+	// method table entries and DAT left out.
+	if got, exp := check(t, files).Layout.Image, 92; got != exp {
+		t.Errorf("unexpected checked image size: exp=%d got=%d", exp, got)
+	}
+	if got, exp := r.Layout.Image, 44; got != exp {
+		t.Errorf("unexpected compiled image size: exp=%d got=%d", exp, got)
+	}
+	if got, exp := r.Layout.Free, compiler.HubSize-(44+r.Layout.Var+8); got != exp {
+		t.Errorf("unexpected free bytes: exp=%d got=%d", exp, got)
+	}
+}
+
+// Ensure cogs and method pointers keep methods alive only when a live
+// method starts them or takes their address, however deep the chain.
+func TestCompile_DeadRoots(t *testing.T) {
+	var tests = []struct {
+		src  string
+		dead string
+	}{
+		{src: "PUB main | p\n  p := @a\nPRI a\nPRI b", dead: "main.spin:b"},
+		{src: "PUB main\nPRI a | p\n  p := @b\nPRI b", dead: "main.spin:a main.spin:b"},
+		{src: "VAR\n  long s[8]\nPUB main\nPRI a\n  cognew(b, @s)\nPRI b", dead: "main.spin:a main.spin:b"},
+		{src: "VAR\n  long s[8]\nPUB main | p\n  p := @a\nPRI a\n  cognew(b, @s)\nPRI b | p\n  p := @c\nPRI c\nPRI d", dead: "main.spin:d"},
+	}
+
+	for i, tt := range tests {
+		r := compile(t, map[string]string{"main.spin": tt.src})
+		if got := dead(r); got != tt.dead {
+			t.Errorf("%d. %q mismatch:\nexp=%s\ngot=%s", i, tt.src, tt.dead, got)
+		}
+	}
+}
+
+// Ensure unused DAT is only dead where leaving it out is safe.
+func TestCompile_DeadData(t *testing.T) {
+	var tests = []struct {
+		src  string
+		dead string
+	}{
+		{src: "PUB main | x\n  x := a\nDAT\na long 1\nb long 2", dead: "main.spin:b@4+4"},
+		{src: "PUB main | x\n  x := b\nDAT\na long 1\nb long 2", dead: ""},
+		{src: "PUB main | x\n  x := a[1]\nDAT\na long 1\nb long 2", dead: ""},
+		{src: "PUB main | x\n  x := @a\nDAT\na long 1\nb long 2", dead: ""},
+		{src: "PUB main | x\n  x := @@0\nDAT\na long 1\nb long 2", dead: ""},
+		{src: "PUB main\nDAT\na long @b\nb long 2", dead: ""},
+		{src: "PUB main\nDAT\n org 0\na jmp #a\nb long 2", dead: ""},
+		{src: "PUB main\nDAT\n org 0\n mov 0, 0\nb long 2\n res 1", dead: ""},
+		{src: "PUB main\nDAT\na\nb long 2", dead: "main.spin:a,b@0+4"},
+		{src: "PUB main | x\n  x := a\nDAT\na\nb long 2", dead: ""},
+		{src: "PRI main\nDAT\na long 1", dead: ""},
+		{src: "DAT\na long 1", dead: ""},
+	}
+
+	for i, tt := range tests {
+		r := compile(t, map[string]string{"main.spin": tt.src})
+		if got := dead(r); got != tt.dead {
+			t.Errorf("%d. %q mismatch:\nexp=%s\ngot=%s", i, tt.src, tt.dead, got)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package compiler
+
+import (
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/checker"
+)
+
+// A CallGraph is the graph of the calls between the methods of a
+// program, inside and across objects.
+type CallGraph struct {
+	Methods []*CallNode // every method, in the order of Tree.Sources
+	Calls   []*CallEdge // every caller and callee once, in source order
+}
+
+// A CallNode is a method in a CallGraph. Cog is set if the method is
+// started in a new cog by COGNEW or COGINIT.
+type CallNode struct {
+	Source *Source
+	Method ast.Block // *ast.PubBlock or *ast.PriBlock
+	Name   string
+	Cog    bool
+}
+
+// A CallEdge is a method that calls another, or that starts it in a new
+// cog if Cog is set.
+type CallEdge struct {
+	Caller, Callee *CallNode
+	Cog            bool
+}
+
+// callGraph returns the call graph of the methods of tree.
+func callGraph(tree *Tree, units map[*Source]*unit) *CallGraph {
+	g := &CallGraph{}
+	nodes := make(map[ast.Block]*CallNode)
+	for _, s := range tree.Sources {
+		for _, b := range s.Object.Blocks {
+			switch b.(type) {
+			case *ast.PubBlock, *ast.PriBlock:
+				n := &CallNode{Source: s, Method: b, Name: units[s].info.Defs[b].Name}
+				nodes[b] = n
+				g.Methods = append(g.Methods, n)
+			}
+		}
+	}
+
+	type key struct {
+		caller, callee *CallNode
+		cog            bool
+	}
+	seen := make(map[key]bool)
+	for _, caller := range g.Methods {
+		u := units[caller.Source]
+		cogs := make(map[*ast.Identifier]bool)
+		ast.Inspect(caller.Method, func(n ast.Node) bool {
+			if x, ok := n.(*ast.CallExpression); ok {
+				for i := range x.Arguments {
+					if id := u.cogEntry(x, i); id != nil {
+						cogs[id] = true
+					}
+				}
+			}
+			x, ok := n.(*ast.Identifier)
+			if !ok {
+				return true
+			}
+			sym := u.info.Uses[x]
+			if sym == nil || sym.Kind != checker.Method {
+				return true
+			}
+			k := key{caller, nodes[sym.Decl.(ast.Block)], cogs[x]}
+			if k.callee != nil && !seen[k] {
+				seen[k] = true
+				k.callee.Cog = k.callee.Cog || k.cog
+				g.Calls = append(g.Calls, &CallEdge{Caller: k.caller, Callee: k.callee, Cog: k.cog})
+			}
+			return true
+		})
+	}
+	return g
+}
@@ -0,0 +1,47 @@
+package compiler_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Ensure calls are found inside and across objects, once per caller and
+// callee, and that methods started in a cog are marked.
+func TestCheck_CallGraph(t *testing.T) {
+	r := check(t, map[string]string{
+		"main.spin": "VAR\n  long stack[32]\nOBJ\n  gfx : \"gfx\"\nPUB main\n  cognew(loop, @stack)\n  helper\n  helper\n  gfx.Blit(1)\nPRI loop\n  repeat\n    gfx.Blit(helper)\nPRI helper\n  return 1\nPRI unused",
+		"gfx.spin":  "PUB Blit(x)\n  return clip(x)\nPRI clip(x)\n  return x",
+	})
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var methods []string
+	for _, m := range r.Calls.Methods {
+		methods = append(methods, fmt.Sprintf("%s:%s cog=%t", m.Source.Name, m.Name, m.Cog))
+	}
+	exp := strings.Join([]string{
+		"gfx.spin:Blit cog=false",
+		"gfx.spin:clip cog=false",
+		"main.spin:main cog=false",
+		"main.spin:loop cog=true",
+		"main.spin:helper cog=false",
+		"main.spin:unused cog=false",
+	}, "\n")
+	if got := strings.Join(methods, "\n"); got != exp {
+		t.Errorf("unexpected methods:\nexp=%s\ngot=%s", exp, got)
+	}
+
+	var calls []string
+	for _, c := range r.Calls.Calls {
+		arrow := "->"
+		if c.Cog {
+			arrow = "=>"
+		}
+		calls = append(calls, c.Caller.Name+arrow+c.Callee.Name)
+	}
+	if got, exp := strings.Join(calls, " "), "Blit->clip main=>loop main->helper main->Blit loop->Blit loop->helper"; got != exp {
+		t.Errorf("unexpected calls:\nexp=%s\ngot=%s", exp, got)
+	}
+}
@@ -0,0 +1,238 @@
+package compiler
+
+import (
+	"strconv"
+
+	"github.com/bweir/lame/ast"
+)
+
+// HubSize is the size in bytes of the hub RAM of the Propeller 1.
+const HubSize = 32768
+
+// A Layout is the memory layout of a program in hub RAM, as the Spin
+// compiler lays it out:
+//
+//	$0000      program header, 16 bytes
+//	$0010      object images, the top object first
+//	VarBase    the VAR of every object instance, the top instance first
+//	StackBase  the stack of the first cog, after a frame of two longs
+//
+// Spin methods are not compiled yet, so the code of methods, and the
+// strings they use, are not counted.
+type Layout struct {
+	Objects   []*ObjectLayout // every object, in image order
+	Instances []*Instance     // every object instance, in VAR order
+
+	Image     int // bytes of the program header and object images
+	VarBase   int // hub address of the VAR of the top instance
+	Var       int // bytes of VAR of every instance
+	StackBase int // hub address of the stack of the first cog
+	Free      int // bytes of hub RAM after StackBase, left for the stack
+}
+
+// An ObjectLayout is the layout of the image of an object, which is
+// shared by every instance of the object. The image holds a header long,
+// a long for each method and for each entry of the object table, then the
+// DAT blocks and the code of the methods.
+type ObjectLayout struct {
+	Source  *Source
+	Address int // hub address of the image
+	Size    int // bytes of the image, rounded up to a long
+
+	Methods int // entries in the method table: PUB methods, then PRI
+	Entries int // entries in the object table: one per instance
+	Tables  int // bytes of the header, method table and object table
+	DAT     int // bytes of the DAT blocks
+	Code    int // bytes of method code, 0 until methods are compiled
+
+	// Labels holds the DAT labels, with their offsets in the image.
+	Labels []*DataLabel
+
+	// Var is the bytes of VAR of each instance, rounded up to a long.
+	// Variables holds the variables in VAR order: longs, then words,
+	// then bytes, each in the order they are declared.
+	Var       int
+	Variables []*Variable
+}
+
+// A DataLabel is a DAT label at an offset in the image of its object.
+type DataLabel struct {
+	Decl   *ast.Label
+	Offset int
+}
+
+// A Variable is a VAR variable, or array, at an offset in the VAR of its
+// object.
+type Variable struct {
+	Decl   *ast.VariableDeclaration
+	Offset int
+	Size   int // bytes of the variable, or of all the elements of the array
+}
+
+// An Instance is an instance of an object, with its own VAR. Decl is the
+// OBJ declaration that makes it, and Index its index in an object array;
+// Decl is nil for the top instance.
+type Instance struct {
+	Object *ObjectLayout
+	Parent *Instance
+	Decl   *ast.ObjectDeclaration
+	Index  int
+	Var    int // hub address of the VAR of the instance
+}
+
+// layout lays out the objects of tree, which must have been checked and
+// assembled without errors, leaving out dead if it is not nil.
+func layout(tree *Tree, units map[*Source]*unit, dead *Dead) *Layout {
+	l := &Layout{Image: 16}
+	objects := make(map[*Source]*ObjectLayout)
+
+	// Objects follow the object that first uses them, each once.
+	var place func(s *Source)
+	place = func(s *Source) {
+		if objects[s] != nil {
+			return
+		}
+		o := units[s].layout(dead)
+		o.Address = l.Image
+		l.Image += o.Size
+		l.Objects = append(l.Objects, o)
+		objects[s] = o
+		for _, d := range declarations(s.Object) {
+			place(s.Children[d])
+		}
+	}
+	place(tree.Root)
+
+	// Each instance has the VAR of its object, followed by the VAR of the
+	// instances in its object table.
+	l.VarBase = l.Image
+	var instantiate func(s *Source, parent *Instance, d *ast.ObjectDeclaration, index int)
+	instantiate = func(s *Source, parent *Instance, d *ast.ObjectDeclaration, index int) {
+		in := &Instance{Object: objects[s], Parent: parent, Decl: d, Index: index, Var: l.VarBase + l.Var}
+		l.Instances = append(l.Instances, in)
+		l.Var += in.Object.Var
+		for _, d := range declarations(s.Object) {
+			for i := 0; i < units[s].count(d.Count); i++ {
+				instantiate(s.Children[d], in, d, i)
+			}
+		}
+	}
+	instantiate(tree.Root, nil, nil, 0)
+
+	l.StackBase = l.VarBase + l.Var + 8
+	l.Free = HubSize - l.StackBase
+	return l
+}
+
+// layout lays out the image and VAR of the object of u, leaving out dead
+// if it is not nil.
+func (u *unit) layout(dead *Dead) *ObjectLayout {
+	o := &ObjectLayout{Source: u.source, DAT: len(u.image)}
+	var cut *DeadData
+	if dead != nil {
+		cut = dead.data[u.source]
+	}
+	if cut != nil {
+		o.DAT = cut.Offset
+	}
+	vars := make(map[int][]*ast.VariableDeclaration)
+	for _, b := range u.object.Blocks {
+		switch b := b.(type) {
+		case *ast.PubBlock, *ast.PriBlock:
+			if dead == nil || !dead.methods[b] {
+				o.Methods++
+			}
+		case *ast.ObjBlock:
+			for _, d := range b.Declarations {
+				if d, ok := d.(*ast.ObjectDeclaration); ok {
+					o.Entries += u.count(d.Count)
+				}
+			}
+		case *ast.VarBlock:
+			for _, d := range b.Declarations {
+				if d, ok := d.(*ast.VariableDeclaration); ok {
+					vars[sizes[d.Size]] = append(vars[sizes[d.Size]], d)
+				}
+			}
+		case *ast.DatBlock:
+			for _, e := range b.Entries {
+				if e, ok := e.(*ast.Label); ok && (cut == nil || u.labels[e] < cut.Offset) {
+					o.Labels = append(o.Labels, &DataLabel{Decl: e, Offset: u.labels[e]})
+				}
+			}
+		}
+	}
+
+	o.Tables = 4 * (1 + o.Methods + o.Entries)
+	for _, label := range o.Labels {
+		label.Offset += o.Tables
+	}
+	o.Size = alignLong(o.Tables + o.DAT + o.Code)
+
+	for _, size := range []int{4, 2, 1} {
+		for _, d := range vars[size] {
+			v := &Variable{Decl: d, Offset: o.Var, Size: size * u.count(d.Count)}
+			o.Variables = append(o.Variables, v)
+			o.Var += v.Size
+		}
+	}
+	o.Var = alignLong(o.Var)
+	return o
+}
+
+// count returns the number of elements of an array of count elements,
+// where a nil count is one element.
+func (u *unit) count(count ast.Expression) int {
+	if count == nil {
+		return 1
+	}
+	v, _ := u.known(count)
+	if v.Bits < 0 {
+		return 0
+	}
+	return int(v.Bits)
+}
+
+// known returns the value of x if it is known at compile time.
+func (u *unit) known(x ast.Expression) (Value, bool) {
+	if v, ok := u.values[x]; ok {
+		return v, true
+	}
+	return literal(x)
+}
+
+// declarations returns the OBJ declarations of object, in source order.
+func declarations(object *ast.Object) []*ast.ObjectDeclaration {
+	var list []*ast.ObjectDeclaration
+	for _, b := range object.Blocks {
+		if b, ok := b.(*ast.ObjBlock); ok {
+			for _, d := range b.Declarations {
+				if d, ok := d.(*ast.ObjectDeclaration); ok {
+					list = append(list, d)
+				}
+			}
+		}
+	}
+	return list
+}
+
+// alignLong rounds n up to a multiple of 4.
+func alignLong(n int) int {
+	return (n + 3) &^ 3
+}
+
+// String returns the name of the instance, such as "gfx" or "enemy[2]",
+// with the names of the instances it is in, such as "game.gfx".
+func (in *Instance) String() string {
+	if in.Decl == nil {
+		return in.Object.Source.Name
+	}
+	name := in.Decl.Name
+	if in.Decl.Count != nil {
+		name += "[" + strconv.Itoa(in.Index) + "]"
+	}
+	if in.Parent.Decl != nil {
+		name = in.Parent.String() + "." + name
+	}
+	return name
+}
@@ -0,0 +1,53 @@
+package compiler_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Ensure objects are laid out once, with VAR ordered by size and a VAR
+// for every instance.
+func TestCheck_Layout(t *testing.T) {
+	r := check(t, map[string]string{
+		"main.spin":  "VAR\n  byte flags\n  long x\n  word w[3]\n  long y\nOBJ\n  gfx : \"gfx\"\n  e[2] : \"enemy\"\nPUB main\nPRI helper\nDAT\ntable long 1, 2\nmsg byte \"hi\", 0\n:loop word 7",
+		"gfx.spin":   "OBJ\n  e : \"enemy\"\nDAT\nbuffer long 0[4]",
+		"enemy.spin": "VAR\n  long hp\nDAT\nsprite byte 1",
+	})
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	l := r.Layout
+
+	var objects []string
+	for _, o := range l.Objects {
+		line := fmt.Sprintf("%s@%d size=%d tables=%d dat=%d var=%d", o.Source.Name, o.Address, o.Size, o.Tables, o.DAT, o.Var)
+		for _, label := range o.Labels {
+			line += fmt.Sprintf(" %s@%d", label.Decl.Name, label.Offset)
+		}
+		for _, v := range o.Variables {
+			line += fmt.Sprintf(" %s+%d", v.Decl.Name, v.Offset)
+		}
+		objects = append(objects, line)
+	}
+	exp := strings.Join([]string{
+		"main.spin@16 size=40 tables=24 dat=14 var=16 table@24 msg@32 :loop@36 x+0 y+4 w+8 flags+14",
+		"gfx.spin@56 size=24 tables=8 dat=16 var=0 buffer@8",
+		"enemy.spin@80 size=8 tables=4 dat=1 var=4 sprite@4 hp+0",
+	}, "\n")
+	if got := strings.Join(objects, "\n"); got != exp {
+		t.Errorf("unexpected objects:\nexp=%s\ngot=%s", exp, got)
+	}
+
+	var instances []string
+	for _, in := range l.Instances {
+		instances = append(instances, fmt.Sprintf("%s@%d", in, in.Var))
+	}
+	if got, exp := strings.Join(instances, " "), "main.spin@88 gfx@104 gfx.e@104 e[0]@108 e[1]@112"; got != exp {
+		t.Errorf("unexpected instances:\nexp=%s\ngot=%s", exp, got)
+	}
+
+	if l.Image != 88 || l.VarBase != 88 || l.Var != 28 || l.StackBase != 124 || l.Free != 32768-124 {
+		t.Errorf("unexpected totals: %+v", *l)
+	}
+}
@@ -0,0 +1,250 @@
+package compiler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/diagnostic"
+	"github.com/bweir/lame/parser"
+	"github.com/bweir/lame/token"
+)
+
+// A Source is a loaded object file.
+type Source struct {
+	Name   string // the path the file was read from
+	Text   []byte
+	File   *token.File
+	Object *ast.Object
+
+	// Children maps the OBJ declarations of Object to the objects they
+	// name. Declarations whose object could not be loaded are missing.
+	Children map[*ast.ObjectDeclaration]*Source
+}
+
+// A Tree is an object and every object it uses, found by following the
+// OBJ blocks from the top object.
+type Tree struct {
+	Root *Source
+
+	// Sources lists every object once, each after the objects it uses,
+	// so the top object is last. An object used by several objects is
+	// loaded once and shared.
+	Sources []*Source
+
+	// Diagnostics holds the syntax errors of every object, and the OBJ
+	// declarations whose object could not be loaded.
+	Diagnostics diagnostic.List
+}
+
+// Load loads the object name and every object it uses. The error is not
+// nil if the top object could not be read or the options are invalid;
+// other problems are reported in the tree.
+//
+// How the names in OBJ blocks are found depends on the dialect. A name
+// ending in .spin or .lame is a file name in both dialects.
+//
+// In the Spin dialect, names are file names without the extension:
+// "LameGFX" is the first of LameGFX.spin and LameGFX.lame found in the
+// directory of the object that names it, and then in each of the search
+// paths. A dotted name that is not found as a file name is then tried as
+// a module path.
+//
+// In the Lame dialect, names are module paths: "lame.gfx" is lame/gfx.lame
+// or lame/gfx.spin under the project root, which is the directory of the
+// top object, and then under each of the search paths. A module path
+// starting with a dot is relative to the directory of the object that
+// names it, and each further leading dot goes up one directory, so
+// "..util.math" is ../util/math.lame.
+func Load(name string, opts *Options) (*Tree, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	text, err := opts.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &loader{opts: opts, tree: &Tree{}, sources: make(map[string]*Source)}
+	l.root = l.dir(name)
+	l.tree.Root = l.load(name, text)
+	return l.tree, nil
+}
+
+// A loader holds the state of loading a tree.
+type loader struct {
+	opts    *Options
+	root    string // the project root
+	tree    *Tree
+	sources map[string]*Source // by cleaned path
+	stack   []*Source          // the objects being loaded, top object first
+}
+
+// clean returns the canonical form of the path name, so that each file
+// is loaded once.
+func (l *loader) clean(name string) string {
+	if l.opts.FS == nil {
+		return filepath.Clean(name)
+	}
+	return path.Clean(name)
+}
+
+// load parses the object name and loads the objects it uses.
+func (l *loader) load(name string, text []byte) *Source {
+	p := parser.NewFileParser(name, bytes.NewReader(text))
+	object, _ := p.Parse()
+	s := &Source{
+		Name:     name,
+		Text:     text,
+		File:     p.File(),
+		Object:   object,
+		Children: make(map[*ast.ObjectDeclaration]*Source),
+	}
+	l.sources[l.clean(name)] = s
+	l.tree.Diagnostics = append(l.tree.Diagnostics, p.Diagnostics()...)
+
+	l.stack = append(l.stack, s)
+	for _, b := range object.Blocks {
+		if b, ok := b.(*ast.ObjBlock); ok {
+			for _, d := range b.Declarations {
+				if d, ok := d.(*ast.ObjectDeclaration); ok {
+					if child := l.child(s, d); child != nil {
+						s.Children[d] = child
+					}
+				}
+			}
+		}
+	}
+	l.stack = l.stack[:len(l.stack)-1]
+
+	l.tree.Sources = append(l.tree.Sources, s)
+	return s
+}
+
+// child returns the object named by the declaration d in s, loading it if
+// it has not been loaded. It returns nil after reporting the problem if
+// the object cannot be loaded.
+func (l *loader) child(s *Source, d *ast.ObjectDeclaration) *Source {
+	errorf := func(code, format string, args ...interface{}) {
+		l.tree.Diagnostics.Add(diagnostic.Error, s.File.Position(d.Pos()), code, format, args...)
+	}
+
+	paths, err := l.candidates(s.Name, d.Path)
+	if err != nil {
+		errorf("bad-module-path", "%s", err)
+		return nil
+	}
+	for _, name := range paths {
+		if child, ok := l.sources[l.clean(name)]; ok {
+			for i, t := range l.stack {
+				if t == child {
+					var names []string
+					for _, t := range l.stack[i:] {
+						names = append(names, t.Name)
+					}
+					errorf("object-cycle", "object cycle: %s -> %s", strings.Join(names, " -> "), child.Name)
+					return nil
+				}
+			}
+			return child
+		}
+
+		text, err := l.opts.readFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			errorf("module-not-found", "%s", err)
+			return nil
+		}
+		return l.load(name, text)
+	}
+	errorf("module-not-found", "module %q not found; tried %s", d.Path, strings.Join(paths, ", "))
+	return nil
+}
+
+// candidates returns the paths where the object called name in the object
+// from may be, in the order they are tried.
+func (l *loader) candidates(from, name string) ([]string, error) {
+	if ext := path.Ext(name); ext == ".spin" || ext == ".lame" {
+		return l.files(name, append([]string{l.dir(from)}, l.opts.SearchPaths...), ""), nil
+	}
+
+	if l.opts.Dialect == Spin {
+		paths := l.files(name, append([]string{l.dir(from)}, l.opts.SearchPaths...), ".spin", ".lame")
+		if strings.Contains(name, ".") && !strings.HasPrefix(name, ".") {
+			if file, _, err := modulePath(name); err == nil {
+				paths = append(paths, l.files(file, append([]string{l.root}, l.opts.SearchPaths...), ".spin", ".lame")...)
+			}
+		}
+		return paths, nil
+	}
+
+	file, up, err := modulePath(name)
+	if err != nil {
+		return nil, err
+	}
+	dirs := append([]string{l.root}, l.opts.SearchPaths...)
+	if up > 0 {
+		dir := l.dir(from)
+		for ; up > 1; up-- {
+			dir = l.joinDir(dir, "..")
+		}
+		dirs = []string{dir}
+	}
+	return l.files(file, dirs, ".lame", ".spin"), nil
+}
+
+// modulePath returns the slash-separated file name, without extension, of
+// the module path name, and the number of leading dots.
+func modulePath(name string) (file string, up int, err error) {
+	for up < len(name) && name[up] == '.' {
+		up++
+	}
+	parts := strings.Split(name[up:], ".")
+	for _, part := range parts {
+		if part == "" || strings.ContainsAny(part, `/\`) {
+			return "", 0, fmt.Errorf("invalid module path %q", name)
+		}
+	}
+	return strings.Join(parts, "/"), up, nil
+}
+
+// files returns the path of the file name with each of the extensions in
+// each of the directories dirs.
+func (l *loader) files(name string, dirs []string, extensions ...string) []string {
+	var paths []string
+	for _, dir := range dirs {
+		for _, ext := range extensions {
+			paths = append(paths, l.joinDir(dir, name+ext))
+		}
+	}
+	return paths
+}
+
+// dir returns the directory of the path name.
+func (l *loader) dir(name string) string {
+	if l.opts.FS == nil {
+		return filepath.Dir(name)
+	}
+	return path.Dir(name)
+}
+
+// joinDir joins the slash-separated path name to the directory dir,
+// unless name is absolute.
+func (l *loader) joinDir(dir, name string) string {
+	if l.opts.FS == nil {
+		if name = filepath.FromSlash(name); filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	return path.Join(dir, name)
+}
@@ -0,0 +1,202 @@
+package compiler_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/compiler"
+)
+
+// Ensure objects are found next to their parent, then in the search
+// paths, and loaded once.
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/main.spin":      {Data: []byte("OBJ\n  gfx : \"LameGFX\"\n  txt : \"LameText\"\n  audio : \"LameAudio.lame\"")},
+		"src/LameText.spin":  {Data: []byte("OBJ\n  gfx : \"LameGFX\"")},
+		"src/LameAudio.lame": {Data: []byte("DAT\nlong 0")},
+		"lib/LameGFX.lame":   {Data: []byte("DAT\nlong 0")},
+		"lib/LameText.spin":  {Data: []byte("DAT\nlong 0")},
+		"more/LameGFX.spin":  {Data: []byte("DAT\nlong 0")},
+	}
+	opts := &compiler.Options{FS: fsys, SearchPaths: []string{"lib", "more"}}
+
+	tree, err := compiler.Load("src/main.spin", opts)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := tree.Diagnostics.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, s := range tree.Sources {
+		names = append(names, s.Name)
+	}
+	if got, exp := strings.Join(names, " "), "lib/LameGFX.lame src/LameText.spin src/LameAudio.lame src/main.spin"; got != exp {
+		t.Fatalf("unexpected sources:\nexp=%s\ngot=%s", exp, got)
+	}
+	if tree.Root != tree.Sources[3] {
+		t.Fatal("unexpected root")
+	}
+
+	// Both uses of LameGFX share one object.
+	gfx := tree.Root.Children[declaration(tree.Root, "gfx")]
+	txt := tree.Root.Children[declaration(tree.Root, "txt")]
+	if gfx == nil || txt == nil || txt.Children[declaration(txt, "gfx")] != gfx {
+		t.Fatal("LameGFX was not shared")
+	}
+}
+
+// Ensure dotted module paths are found under the project root and the
+// search paths, or relative to the object that names them.
+func TestLoad_Modules(t *testing.T) {
+	fsys := fstest.MapFS{
+		"game/main.lame":      {Data: []byte("OBJ\n  gfx : \"lame.gfx\"\n  audio : \"lame.audio\"\n  util : \".util\"\n  extra : \"Other.spin\"")},
+		"game/lame/gfx.lame":  {Data: []byte("OBJ\n  m : \"..math\"\n  u : \"util\"")},
+		"game/util.spin":      {Data: []byte("DAT\nlong 0")},
+		"game/math.lame":      {Data: []byte("DAT\nlong 0")},
+		"game/Other.spin":     {Data: []byte("DAT\nlong 0")},
+		"lib/lame/audio.lame": {Data: []byte("DAT\nlong 0")},
+		"lib/lame/audio.spin": {Data: []byte("DAT\nlong 0")},
+		"lib/LameGFX.spin":    {Data: []byte("DAT\nlong 0")},
+		"spin/main.spin":      {Data: []byte("OBJ\n  gfx : \"LameGFX\"\n  audio : \"lame.audio\"")},
+	}
+
+	for _, tt := range []struct {
+		name    string
+		dialect compiler.Dialect
+		sources string
+	}{
+		{name: "game/main.lame", dialect: compiler.Lame, sources: "game/math.lame game/util.spin game/lame/gfx.lame lib/lame/audio.lame game/Other.spin game/main.lame"},
+
+		// Plain names are file names in the Spin dialect, and dotted names
+		// fall back to module paths.
+		{name: "spin/main.spin", dialect: compiler.Spin, sources: "lib/LameGFX.spin lib/lame/audio.spin spin/main.spin"},
+	} {
+		tree, err := compiler.Load(tt.name, &compiler.Options{FS: fsys, Dialect: tt.dialect, SearchPaths: []string{"lib"}})
+		if err != nil {
+			t.Fatal(err)
+		} else if err := tree.Diagnostics.Err(); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		var names []string
+		for _, s := range tree.Sources {
+			names = append(names, s.Name)
+		}
+		if got := strings.Join(names, " "); got != tt.sources {
+			t.Errorf("%s: unexpected sources:\nexp=%s\ngot=%s", tt.name, tt.sources, got)
+		}
+	}
+}
+
+// Ensure missing objects and cycles are reported at the OBJ declaration.
+func TestLoad_Errors(t *testing.T) {
+	var tests = []struct {
+		files   map[string]string
+		dialect compiler.Dialect
+		diag    string
+	}{
+		{
+			files: map[string]string{"main.spin": "OBJ\n  gfx : \"LameGFX\""},
+			diag:  `main.spin:2:3: error: module "LameGFX" not found; tried LameGFX.spin, LameGFX.lame, lib/LameGFX.spin, lib/LameGFX.lame [module-not-found]`,
+		},
+		{
+			files: map[string]string{"main.spin": "OBJ\n  a : \"a\"", "a.spin": "OBJ\n  b : \"b\"", "b.spin": "OBJ\n  a : \"a\""},
+			diag:  `b.spin:2:3: error: object cycle: a.spin -> b.spin -> a.spin [object-cycle]`,
+		},
+		{
+			files: map[string]string{"main.spin": "OBJ\n  m : \"main\""},
+			diag:  `main.spin:2:3: error: object cycle: main.spin -> main.spin [object-cycle]`,
+		},
+		{
+			files: map[string]string{"main.spin": "OBJ\n  a : \"a\"", "a.spin": "PUB main\n  x :="},
+			diag:  `a.spin:2:7: error: found "", expected expression [unexpected-token]`,
+		},
+		{
+			files:   map[string]string{"main.spin": "OBJ\n  gfx : \"lame.gfx\""},
+			dialect: compiler.Lame,
+			diag:    `main.spin:2:3: error: module "lame.gfx" not found; tried lame/gfx.lame, lame/gfx.spin, lib/lame/gfx.lame, lib/lame/gfx.spin [module-not-found]`,
+		},
+		{
+			files:   map[string]string{"main.spin": "OBJ\n  gfx : \"lame..gfx\""},
+			dialect: compiler.Lame,
+			diag:    `main.spin:2:3: error: invalid module path "lame..gfx" [bad-module-path]`,
+		},
+		{
+			files: map[string]string{"main.spin": "OBJ\n  gfx : \"lame.gfx\""},
+			diag:  `main.spin:2:3: error: module "lame.gfx" not found; tried lame.gfx.spin, lame.gfx.lame, lib/lame.gfx.spin, lib/lame.gfx.lame, lame/gfx.spin, lame/gfx.lame, lib/lame/gfx.spin, lib/lame/gfx.lame [module-not-found]`,
+		},
+	}
+
+	for i, tt := range tests {
+		fsys := fstest.MapFS{}
+		for name, src := range tt.files {
+			fsys[name] = &fstest.MapFile{Data: []byte(src)}
+		}
+		tree, err := compiler.Load("main.spin", &compiler.Options{FS: fsys, Dialect: tt.dialect, SearchPaths: []string{"lib"}})
+		if err != nil {
+			t.Fatalf("%d. %s", i, err)
+		}
+		if got := tree.Diagnostics.Error(); got != tt.diag {
+			t.Errorf("%d. mismatch:\nexp=%s\ngot=%s", i, tt.diag, got)
+		}
+	}
+}
+
+// Ensure every object in the tree is checked.
+func TestCheck_Tree(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.spin":    {Data: []byte("OBJ\n  gfx : \"LameGFX\"\nPUB main\n  gfx.Sprite")},
+		"LameGFX.spin": {Data: []byte("PUB Sprite\n  undefined := 1")},
+	}
+	r, err := compiler.Check("main.spin", &compiler.Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := r.Diagnostics.Error(), `LameGFX.spin:2:3: error: undefined: undefined [undefined]`; got != exp {
+		t.Errorf("mismatch:\nexp=%s\ngot=%s", exp, got)
+	}
+	if len(r.Tree.Sources) != 2 {
+		t.Errorf("unexpected tree: %d sources", len(r.Tree.Sources))
+	}
+}
+
+// Ensure calls to other objects name a PUB method of the object, with the
+// right number of arguments.
+func TestCheck_Calls(t *testing.T) {
+	var tests = []struct {
+		src  string
+		diag string
+	}{
+		{src: "PUB main\n  gfx.Sprite(1, 2, 3, 4 - 1)\n  arr[1].Sprite(1, 2, 3, 4)\n  return gfx#SX + gfx.Blit", diag: "no errors"},
+		{src: "PUB main\n  gfx.Sprit(1, 2, 3, 4)", diag: `main.spin:2:7: error: undefined: gfx.Sprit [undefined]`},
+		{src: "PUB main\n  gfx.Sprite(1, 2, 3)", diag: `main.spin:2:3: error: not enough arguments in call to gfx.Sprite: have 3, want 4 [arity]`},
+		{src: "PUB main\n  gfx.Blit(1)", diag: `main.spin:2:3: error: too many arguments in call to gfx.Blit: have 1, want 0 [arity]`},
+		{src: "PUB main\n  gfx.Hidden", diag: `main.spin:2:7: error: gfx.Hidden is private to "gfx" [private]`},
+		{src: "PUB main : r\n  r := gfx.Sprite", diag: `main.spin:2:8: error: gfx.Sprite takes 4 arguments but is used without parentheses [method-value]`},
+		{src: "PUB main\n  arr.Blit", diag: `main.spin:2:3: error: object array arr used without an index [missing-index]`},
+		{src: "PUB main\n  gfx[0].Blit", diag: `main.spin:2:3: error: object gfx is not an array [not-array]`},
+		{src: "PUB main : r\n  r := gfx#Sprite", diag: `main.spin:2:12: error: undefined: gfx#Sprite [undefined]`},
+	}
+
+	for i, tt := range tests {
+		r := check(t, map[string]string{
+			"main.spin": tt.src + "\nOBJ\n  gfx : \"gfx\"\n  arr[2] : \"gfx\"",
+			"gfx.spin":  "CON\n  SX = 1\nPUB Sprite(source, x, y, frame)\nPUB Blit\nPRI Hidden",
+		})
+		if got := r.Diagnostics.Error(); got != tt.diag {
+			t.Errorf("%d. %q mismatch:\nexp=%s\ngot=%s", i, tt.src, tt.diag, got)
+		}
+	}
+}
+
+// declaration returns the OBJ declaration called name in s.
+func declaration(s *compiler.Source, name string) *ast.ObjectDeclaration {
+	for d := range s.Children {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
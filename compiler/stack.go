@@ -0,0 +1,416 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/checker"
+	"github.com/bweir/lame/diagnostic"
+	"github.com/bweir/lame/token"
+)
+
+// Stack holds the estimated stack use of the methods of a program, and of
+// the cogs it starts.
+//
+// A call pushes a frame of two longs of return information, then RESULT,
+// the parameters and the locals of the method, and its body pushes the
+// temporary values of the expressions it evaluates. A method uses its
+// frame, and the most its body pushes, including the methods it calls.
+type Stack struct {
+	Methods []*MethodStack // every method, in the order of Tree.Sources
+	Cogs    []*CogStack    // every cog started by COGNEW or COGINIT
+}
+
+// A MethodStack is the estimated worst-case stack use of a method.
+type MethodStack struct {
+	Source *Source
+	Method ast.Block // *ast.PubBlock or *ast.PriBlock
+	Name   string
+	Frame  int // bytes of the call frame
+	Bytes  int // bytes of the frame and the most the body pushes
+
+	// Bounded is false if the method is recursive, or calls a recursive
+	// method; then Bytes leaves out the calls that recurse.
+	Bounded   bool
+	Recursive bool
+}
+
+// A CogStack is a cog started in a Spin method by COGNEW or COGINIT, with
+// the stack it is given.
+type CogStack struct {
+	Source *Source
+	Call   *ast.CallExpression
+	Method *MethodStack
+
+	// Stack is the VAR array given as the stack, such as stack in
+	// @stack, and Bytes its size, or nil and 0 if it is not known.
+	Stack *ast.VariableDeclaration
+	Bytes int
+}
+
+// A stacker estimates the stack use of the methods of a program.
+type stacker struct {
+	units   map[*Source]*unit
+	owners  map[ast.Block]*unit
+	methods map[ast.Block]*MethodStack
+	path    []ast.Block // the methods being estimated, for recursion
+	stack   *Stack
+	diags   diagnostic.List
+}
+
+// stack estimates the stack use of the methods of tree, which must have
+// been laid out as l, and reports recursion and stacks that are too
+// small.
+func stack(tree *Tree, units map[*Source]*unit, l *Layout) (*Stack, diagnostic.List) {
+	st := &stacker{
+		units:   units,
+		owners:  make(map[ast.Block]*unit),
+		methods: make(map[ast.Block]*MethodStack),
+		stack:   &Stack{},
+	}
+	for _, s := range tree.Sources {
+		for _, b := range s.Object.Blocks {
+			switch b.(type) {
+			case *ast.PubBlock, *ast.PriBlock:
+				st.owners[b] = units[s]
+			}
+		}
+	}
+	for _, s := range tree.Sources {
+		for _, b := range s.Object.Blocks {
+			if st.owners[b] != nil {
+				st.stack.Methods = append(st.stack.Methods, st.method(b))
+			}
+		}
+	}
+	for _, s := range tree.Sources {
+		st.cogs(units[s])
+	}
+
+	// The first PUB method of the top object runs in the first cog, whose
+	// stack is the free hub RAM.
+	for _, b := range tree.Root.Object.Blocks {
+		if b, ok := b.(*ast.PubBlock); ok {
+			if m := st.methods[b]; m.Bytes > l.Free {
+				st.warnf(tree.Root, b.Pos(), "stack-size", "%s needs about %d bytes of stack, but only %d bytes of hub RAM are free", m.Name, m.Bytes, l.Free)
+			}
+			break
+		}
+	}
+	st.diags.Sort()
+	return st.stack, st.diags
+}
+
+// warnf reports a warning at pos in the object s.
+func (st *stacker) warnf(s *Source, pos token.Pos, code, format string, args ...interface{}) {
+	st.diags.Add(diagnostic.Warning, s.File.Position(pos), code, format, args...)
+}
+
+// method returns the stack use of the method m.
+func (st *stacker) method(m ast.Block) *MethodStack {
+	if ms, ok := st.methods[m]; ok {
+		for _, b := range st.path {
+			if b == m && !ms.Recursive {
+				st.recursive(m)
+			}
+		}
+		return ms
+	}
+
+	u := st.owners[m]
+	ms := &MethodStack{Source: u.source, Method: m, Name: u.info.Defs[m].Name, Bounded: true}
+	st.methods[m] = ms
+	st.path = append(st.path, m)
+
+	var params []*ast.Identifier
+	var locals []*ast.LocalDeclaration
+	var body []ast.Statement
+	switch m := m.(type) {
+	case *ast.PubBlock:
+		params, locals, body = m.Parameters, m.Locals, m.Body
+	case *ast.PriBlock:
+		params, locals, body = m.Parameters, m.Locals, m.Body
+	}
+	longs := 1 + len(params)
+	for _, l := range locals {
+		longs += u.count(l.Count)
+	}
+	ms.Frame = 8 + 4*longs
+	ms.Bytes = ms.Frame + st.statements(u, ms, body)
+
+	st.path = st.path[:len(st.path)-1]
+	return ms
+}
+
+// recursive marks the methods on the path from m, which calls itself
+// through them, as recursive, and reports m.
+func (st *stacker) recursive(m ast.Block) {
+	i := len(st.path) - 1
+	for st.path[i] != m {
+		i--
+	}
+	var names []string
+	for _, b := range st.path[i:] {
+		st.methods[b].Recursive = true
+		st.methods[b].Bounded = false
+		names = append(names, st.methods[b].Name)
+	}
+	names = append(names, st.methods[m].Name)
+
+	ms := st.methods[m]
+	st.warnf(ms.Source, m.Pos(), "recursion", "%s is recursive (%s), so its stack use has no bound", ms.Name, strings.Join(names, " -> "))
+}
+
+// statements returns the most bytes that list pushes, in the method ms
+// of u.
+func (st *stacker) statements(u *unit, ms *MethodStack, list []ast.Statement) int {
+	most := 0
+	for _, s := range list {
+		most = maximum(most, st.statement(u, ms, s))
+	}
+	return most
+}
+
+func (st *stacker) statement(u *unit, ms *MethodStack, s ast.Statement) int {
+	x := func(x ast.Expression) int { return st.expr(u, ms, x) }
+	body := func(list []ast.Statement) int { return st.statements(u, ms, list) }
+
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		return x(s.X)
+	case *ast.IfStatement:
+		n := maximum(x(s.Condition), body(s.Body))
+		if s.Else != nil {
+			n = maximum(n, st.statement(u, ms, s.Else))
+		}
+		return n
+	case *ast.ElseStatement:
+		return body(s.Body)
+	case *ast.RepeatStatement:
+		// A count stays on the stack while the loop runs.
+		if s.Count != nil {
+			return maximum(x(s.Count), 4+body(s.Body))
+		}
+		return body(s.Body)
+	case *ast.RepeatRangeStatement:
+		return maximum(x(s.Variable), x(s.Start), x(s.Stop), x(s.Step), body(s.Body))
+	case *ast.RepeatWhileStatement:
+		return maximum(x(s.Condition), body(s.Body))
+	case *ast.CaseStatement:
+		// The value and the address of the end of the case stay on the
+		// stack while it runs.
+		n := x(s.Value)
+		for _, arm := range s.Arms {
+			for _, m := range arm.Matches {
+				n = maximum(n, 8+x(m))
+			}
+			n = maximum(n, 8+body(arm.Body))
+		}
+		return n
+	case *ast.ReturnStatement:
+		return x(s.Value)
+	case *ast.AbortStatement:
+		return x(s.Value)
+	}
+	return 0
+}
+
+// expr returns the most bytes that pushing the value of x pushes, in the
+// method ms of u.
+func (st *stacker) expr(u *unit, ms *MethodStack, x ast.Expression) int {
+	e := func(x ast.Expression) int { return st.expr(u, ms, x) }
+
+	switch x := x.(type) {
+	case nil:
+		return 0
+	case *ast.ParenExpression:
+		return e(x.X)
+	case *ast.ConstantExpression:
+		return 4
+	case *ast.UnaryExpression:
+		return maximum(4, e(x.X))
+	case *ast.PostfixExpression:
+		return maximum(4, e(x.X))
+	case *ast.BinaryExpression:
+		return maximum(e(x.X), 4+e(x.Y))
+	case *ast.AssignmentExpression:
+		// Only the index of an array or memory target is pushed.
+		if _, ok := x.Target.(*ast.Identifier); ok {
+			return e(x.Value)
+		}
+		return maximum(e(x.Value), 4+e(x.Target))
+	case *ast.MemoryExpression:
+		return maximum(e(x.Base), 4+e(x.Index))
+	case *ast.IndexExpression:
+		return maximum(e(x.X), 4+e(x.Index))
+	case *ast.RangeExpression:
+		return maximum(e(x.Low), 4+e(x.High))
+	case *ast.LookupExpression:
+		n := e(x.Index)
+		for _, y := range x.List {
+			n = maximum(n, 8+e(y))
+		}
+		return n
+	case *ast.StringExpression:
+		return 4
+	case *ast.Identifier:
+		if m := st.callee(u, x); m != nil {
+			return st.call(ms, m)
+		}
+		return 4
+	case *ast.SelectorExpression:
+		if m := st.callee(u, x.Name); m != nil {
+			return st.call(ms, m)
+		}
+		return 4
+	case *ast.CallExpression:
+		return st.callExpr(u, ms, x)
+	}
+	return 4
+}
+
+// callExpr returns the most bytes that the call x pushes, in the method
+// ms of u.
+func (st *stacker) callExpr(u *unit, ms *MethodStack, x *ast.CallExpression) int {
+	var m ast.Block
+	switch f := x.Function.(type) {
+	case *ast.Identifier:
+		m = st.callee(u, f)
+	case *ast.SelectorExpression:
+		m = st.callee(u, f.Name)
+	}
+
+	// The arguments are pushed after the frame of a method, or on their
+	// own for a built-in method. A method started in a new cog by COGNEW
+	// or COGINIT runs on that cog's stack, so only its arguments count.
+	var args []ast.Expression
+	for i, arg := range x.Arguments {
+		if u.cogEntry(x, i) == nil {
+			args = append(args, arg)
+		} else if call, ok := arg.(*ast.CallExpression); ok {
+			args = append(args, call.Arguments...)
+		}
+	}
+	base := 0
+	if m != nil {
+		base = 12
+	}
+	n := 4
+	for i, arg := range args {
+		n = maximum(n, base+4*i+st.expr(u, ms, arg))
+	}
+	if m != nil {
+		n = maximum(n, st.call(ms, m))
+	}
+	return n
+}
+
+// call returns the bytes that calling m from the method ms uses.
+func (st *stacker) call(ms *MethodStack, m ast.Block) int {
+	callee := st.method(m)
+	if !callee.Bounded {
+		ms.Bounded = false
+	}
+	return callee.Bytes
+}
+
+// callee returns the Spin method named by x, or nil.
+func (st *stacker) callee(u *unit, x *ast.Identifier) ast.Block {
+	if sym := u.info.Uses[x]; sym != nil && sym.Kind == checker.Method {
+		return sym.Decl.(ast.Block)
+	}
+	return nil
+}
+
+// cogEntry returns the name of the Spin method that argument i of x
+// starts in a new cog, as in COGNEW(method(args), @stack) or
+// COGNEW(method, @stack), or nil.
+func (u *unit) cogEntry(x *ast.CallExpression, i int) *ast.Identifier {
+	f, ok := x.Function.(*ast.Identifier)
+	if !ok || u.info.Uses[f] == nil || u.info.Uses[f].Decl != nil {
+		return nil
+	}
+	switch {
+	case strings.EqualFold(f.Name, "COGNEW") && i == 0:
+	case strings.EqualFold(f.Name, "COGINIT") && i == 1:
+	default:
+		return nil
+	}
+	arg := x.Arguments[i]
+	if call, ok := arg.(*ast.CallExpression); ok {
+		arg = call.Function
+	}
+	if id, ok := arg.(*ast.Identifier); ok {
+		if sym := u.info.Uses[id]; sym != nil && sym.Kind == checker.Method {
+			return id
+		}
+	}
+	return nil
+}
+
+// cogs adds the cogs started in the methods of u, and reports those
+// whose stack is too small.
+func (st *stacker) cogs(u *unit) {
+	ast.Inspect(u.object, func(n ast.Node) bool {
+		x, ok := n.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		for i := range x.Arguments {
+			id := u.cogEntry(x, i)
+			if id == nil {
+				continue
+			}
+			m := st.methods[u.info.Uses[id].Decl.(ast.Block)]
+			c := &CogStack{Source: u.source, Call: x, Method: m}
+			if i+1 < len(x.Arguments) {
+				c.Stack, c.Bytes = u.stackArray(x.Arguments[i+1])
+			}
+			st.stack.Cogs = append(st.stack.Cogs, c)
+
+			switch {
+			case c.Stack == nil:
+			case !m.Bounded:
+				st.warnf(u.source, x.Pos(), "stack-size", "%s has %d bytes, but %s may need more, as its stack use has no bound", c.Stack.Name, c.Bytes, m.Name)
+			case m.Bytes > c.Bytes:
+				st.warnf(u.source, x.Pos(), "stack-size", "%s has %d bytes, but %s needs about %d", c.Stack.Name, c.Bytes, m.Name, m.Bytes)
+			}
+		}
+		return true
+	})
+}
+
+// stackArray returns the VAR array at whose start x points, as in @stack
+// or @stack[0], and its size in bytes.
+func (u *unit) stackArray(x ast.Expression) (*ast.VariableDeclaration, int) {
+	at, ok := x.(*ast.UnaryExpression)
+	if !ok || at.Operator != token.AT {
+		return nil, 0
+	}
+	y := at.X
+	if ix, ok := y.(*ast.IndexExpression); ok {
+		if v, ok := u.known(ix.Index); !ok || v.Bits != 0 {
+			return nil, 0
+		}
+		y = ix.X
+	}
+	id, ok := y.(*ast.Identifier)
+	if !ok || u.info.Uses[id] == nil {
+		return nil, 0
+	}
+	d, ok := u.info.Uses[id].Decl.(*ast.VariableDeclaration)
+	if !ok {
+		return nil, 0
+	}
+	return d, sizes[d.Size] * u.count(d.Count)
+}
+
+// maximum returns the largest of n and more.
+func maximum(n int, more ...int) int {
+	for _, m := range more {
+		if m > n {
+			n = m
+		}
+	}
+	return n
+}
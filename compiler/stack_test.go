@@ -0,0 +1,82 @@
+package compiler_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Ensure stack use counts frames, temporaries and calls, across objects,
+// and that cog stacks and recursion are reported.
+func TestCheck_Stack(t *testing.T) {
+	r := check(t, map[string]string{
+		"main.spin": "VAR\n  long stack[8]\n  long big[64]\nOBJ\n  gfx : \"gfx\"\nPUB main | i\n  cognew(loop(1, 2), @stack)\n  coginit(3, fib(10), @big[0])\n  repeat i from 0 to 3\n    i := fib(i) + gfx.Blit(i, 2)\nPRI loop(a, b) | buf[4]\n  repeat\n    buf[a] := gfx.Blit(a, b * (a + 1))\nPRI fib(n)\n  if n < 2\n    return n\n  return fib(n - 1) + fib(n - 2)",
+		"gfx.spin":  "PUB Blit(x, y)\n  return x * (y + x)",
+	})
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var methods []string
+	for _, m := range r.Stack.Methods {
+		methods = append(methods, fmt.Sprintf("%s frame=%d bytes=%d bounded=%t recursive=%t", m.Name, m.Frame, m.Bytes, m.Bounded, m.Recursive))
+	}
+	exp := strings.Join([]string{
+		"Blit frame=20 bytes=32 bounded=true recursive=false",
+		"main frame=16 bytes=56 bounded=false recursive=false",
+		"loop frame=36 bytes=68 bounded=true recursive=false",
+		"fib frame=16 bytes=40 bounded=false recursive=true",
+	}, "\n")
+	if got := strings.Join(methods, "\n"); got != exp {
+		t.Errorf("unexpected methods:\nexp=%s\ngot=%s", exp, got)
+	}
+
+	var cogs []string
+	for _, c := range r.Stack.Cogs {
+		cogs = append(cogs, fmt.Sprintf("%s %s=%d", c.Method.Name, c.Stack.Name, c.Bytes))
+	}
+	if got, exp := strings.Join(cogs, " "), "loop stack=32 fib big=256"; got != exp {
+		t.Errorf("unexpected cogs:\nexp=%s\ngot=%s", exp, got)
+	}
+
+	exp = strings.Join([]string{
+		"main.spin:7:3: warning: stack has 32 bytes, but loop needs about 68 [stack-size]",
+		"main.spin:8:3: warning: big has 256 bytes, but fib may need more, as its stack use has no bound [stack-size]",
+		"main.spin:14:1: warning: fib is recursive (fib -> fib), so its stack use has no bound [recursion]",
+	}, "\n")
+	if got := r.Diagnostics.Error(); got != exp {
+		t.Errorf("unexpected diagnostics:\nexp=%s\ngot=%s", exp, got)
+	}
+}
+
+// Ensure a method started by name, as in cognew(Method, @stack), runs on
+// its own stack rather than that of the method that starts it.
+func TestCheck_Stack_CogName(t *testing.T) {
+	r := check(t, map[string]string{
+		"main.spin": "VAR\n  long stack[4]\nPUB Main\n  cognew(Worker, @stack)\nPRI Worker | buf[8]\n  repeat\n    buf[0] := buf[1] + 1",
+	})
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var methods []string
+	for _, m := range r.Stack.Methods {
+		methods = append(methods, fmt.Sprintf("%s=%d", m.Name, m.Bytes))
+	}
+	if got, exp := strings.Join(methods, " "), "Main=16 Worker=56"; got != exp {
+		t.Errorf("unexpected methods:\nexp=%s\ngot=%s", exp, got)
+	}
+
+	var cogs []string
+	for _, c := range r.Stack.Cogs {
+		cogs = append(cogs, fmt.Sprintf("%s %s=%d", c.Method.Name, c.Stack.Name, c.Bytes))
+	}
+	if got, exp := strings.Join(cogs, " "), "Worker stack=16"; got != exp {
+		t.Errorf("unexpected cogs:\nexp=%s\ngot=%s", exp, got)
+	}
+
+	exp := "main.spin:4:3: warning: stack has 16 bytes, but Worker needs about 56 [stack-size]"
+	if got := r.Diagnostics.Error(); got != exp {
+		t.Errorf("unexpected diagnostics:\nexp=%s\ngot=%s", exp, got)
+	}
+}
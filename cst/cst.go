@@ -0,0 +1,204 @@
+// Package cst defines a concrete syntax tree for Lame objects.
+//
+// Unlike the AST, a concrete syntax tree keeps every token of the source,
+// including spaces, newlines, indents, dedents and comments, so that the
+// source can be written back byte for byte. Trees mirror the AST: each
+// Tree holds the tokens and subtrees of one AST node. Tools can edit the
+// text of tokens or replace children, and the rest of the source is kept
+// as it was.
+//
+// Trees are built by parser.ParseCST, and parser.NewTreeParser turns a
+// tree back into an AST.
+package cst
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+// A Node is a *Tree or a *Token.
+type Node interface {
+	Pos() token.Pos
+	End() token.Pos
+}
+
+// A Token is a leaf of the tree. Text is the token as it appears in the
+// source, which differs from Literal for strings and comments. INDENT
+// and DEDENT tokens are empty; the indentation is a SPACE token.
+type Token struct {
+	From, To token.Pos
+	Type     token.Type
+	Literal  string
+	Text     string
+}
+
+func (t *Token) Pos() token.Pos { return t.From }
+func (t *Token) End() token.Pos { return t.To }
+
+// A Tree is the concrete syntax of an AST node. Children holds the tokens
+// of the node, in source order, with the trees of its child nodes in
+// their place.
+//
+// Blocks extend to the start of the next block, so that the blank lines
+// and comments between blocks belong to the block above. Text before the
+// first block and the final EOF token belong to the tree of the object.
+//
+// Node, From and To are not updated when a tree is edited.
+type Tree struct {
+	Node     ast.Node
+	From, To token.Pos
+	Children []Node
+}
+
+func (t *Tree) Pos() token.Pos { return t.From }
+func (t *Tree) End() token.Pos { return t.To }
+
+// Tokens returns the tokens of the tree in source order.
+func (t *Tree) Tokens() []*Token {
+	var list []*Token
+	Inspect(t, func(n Node) bool {
+		if tok, ok := n.(*Token); ok {
+			list = append(list, tok)
+		}
+		return true
+	})
+	return list
+}
+
+// Text returns the source of the tree.
+func (t *Tree) Text() string {
+	var b strings.Builder
+	_, _ = t.WriteTo(&b)
+	return b.String()
+}
+
+// WriteTo writes the source of the tree to w.
+func (t *Tree) WriteTo(w io.Writer) (n int64, err error) {
+	for _, tok := range t.Tokens() {
+		m, err := io.WriteString(w, tok.Text)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Find returns the tree of the AST node n, or nil if there is none.
+func (t *Tree) Find(n ast.Node) (found *Tree) {
+	Inspect(t, func(node Node) bool {
+		if found != nil {
+			return false
+		}
+		if tree, ok := node.(*Tree); ok && tree.Node == n {
+			found = tree
+		}
+		return found == nil
+	})
+	return
+}
+
+// Inspect traverses a tree in depth-first order, in source order. It
+// calls f(node) for each node; if f returns true, Inspect visits the
+// children of node.
+func Inspect(node Node, f func(Node) bool) {
+	if !f(node) {
+		return
+	}
+	if t, ok := node.(*Tree); ok {
+		for _, c := range t.Children {
+			Inspect(c, f)
+		}
+	}
+}
+
+// New returns the tree of object, built from the tokens of its source.
+// The tokens must cover the source without gaps, as the scanner returns
+// them, and src must hold the source.
+func New(object *ast.Object, tokens []token.Token, file *token.File, src []byte) *Tree {
+	leaves := make([]*Token, len(tokens))
+	for i, tok := range tokens {
+		leaves[i] = &Token{
+			From:    tok.Pos,
+			To:      tok.End,
+			Type:    tok.Type,
+			Literal: tok.Literal,
+			Text:    string(src[file.Offset(tok.Pos):file.Offset(tok.End)]),
+		}
+	}
+
+	root := &Tree{Node: object, From: file.Pos(0), To: file.Pos(len(src))}
+	b := builder{tokens: leaves}
+	for i, block := range object.Blocks {
+		to := root.To
+		if i+1 < len(object.Blocks) {
+			to = object.Blocks[i+1].Pos()
+		}
+		root.Children = append(root.Children, b.leading(block.Pos())...)
+		root.Children = append(root.Children, b.tree(block, block.Pos(), to))
+	}
+	root.Children = append(root.Children, b.take(len(b.tokens))...)
+	return root
+}
+
+type builder struct {
+	tokens []*Token // tokens not yet placed
+}
+
+// leading takes the tokens that end before pos.
+func (b *builder) leading(pos token.Pos) []Node {
+	i := 0
+	for i < len(b.tokens) && b.tokens[i].To <= pos {
+		i++
+	}
+	return b.take(i)
+}
+
+// inside takes the tokens that start before end.
+func (b *builder) inside(end token.Pos) []Node {
+	i := 0
+	for i < len(b.tokens) && b.tokens[i].From < end {
+		i++
+	}
+	return b.take(i)
+}
+
+func (b *builder) take(i int) []Node {
+	list := make([]Node, i)
+	for j, tok := range b.tokens[:i] {
+		list[j] = tok
+	}
+	b.tokens = b.tokens[i:]
+	return list
+}
+
+// tree builds the tree of n, which covers from..to.
+func (b *builder) tree(n ast.Node, from, to token.Pos) *Tree {
+	t := &Tree{Node: n, From: from, To: to}
+	for _, c := range children(n) {
+		t.Children = append(t.Children, b.leading(c.Pos())...)
+		t.Children = append(t.Children, b.tree(c, c.Pos(), c.End()))
+	}
+	t.Children = append(t.Children, b.inside(to)...)
+	return t
+}
+
+// children returns the child nodes of n with a position, in source order.
+func children(n ast.Node) []ast.Node {
+	var list []ast.Node
+	ast.Inspect(n, func(c ast.Node) bool {
+		if c == n {
+			return true
+		}
+		if c != nil && c.Pos().IsValid() {
+			list = append(list, c)
+		}
+		return false
+	})
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Pos() < list[j].Pos() })
+	return list
+}
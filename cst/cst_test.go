@@ -0,0 +1,130 @@
+package cst_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/cst"
+	"github.com/bweir/lame/parser"
+	"github.com/bweir/lame/token"
+)
+
+// Ensure the tree of every test object writes back its source byte for
+// byte, and converts to the AST that Parse returns.
+func TestParseCST_Files(t *testing.T) {
+	err := filepath.Walk("../test", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".spin" {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		p := parser.NewFileParser(path, bytes.NewReader(src))
+		tree, _ := p.ParseCST()
+		if text := tree.Text(); text != string(src) {
+			t.Errorf("%s: text mismatch:\n%s", path, text)
+		}
+
+		// Every node of the AST has a tree with its text.
+		object := tree.Node.(*ast.Object)
+		ast.Inspect(object, func(n ast.Node) bool {
+			if n == nil || n == ast.Node(object) || !n.Pos().IsValid() {
+				return true
+			}
+			sub := tree.Find(n)
+			if sub == nil {
+				t.Errorf("%s: no tree for %T at %d", path, n, n.Pos())
+				return false
+			}
+			if _, ok := n.(ast.Block); ok {
+				return true
+			}
+			if exp, text := string(src[p.File().Offset(n.Pos()):p.File().Offset(n.End())]), sub.Text(); text != exp {
+				t.Errorf("%s: %T text mismatch: exp=%q got=%q", path, n, exp, text)
+			}
+			return true
+		})
+
+		exp, _ := parser.NewFileParser(path, bytes.NewReader(src)).Parse()
+		got, _ := parser.NewTreeParser(path, tree).Parse()
+		if ast.SExpr(got) != ast.SExpr(exp) {
+			t.Errorf("%s: AST mismatch:\nexp=%s\ngot=%s", path, ast.SExpr(exp), ast.SExpr(got))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure edits to a tree keep the rest of the source as it was.
+func TestTree_Edit(t *testing.T) {
+	src := "CON\n  LED = 16 ' pin\n\nPUB main | x\n    {{ set the pin }}\n    x :=  LED\n    outa[x]~~\n"
+	tree, err := parser.NewParser(strings.NewReader(src)).ParseCST()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tok := range tree.Tokens() {
+		if tok.Type == token.IDENTIFIER && tok.Text == "LED" {
+			tok.Text = "PIN"
+		}
+	}
+	exp := "CON\n  PIN = 16 ' pin\n\nPUB main | x\n    {{ set the pin }}\n    x :=  PIN\n    outa[x]~~\n"
+	if text := tree.Text(); text != exp {
+		t.Fatalf("text mismatch:\nexp=%q\ngot=%q", exp, text)
+	}
+
+	object, err := parser.NewTreeParser("", tree).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := object.Blocks[0].(*ast.ConBlock).Declarations[0].(*ast.ConstantDeclaration).Name; name != "PIN" {
+		t.Fatalf("unexpected name: %s", name)
+	}
+}
+
+// Ensure layout tokens belong to the innermost node around them.
+func TestTree_Children(t *testing.T) {
+	src := "' header\nPUB main\n  x := 1 ' one\n\nPRI b\n"
+	tree, err := parser.NewParser(strings.NewReader(src)).ParseCST()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	cst.Inspect(tree, func(n cst.Node) bool {
+		if sub, ok := n.(*cst.Tree); ok {
+			got = append(got, strings.TrimPrefix(fmt.Sprintf("%T", sub.Node), "*ast.")+": "+sub.Text())
+		}
+		return true
+	})
+	exp := []string{
+		"Object: " + src,
+		"PubBlock: PUB main\n  x := 1 ' one\n\n",
+		"ExpressionStatement: x := 1",
+		"AssignmentExpression: x := 1",
+		"Identifier: x",
+		"NumberLiteral: 1",
+		"PriBlock: PRI b\n",
+	}
+	if strings.Join(got, "|") != strings.Join(exp, "|") {
+		t.Fatalf("trees mismatch:\nexp=%q\ngot=%q", exp, got)
+	}
+
+	// The leading comment and the EOF token belong to the object.
+	if tok := tree.Children[0].(*cst.Token); tok.Type != token.COMMENT || tok.Text != "' header" {
+		t.Fatalf("unexpected first child: %#v", tok)
+	}
+	if tok := tree.Children[len(tree.Children)-1].(*cst.Token); tok.Type != token.EOF {
+		t.Fatalf("unexpected last child: %#v", tok)
+	}
+}
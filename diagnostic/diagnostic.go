@@ -0,0 +1,104 @@
+// Package diagnostic describes problems found in Lame source code.
+package diagnostic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bweir/lame/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Info:
+		return "info"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// A Diagnostic is a single problem at a position in the source. Code is a
+// short, stable identifier for the kind of problem, e.g. "unexpected-token".
+type Diagnostic struct {
+	Severity Severity
+	Pos      token.Position
+	Message  string
+	Code     string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", d.Pos, d.Severity, d.Message, d.Code)
+}
+
+// A List is a list of diagnostics. A List with errors can be returned as
+// an error.
+type List []Diagnostic
+
+// Add appends a diagnostic to the list.
+func (l *List) Add(severity Severity, pos token.Position, code, format string, args ...interface{}) {
+	*l = append(*l, Diagnostic{
+		Severity: severity,
+		Pos:      pos,
+		Message:  fmt.Sprintf(format, args...),
+		Code:     code,
+	})
+}
+
+// HasErrors reports whether the list contains any errors.
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort sorts the list by position.
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Pos, l[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// Err returns the list as an error if it contains any errors, and nil
+// otherwise.
+func (l List) Err() error {
+	if !l.HasErrors() {
+		return nil
+	}
+	return l
+}
+
+func (l List) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	lines := make([]string, len(l))
+	for i, d := range l {
+		lines[i] = d.Error()
+	}
+	return strings.Join(lines, "\n")
+}
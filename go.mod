@@ -1,5 +1,5 @@
 module github.com/bweir/lame
 
-go 1.15
+go 1.16
 
 require github.com/spf13/cobra v1.1.1
@@ -17,7 +17,10 @@ func (s *Scanner) scanLineComment() (tok token.Token) {
 	buf.WriteRune(s.read())
 
 	for {
-		if ch := s.read(); ch == eof || isLineCommentEnd(ch) {
+		if ch := s.read(); ch == eof {
+			break
+		} else if isLineCommentEnd(ch) {
+			s.unread()
 			break
 		} else {
 			_, _ = buf.WriteRune(ch)
@@ -33,6 +36,7 @@ func (s *Scanner) scanDocComment() (tok token.Token) {
 		return s.makeToken(token.ILLEGAL, string(ch))
 	}
 	tok.Type = token.DOC_COMMENT
+	tok.End = s.file.Pos(s.offset)
 	return tok
 }
 
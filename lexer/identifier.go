@@ -63,10 +63,16 @@ func (s *Scanner) scanIdentifier() (tok token.Token) {
 		return s.makeToken(token.CASE, buf.String())
 	case "IF":
 		return s.makeToken(token.IF, buf.String())
+	case "IFNOT":
+		return s.makeToken(token.IFNOT, buf.String())
 	case "ELSEIF":
 		return s.makeToken(token.ELSEIF, buf.String())
+	case "ELSEIFNOT":
+		return s.makeToken(token.ELSEIFNOT, buf.String())
 	case "ELSE":
 		return s.makeToken(token.ELSE, buf.String())
+	case "OTHER":
+		return s.makeToken(token.OTHER, buf.String())
 	case "NEXT":
 		return s.makeToken(token.NEXT, buf.String())
 	case "QUIT":
@@ -85,6 +91,8 @@ func (s *Scanner) scanIdentifier() (tok token.Token) {
 		return s.makeToken(token.UNTIL, buf.String())
 	case "RETURN":
 		return s.makeToken(token.RETURN, buf.String())
+	case "ABORT":
+		return s.makeToken(token.ABORT, buf.String())
 
 	// Memory
 	case "BYTE":
@@ -98,9 +106,9 @@ func (s *Scanner) scanIdentifier() (tok token.Token) {
 	case "NOT":
 		return s.makeToken(token.NOT, buf.String())
 	case "AND":
-		return s.makeToken(token.AND, buf.String())
+		return s.scanAssign(token.AND, token.AND_ASSIGN, buf.String())
 	case "OR":
-		return s.makeToken(token.OR, buf.String())
+		return s.scanAssign(token.OR, token.OR_ASSIGN, buf.String())
 	}
 
 	return s.makeToken(token.IDENTIFIER, buf.String())
@@ -1,7 +1,6 @@
 package lexer
 
 import (
-	"bytes"
 	"container/list"
 	"fmt"
 
@@ -15,21 +14,25 @@ func printList(li *list.List) {
 	fmt.Printf("\n")
 }
 
+// readIndent measures the indentation of the next line without reading
+// it, so that the indentation is scanned as a SPACE token. Blank lines and
+// lines that start with a comment keep the current indentation.
 func (s *Scanner) readIndent() {
-	var buf bytes.Buffer
-
+	n := 0
 	for {
-		if ch := s.read(); ch == eof {
-			break
-		} else if !isSpace(ch) {
-			s.unread()
+		next, err := s.r.Peek(n + 1)
+		if err != nil {
+			// Trailing spaces at the end of the file do not indent.
 			break
+		} else if ch := rune(next[n]); isSpace(ch) {
+			n++
 		} else {
-			buf.WriteRune(ch)
+			if !isNewline(ch) && !isLineCommentStart(ch) && !isCommentStart(ch) {
+				s.newIndent = n
+			}
+			break
 		}
 	}
-
-	s.newIndent = buf.Len()
 	if s.blockStart {
 		s.blockStart = false
 	}
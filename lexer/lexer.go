@@ -1,6 +1,6 @@
 // A lexer implementation of Lame.
 //
-// 		Hello
+//	Hello
 package lexer
 
 import (
@@ -19,53 +19,66 @@ var eof = rune(0)
 
 type Scanner struct {
 	r          *bufio.Reader
+	src        bytes.Buffer // source read so far
 	state      state.State
 	indent     *list.List
 	newIndent  int
 	blockStart bool
+	file       *token.File
+	offset     int // position of the next rune
 	line       int
 	column     int
-	lastChar   rune
-	lastColumn int
+	prevOffset int // position before the last read, for unread
+	prevLine   int
+	prevColumn int
+	tokOffset  int // position of the current token
+	tokLine    int
+	tokColumn  int
 }
 
 func NewScanner(r io.Reader) *Scanner {
-	return &Scanner{
-		r:      bufio.NewReader(r),
+	s := &Scanner{
 		state:  state.DEFAULT,
 		indent: list.New(),
+		file:   token.NewFile(""),
 	}
+	s.r = bufio.NewReader(io.TeeReader(r, &s.src))
+	return s
+}
+
+// File returns the line table of the source scanned so far.
+func (s *Scanner) File() *token.File {
+	return s.file
+}
+
+// Source returns the bytes read from the source. Once Scan has returned EOF it
+// holds the whole source, and the tokens cover it without gaps.
+func (s *Scanner) Source() []byte {
+	return s.src.Bytes()
 }
 
 // read reads the next rune from the bufferred reader.
 // Returns the rune(0) if an error occurs (or io.EOF is returned).
 func (s *Scanner) read() rune {
-	ch, _, err := s.r.ReadRune()
-	// fmt.Printf("reading '%s'\n", string(ch))
+	s.prevOffset, s.prevLine, s.prevColumn = s.offset, s.line, s.column
+	ch, size, err := s.r.ReadRune()
 	if err != nil {
 		return eof
 	}
-	if s.lastChar == '\n' {
-		s.lastColumn = s.column
-		s.column = 0
+	s.offset += size
+	if ch == '\n' {
 		s.line++
+		s.column = 0
+		s.file.AddLine(s.offset)
 	} else {
 		s.column++
 	}
-
-	s.lastChar = ch
 	return ch
 }
 
 func (s *Scanner) unread() {
 	_ = s.r.UnreadRune()
-	// fmt.Printf("unreading '%s'\n", string(s.lastChar))
-	if s.lastChar == '\n' {
-		s.line--
-		s.column = s.lastColumn
-	} else {
-		s.column--
-	}
+	s.offset, s.line, s.column = s.prevOffset, s.prevLine, s.prevColumn
 }
 
 func (s *Scanner) makeToken(tok token.Type, lit string) token.Token {
@@ -73,12 +86,16 @@ func (s *Scanner) makeToken(tok token.Type, lit string) token.Token {
 		Type:    tok,
 		Literal: lit,
 		State:   s.state,
-		Line:    s.line,
-		Column:  s.column,
+		Pos:     s.file.Pos(s.tokOffset),
+		End:     s.file.Pos(s.offset),
+		Line:    s.tokLine,
+		Column:  s.tokColumn,
 	}
 }
 
 func (s *Scanner) Scan() (tok token.Token) {
+	s.tokOffset, s.tokLine, s.tokColumn = s.offset, s.line, s.column
+
 	currentIndent := 0
 	if s.indent.Len() > 0 {
 		currentIndent = s.indent.Back().Value.(int)
@@ -87,10 +104,11 @@ func (s *Scanner) Scan() (tok token.Token) {
 	if ch := s.read(); ch == eof {
 		return s.makeToken(token.EOF, "")
 	} else if isNewline(ch) {
+		tok = s.makeToken(token.NEWLINE, string(ch))
 		if s.state == state.FUNCTION {
 			s.readIndent()
 		}
-		return s.makeToken(token.NEWLINE, string(ch))
+		return tok
 	} else if s.newIndent > currentIndent {
 		s.unread()
 		return s.scanIndent()
@@ -106,7 +124,11 @@ func (s *Scanner) Scan() (tok token.Token) {
 
 	} else if ch == '=' {
 		if ch = s.read(); ch == '=' {
-			return s.makeToken(token.EQUAL_TO, "==")
+			return s.scanAssign(token.EQUAL_TO, token.EQUAL_TO_ASSIGN, "==")
+		} else if ch == '<' {
+			return s.scanAssign(token.LESS_THAN_EQUAL_TO, token.LESS_THAN_EQUAL_TO_ASSIGN, "=<")
+		} else if ch == '>' {
+			return s.scanAssign(token.GREATER_THAN_EQUAL_TO, token.GREATER_THAN_EQUAL_TO_ASSIGN, "=>")
 		} else {
 			s.unread()
 			return s.makeToken(token.ASSIGN, "=")
@@ -114,11 +136,15 @@ func (s *Scanner) Scan() (tok token.Token) {
 
 	} else if ch == '<' {
 		if ch = s.read(); ch == '=' {
-			return s.makeToken(token.LESS_THAN_EQUAL_TO, "<=")
+			return s.makeToken(token.LESS_THAN_ASSIGN, "<=")
+		} else if ch == '>' {
+			return s.scanAssign(token.NOT_EQUAL_TO, token.NOT_EQUAL_TO_ASSIGN, "<>")
 		} else if ch == '<' {
-			return s.makeToken(token.BITWISE_SHIFT_LEFT, "<<")
+			return s.scanAssign(token.BITWISE_SHIFT_LEFT, token.BITWISE_SHIFT_LEFT_ASSIGN, "<<")
 		} else if ch == '-' {
-			return s.makeToken(token.BITWISE_ROTATE_LEFT, "<-")
+			return s.scanAssign(token.BITWISE_ROTATE_LEFT, token.BITWISE_ROTATE_LEFT_ASSIGN, "<-")
+		} else if ch == '#' {
+			return s.scanAssign(token.LIMIT_MAXIMUM, token.LIMIT_MAXIMUM_ASSIGN, "<#")
 		} else {
 			s.unread()
 			return s.makeToken(token.LESS_THAN, "<")
@@ -126,11 +152,13 @@ func (s *Scanner) Scan() (tok token.Token) {
 
 	} else if ch == '>' {
 		if ch = s.read(); ch == '=' {
-			return s.makeToken(token.GREATER_THAN_EQUAL_TO, ">=")
+			return s.makeToken(token.GREATER_THAN_ASSIGN, ">=")
 		} else if ch == '>' {
-			return s.makeToken(token.BITWISE_SHIFT_RIGHT, ">>")
+			return s.scanAssign(token.BITWISE_SHIFT_RIGHT, token.BITWISE_SHIFT_RIGHT_ASSIGN, ">>")
 		} else if ch == '<' {
-			return s.makeToken(token.BITWISE_REVERSE, "><")
+			return s.scanAssign(token.BITWISE_REVERSE, token.BITWISE_REVERSE_ASSIGN, "><")
+		} else if ch == '|' {
+			return s.makeToken(token.ENCODE, ">|")
 		} else {
 			s.unread()
 			return s.makeToken(token.GREATER_THAN, ">")
@@ -138,7 +166,7 @@ func (s *Scanner) Scan() (tok token.Token) {
 
 	} else if ch == '~' {
 		if ch = s.read(); ch == '>' {
-			return s.makeToken(token.BITWISE_SIGNED_SHIFT_RIGHT, "~>")
+			return s.scanAssign(token.BITWISE_SIGNED_SHIFT_RIGHT, token.BITWISE_SIGNED_SHIFT_RIGHT_ASSIGN, "~>")
 		} else if ch == '~' {
 			return s.makeToken(token.BITWISE_SIGN_EXTEND_15, "~~")
 		} else {
@@ -149,6 +177,8 @@ func (s *Scanner) Scan() (tok token.Token) {
 	} else if ch == '+' {
 		if ch = s.read(); ch == '=' {
 			return s.makeToken(token.ADD_ASSIGN, "+=")
+		} else if ch == '+' {
+			return s.makeToken(token.INCREMENT, "++")
 		} else {
 			s.unread()
 			return s.makeToken(token.ADD, "+")
@@ -158,7 +188,9 @@ func (s *Scanner) Scan() (tok token.Token) {
 		if ch = s.read(); ch == '=' {
 			return s.makeToken(token.SUBTRACT_ASSIGN, "-=")
 		} else if ch == '>' {
-			return s.makeToken(token.BITWISE_ROTATE_RIGHT, "->")
+			return s.scanAssign(token.BITWISE_ROTATE_RIGHT, token.BITWISE_ROTATE_RIGHT_ASSIGN, "->")
+		} else if ch == '-' {
+			return s.makeToken(token.DECREMENT, "--")
 		} else {
 			s.unread()
 			return s.makeToken(token.SUBTRACT, "-")
@@ -167,6 +199,8 @@ func (s *Scanner) Scan() (tok token.Token) {
 	} else if ch == '*' {
 		if ch = s.read(); ch == '=' {
 			return s.makeToken(token.MULTIPLY_ASSIGN, "*=")
+		} else if ch == '*' {
+			return s.scanAssign(token.MULTIPLY_HIGH, token.MULTIPLY_HIGH_ASSIGN, "**")
 		} else {
 			s.unread()
 			return s.makeToken(token.MULTIPLY, "*")
@@ -175,6 +209,8 @@ func (s *Scanner) Scan() (tok token.Token) {
 	} else if ch == '/' {
 		if ch = s.read(); ch == '=' {
 			return s.makeToken(token.DIVIDE_ASSIGN, "/=")
+		} else if ch == '/' {
+			return s.scanAssign(token.MODULO, token.MODULO_ASSIGN, "//")
 		} else {
 			s.unread()
 			return s.makeToken(token.DIVIDE, "/")
@@ -204,6 +240,10 @@ func (s *Scanner) Scan() (tok token.Token) {
 	} else if ch == '|' {
 		if ch = s.read(); ch == '=' {
 			return s.makeToken(token.BITWISE_OR_ASSIGN, "|=")
+		} else if ch == '|' {
+			return s.makeToken(token.ABSOLUTE, "||")
+		} else if ch == '<' {
+			return s.makeToken(token.DECODE, "|<")
 		} else {
 			s.unread()
 			return s.makeToken(token.BITWISE_OR, "|")
@@ -212,15 +252,44 @@ func (s *Scanner) Scan() (tok token.Token) {
 	} else if ch == '^' {
 		if ch = s.read(); ch == '=' {
 			return s.makeToken(token.BITWISE_XOR_ASSIGN, "^=")
+		} else if ch == '^' {
+			return s.makeToken(token.SQUARE_ROOT, "^^")
 		} else {
 			s.unread()
 			return s.makeToken(token.BITWISE_XOR, "^")
 		}
 
+	} else if ch == '#' {
+		if ch = s.read(); ch == '>' {
+			return s.scanAssign(token.LIMIT_MINIMUM, token.LIMIT_MINIMUM_ASSIGN, "#>")
+		} else {
+			s.unread()
+			return s.makeToken(token.POUND, "#")
+		}
+
+	} else if ch == '@' {
+		if ch = s.read(); ch == '@' {
+			return s.makeToken(token.AT_AT, "@@")
+		} else {
+			s.unread()
+			return s.makeToken(token.AT, "@")
+		}
+
+	} else if ch == ':' {
+		if ch = s.read(); ch == '=' {
+			return s.makeToken(token.ASSIGN, ":=")
+		} else {
+			s.unread()
+			return s.makeToken(token.COLON, ":")
+		}
+
 	} else if isDecimalDigit(ch) {
 		s.unread()
-		return s.scanDecimalNumber()
+		return s.scanNumber()
 	} else if ch == '$' {
+		if next, _ := s.r.Peek(1); len(next) == 0 || !isHexadecimalDigit(rune(next[0])) {
+			return s.makeToken(token.DOLLAR, "$")
+		}
 		return s.scanHexadecimalNumber()
 
 	} else if ch == '"' {
@@ -260,6 +329,16 @@ func (s *Scanner) Scan() (tok token.Token) {
 	}
 }
 
+// scanAssign returns the assignment form of a binary operator if the
+// operator is directly followed by '='.
+func (s *Scanner) scanAssign(op, assign token.Type, lit string) token.Token {
+	if ch := s.read(); ch == '=' {
+		return s.makeToken(assign, lit+"=")
+	}
+	s.unread()
+	return s.makeToken(op, lit)
+}
+
 func (s *Scanner) scanSpace() (tok token.Token) {
 	var buf bytes.Buffer
 	buf.WriteRune(s.read())
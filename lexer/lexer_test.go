@@ -1,6 +1,10 @@
 package lexer_test
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -33,6 +37,19 @@ func TestScanner_Scan(t *testing.T) {
 		{src: `/=`, Type: token.DIVIDE_ASSIGN, Literal: `/=`},
 		{src: `%`, Type: token.MODULO, Literal: `%`},
 		{src: `%=`, Type: token.MODULO_ASSIGN, Literal: `%=`},
+		{src: `//`, Type: token.MODULO, Literal: `//`},
+		{src: `//=`, Type: token.MODULO_ASSIGN, Literal: `//=`},
+		{src: `**`, Type: token.MULTIPLY_HIGH, Literal: `**`},
+		{src: `**=`, Type: token.MULTIPLY_HIGH_ASSIGN, Literal: `**=`},
+		{src: `++`, Type: token.INCREMENT, Literal: `++`},
+		{src: `--`, Type: token.DECREMENT, Literal: `--`},
+		{src: `#>`, Type: token.LIMIT_MINIMUM, Literal: `#>`},
+		{src: `#>=`, Type: token.LIMIT_MINIMUM_ASSIGN, Literal: `#>=`},
+		{src: `<#`, Type: token.LIMIT_MAXIMUM, Literal: `<#`},
+		{src: `<#=`, Type: token.LIMIT_MAXIMUM_ASSIGN, Literal: `<#=`},
+		{src: `||`, Type: token.ABSOLUTE, Literal: `||`},
+		{src: `^^`, Type: token.SQUARE_ROOT, Literal: `^^`},
+		{src: `?`, Type: token.RANDOM, Literal: `?`},
 
 		// Bitwise
 		{src: `&`, Type: token.BITWISE_AND, Literal: `&`},
@@ -49,19 +66,42 @@ func TestScanner_Scan(t *testing.T) {
 		{src: `->`, Type: token.BITWISE_ROTATE_RIGHT, Literal: `->`},
 		{src: `><`, Type: token.BITWISE_REVERSE, Literal: `><`},
 		{src: `~>`, Type: token.BITWISE_SIGNED_SHIFT_RIGHT, Literal: `~>`},
+		{src: `|<`, Type: token.DECODE, Literal: `|<`},
+		{src: `>|`, Type: token.ENCODE, Literal: `>|`},
+
+		{src: `<<=`, Type: token.BITWISE_SHIFT_LEFT_ASSIGN, Literal: `<<=`},
+		{src: `>>=`, Type: token.BITWISE_SHIFT_RIGHT_ASSIGN, Literal: `>>=`},
+		{src: `<-=`, Type: token.BITWISE_ROTATE_LEFT_ASSIGN, Literal: `<-=`},
+		{src: `->=`, Type: token.BITWISE_ROTATE_RIGHT_ASSIGN, Literal: `->=`},
+		{src: `><=`, Type: token.BITWISE_REVERSE_ASSIGN, Literal: `><=`},
+		{src: `~>=`, Type: token.BITWISE_SIGNED_SHIFT_RIGHT_ASSIGN, Literal: `~>=`},
 
 		{src: `~`, Type: token.BITWISE_SIGN_EXTEND_7, Literal: `~`},
 		{src: `~~`, Type: token.BITWISE_SIGN_EXTEND_15, Literal: `~~`},
 
 		// Comparison
 		{src: `=`, Type: token.ASSIGN, Literal: `=`},
+		{src: `:=`, Type: token.ASSIGN, Literal: `:=`},
 		{src: `==`, Type: token.EQUAL_TO, Literal: `==`},
+		{src: `<>`, Type: token.NOT_EQUAL_TO, Literal: `<>`},
+		{src: `=<`, Type: token.LESS_THAN_EQUAL_TO, Literal: `=<`},
+		{src: `=>`, Type: token.GREATER_THAN_EQUAL_TO, Literal: `=>`},
 		{src: `<`, Type: token.LESS_THAN, Literal: `<`},
-		{src: `<=`, Type: token.LESS_THAN_EQUAL_TO, Literal: `<=`},
 		{src: `>`, Type: token.GREATER_THAN, Literal: `>`},
-		{src: `>=`, Type: token.GREATER_THAN_EQUAL_TO, Literal: `>=`},
+
+		// Comparison assign
+		{src: `===`, Type: token.EQUAL_TO_ASSIGN, Literal: `===`},
+		{src: `<>=`, Type: token.NOT_EQUAL_TO_ASSIGN, Literal: `<>=`},
+		{src: `<=`, Type: token.LESS_THAN_ASSIGN, Literal: `<=`},
+		{src: `>=`, Type: token.GREATER_THAN_ASSIGN, Literal: `>=`},
+		{src: `=<=`, Type: token.LESS_THAN_EQUAL_TO_ASSIGN, Literal: `=<=`},
+		{src: `=>=`, Type: token.GREATER_THAN_EQUAL_TO_ASSIGN, Literal: `=>=`},
 		{src: `.`, Type: token.DOT, Literal: `.`},
 		{src: `..`, Type: token.RANGE, Literal: `..`},
+		{src: `:`, Type: token.COLON, Literal: `:`},
+		{src: `#`, Type: token.POUND, Literal: `#`},
+		{src: `@`, Type: token.AT, Literal: `@`},
+		{src: `@@`, Type: token.AT_AT, Literal: `@@`},
 
 		// Numbers
 
@@ -89,6 +129,15 @@ func TestScanner_Scan(t *testing.T) {
 		{src: `$1ACD3`, Type: token.HEXADECIMAL_NUMBER, Literal: `1ACD3`},
 		{src: `$1acd3`, Type: token.HEXADECIMAL_NUMBER, Literal: `1acd3`},
 		{src: `$1ac_d3`, Type: token.HEXADECIMAL_NUMBER, Literal: `1ac_d3`},
+		{src: `$`, Type: token.DOLLAR, Literal: `$`}, // current address in PASM
+		{src: `$ - 1`, Type: token.DOLLAR, Literal: `$`},
+
+		// Float numbers
+		{src: `3.14`, Type: token.FLOAT_NUMBER, Literal: `3.14`},
+		{src: `1e10`, Type: token.FLOAT_NUMBER, Literal: `1e10`},
+		{src: `2.5e-3`, Type: token.FLOAT_NUMBER, Literal: `2.5e-3`},
+		{src: `0..9`, Type: token.DECIMAL_NUMBER, Literal: `0`}, // ranges are not floats
+		{src: `3.x`, Type: token.DECIMAL_NUMBER, Literal: `3`},
 
 		// Identifiers
 		{src: `foobar`, Type: token.IDENTIFIER, Literal: `foobar`},
@@ -114,8 +163,11 @@ func TestScanner_Scan(t *testing.T) {
 		// Flow Control
 		{src: `case`, Type: token.CASE, Literal: `case`},
 		{src: `if`, Type: token.IF, Literal: `if`},
+		{src: `ifnot`, Type: token.IFNOT, Literal: `ifnot`},
 		{src: `elseif`, Type: token.ELSEIF, Literal: `elseif`},
+		{src: `elseifnot`, Type: token.ELSEIFNOT, Literal: `elseifnot`},
 		{src: `else`, Type: token.ELSE, Literal: `else`},
+		{src: `other`, Type: token.OTHER, Literal: `other`},
 		{src: `next`, Type: token.NEXT, Literal: `next`},
 		{src: `quit`, Type: token.QUIT, Literal: `quit`},
 		{src: `repeat`, Type: token.REPEAT, Literal: `repeat`},
@@ -125,6 +177,7 @@ func TestScanner_Scan(t *testing.T) {
 		{src: `while`, Type: token.WHILE, Literal: `while`},
 		{src: `until`, Type: token.UNTIL, Literal: `until`},
 		{src: `return`, Type: token.RETURN, Literal: `return`},
+		{src: `abort`, Type: token.ABORT, Literal: `abort`},
 
 		// Memory
 		{src: `byte`, Type: token.BYTE, Literal: `byte`},
@@ -135,6 +188,16 @@ func TestScanner_Scan(t *testing.T) {
 		{src: `not`, Type: token.NOT, Literal: `not`},
 		{src: `and`, Type: token.AND, Literal: `and`},
 		{src: `or`, Type: token.OR, Literal: `or`},
+		{src: `AND=`, Type: token.AND_ASSIGN, Literal: `AND=`},
+		{src: `or=`, Type: token.OR_ASSIGN, Literal: `or=`},
+
+		// Strings
+		{src: `"abc"`, Type: token.STRING, Literal: `abc`},
+		{src: `""`, Type: token.STRING, Literal: ``},
+		{src: `"\"q\""`, Type: token.STRING, Literal: `"q"`},
+		{src: `"\n\t"`, Type: token.STRING, Literal: "\n\t"},
+		{src: `"\65\0"`, Type: token.STRING, Literal: "A\x00"},
+		{src: `"abc`, Type: token.UNEXPECTED_EOF},
 	}
 
 	for i, tt := range tests {
@@ -147,3 +210,114 @@ func TestScanner_Scan(t *testing.T) {
 		}
 	}
 }
+
+// Ensure the scanner produces the right token sequence across lines.
+func TestScanner_Scan_Sequence(t *testing.T) {
+	var tests = []struct {
+		src   string
+		Types []token.Type
+	}{
+		// Line comments end before the newline
+		{src: "' note\nx", Types: []token.Type{token.COMMENT, token.NEWLINE, token.IDENTIFIER, token.EOF}},
+		{src: "'' doc\nx", Types: []token.Type{token.DOC_COMMENT, token.NEWLINE, token.IDENTIFIER, token.EOF}},
+
+		// Comment lines do not change the indentation
+		{src: "PUB a\n    x\n' note\n    y", Types: []token.Type{
+			token.PUB, token.SPACE, token.IDENTIFIER, token.NEWLINE,
+			token.INDENT, token.SPACE, token.IDENTIFIER, token.NEWLINE,
+			token.COMMENT, token.NEWLINE,
+			token.SPACE, token.IDENTIFIER, token.EOF,
+		}},
+
+		// Logical assignments end at the =, and need no space
+		{src: "a and= b", Types: []token.Type{token.IDENTIFIER, token.SPACE, token.AND_ASSIGN, token.SPACE, token.IDENTIFIER, token.EOF}},
+		{src: "a or=b", Types: []token.Type{token.IDENTIFIER, token.SPACE, token.OR_ASSIGN, token.IDENTIFIER, token.EOF}},
+		{src: "a and =b", Types: []token.Type{token.IDENTIFIER, token.SPACE, token.AND, token.SPACE, token.ASSIGN, token.IDENTIFIER, token.EOF}},
+	}
+
+	for i, tt := range tests {
+		s := lexer.NewScanner(strings.NewReader(tt.src))
+		for j, typ := range tt.Types {
+			if tok := s.Scan(); tok.Type != typ {
+				t.Errorf("%d. %q token %d mismatch: exp=%q got=%q <%q>", i, tt.src, j, typ, tok.Type, tok.Literal)
+				break
+			}
+		}
+	}
+}
+
+// Ensure tokens are positioned at their first character.
+func TestScanner_Scan_Position(t *testing.T) {
+	src := "PUB a\n  x := 10\n  y~\n"
+	var exp = []struct {
+		Type         token.Type
+		Line, Column int
+	}{
+		{token.PUB, 0, 0},
+		{token.SPACE, 0, 3},
+		{token.IDENTIFIER, 0, 4},
+		{token.NEWLINE, 0, 5},
+		{token.INDENT, 1, 0},
+		{token.SPACE, 1, 0},
+		{token.IDENTIFIER, 1, 2},
+		{token.SPACE, 1, 3},
+		{token.ASSIGN, 1, 4},
+		{token.SPACE, 1, 6},
+		{token.DECIMAL_NUMBER, 1, 7},
+		{token.NEWLINE, 1, 9},
+		{token.SPACE, 2, 0},
+		{token.IDENTIFIER, 2, 2},
+		{token.BITWISE_SIGN_EXTEND_7, 2, 3},
+		{token.NEWLINE, 2, 4},
+		{token.EOF, 3, 0},
+	}
+
+	s := lexer.NewScanner(strings.NewReader(src))
+	for i, e := range exp {
+		tok := s.Scan()
+		if tok.Type != e.Type || tok.Line != e.Line || tok.Column != e.Column {
+			t.Errorf("%d. mismatch: exp=%s(%d, %d) got=%s(%d, %d)", i, e.Type, e.Line, e.Column, tok.Type, tok.Line, tok.Column)
+		}
+		if raw := src[s.File().Offset(tok.Pos):s.File().Offset(tok.End)]; raw != tok.Literal {
+			t.Errorf("%d. %s range mismatch: exp=%q got=%q", i, tok.Type, tok.Literal, raw)
+		}
+	}
+}
+
+// Ensure the tokens of every test object cover the source without gaps,
+// so that the source can be rebuilt from them.
+func TestScanner_Scan_Lossless(t *testing.T) {
+	err := filepath.Walk("../test", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".spin" {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		s := lexer.NewScanner(bytes.NewReader(src))
+		offset := 0
+		for {
+			tok := s.Scan()
+			if from := s.File().Offset(tok.Pos); from != offset {
+				t.Errorf("%s: %s at offset %d, expected %d", path, tok.Type, from, offset)
+				return nil
+			}
+			offset = s.File().Offset(tok.End)
+			if tok.Type == token.EOF || tok.Type == token.UNEXPECTED_EOF {
+				break
+			}
+		}
+		if offset != len(src) {
+			t.Errorf("%s: tokens end at offset %d, expected %d", path, offset, len(src))
+		}
+		if !bytes.Equal(s.Source(), src) {
+			t.Errorf("%s: source mismatch", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
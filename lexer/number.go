@@ -60,6 +60,51 @@ func (s *Scanner) scanDecimalNumber() (tok token.Token) {
 	return s.makeToken(token.DECIMAL_NUMBER, buf.String())
 }
 
+// scanNumber scans a decimal number, continuing into a float if the number
+// is followed by a fraction or an exponent.
+func (s *Scanner) scanNumber() (tok token.Token) {
+	tok = s.scanDecimalNumber()
+
+	var buf bytes.Buffer
+	buf.WriteString(tok.Literal)
+	isFloat := false
+
+	if next, _ := s.r.Peek(2); len(next) == 2 && next[0] == '.' && isDecimalDigit(rune(next[1])) {
+		isFloat = true
+		buf.WriteRune(s.read())
+		s.scanDigits(&buf)
+	}
+
+	if next, _ := s.r.Peek(3); len(next) >= 2 && (next[0] == 'e' || next[0] == 'E') {
+		if isDecimalDigit(rune(next[1])) || (len(next) == 3 && (next[1] == '-' || next[1] == '+') && isDecimalDigit(rune(next[2]))) {
+			isFloat = true
+			buf.WriteRune(s.read())
+			if next[1] == '-' || next[1] == '+' {
+				buf.WriteRune(s.read())
+			}
+			s.scanDigits(&buf)
+		}
+	}
+
+	if !isFloat {
+		return tok
+	}
+	return s.makeToken(token.FLOAT_NUMBER, buf.String())
+}
+
+func (s *Scanner) scanDigits(buf *bytes.Buffer) {
+	for {
+		if ch := s.read(); ch == eof {
+			break
+		} else if !isDecimalDigit(ch) && !isGroupSeparator(ch) {
+			s.unread()
+			break
+		} else {
+			_, _ = buf.WriteRune(ch)
+		}
+	}
+}
+
 func (s *Scanner) scanHexadecimalNumber() (tok token.Token) {
 	var buf bytes.Buffer
 	buf.WriteRune(s.read())
@@ -11,16 +11,10 @@ func (s *Scanner) scanOperator() (tok token.Token) {
 	case ',':
 		return s.makeToken(token.COMMA, string(ch))
 
-	case ':':
-		return s.makeToken(token.COLON, string(ch))
 	case '.':
 		return s.makeToken(token.DOT, string(ch))
-	case '|':
-		return s.makeToken(token.PIPE, string(ch))
-	case '@':
-		return s.makeToken(token.AT, string(ch))
-	case '#':
-		return s.makeToken(token.POUND, string(ch))
+	case '?':
+		return s.makeToken(token.RANDOM, string(ch))
 
 	// Bitwise
 	case '!':
@@ -7,9 +7,9 @@ import (
 	"github.com/bweir/lame/token"
 )
 
+// scanString scans a string literal after its opening quote.
 func (s *Scanner) scanString() (tok token.Token) {
 	var buf bytes.Buffer
-	buf.WriteRune(s.read())
 
 	for {
 		if ch := s.read(); ch == '"' {
@@ -0,0 +1,124 @@
+package parser
+
+import "github.com/bweir/lame/token"
+
+// A tokenBuffer is a ring buffer of the tokens read from the scanner. It
+// holds every token from the oldest one the parser can still go back to,
+// by unscan or by resetting a mark, up to the furthest one peeked at.
+// Tokens are numbered from zero in the order they were scanned.
+type tokenBuffer struct {
+	ring []token.Token  // the length is a power of two
+	head int            // number of the next token to return
+	tail int            // number of the next token to read from the scanner
+	last int            // number of the last token returned
+	init [4]token.Token // the first ring, so that most parses need no other
+}
+
+// at returns token i, which must be buffered.
+func (b *tokenBuffer) at(i int) token.Token {
+	return b.ring[i&(len(b.ring)-1)]
+}
+
+// push adds a token read from the scanner. Tokens from keep on are still
+// needed; the ring grows if it would overwrite one of them.
+func (b *tokenBuffer) push(tok token.Token, keep int) {
+	if n := len(b.ring); b.tail-keep >= n {
+		var ring []token.Token
+		if n == 0 {
+			ring = b.init[:]
+		} else {
+			ring = make([]token.Token, 2*n)
+		}
+		for i := keep; i < b.tail; i++ {
+			ring[i&(len(ring)-1)] = b.at(i)
+		}
+		b.ring = ring
+	}
+	b.ring[b.tail&(len(b.ring)-1)] = tok
+	b.tail++
+}
+
+// A mark records the state of the parser, so that it can go back to it
+// after looking ahead.
+type mark struct {
+	depth        int // number of marks held before this one
+	head, last   int
+	end, prevEnd token.Pos
+	diagnostics  int
+}
+
+// mark returns a mark at the current token. Marks nest: each must be
+// reset or released, the latest first.
+func (p *Parser) mark() mark {
+	if p.marks == 0 {
+		p.keep = p.buf.last
+	}
+	p.marks++
+	return mark{
+		depth:       p.marks - 1,
+		head:        p.buf.head,
+		last:        p.buf.last,
+		end:         p.end,
+		prevEnd:     p.prevEnd,
+		diagnostics: len(p.diagnostics),
+	}
+}
+
+// reset goes back to m, dropping the syntax errors reported since, and
+// releases it. Lexical errors are reported once, when a token is first
+// read from the scanner, so they are kept.
+func (p *Parser) reset(m mark) {
+	p.buf.head, p.buf.last = m.head, m.last
+	p.end, p.prevEnd = m.end, m.prevEnd
+	kept := p.diagnostics[:m.diagnostics]
+	for _, d := range p.diagnostics[m.diagnostics:] {
+		if d.Code != "unexpected-token" {
+			kept = append(kept, d)
+		}
+	}
+	p.diagnostics = kept
+	p.release(m)
+}
+
+// release releases m, keeping the tokens read since. It panics if m is
+// not the latest mark held.
+func (p *Parser) release(m mark) {
+	if m.depth != p.marks-1 {
+		panic("parser: mark released out of order")
+	}
+	p.marks = m.depth
+}
+
+// fill reads the next token from the scanner into the buffer.
+func (p *Parser) fill() {
+	tok := p.s.Scan()
+	p.checkToken(tok)
+	p.comments.add(tok)
+	if p.record {
+		p.tokens = append(p.tokens, tok)
+	}
+
+	keep := p.buf.last
+	if p.marks > 0 && p.keep < keep {
+		keep = p.keep
+	}
+	p.buf.push(tok, keep)
+}
+
+// peek returns the nth next token on the current line without consuming
+// it, skipping spaces and comments. It does not look past the end of the
+// file.
+func (p *Parser) peek(n int) (tok token.Token) {
+	for i := p.buf.head; ; i++ {
+		if i == p.buf.tail {
+			p.fill()
+		}
+		tok = p.buf.at(i)
+		if tok.Type == token.SPACE || isComment(tok) {
+			continue
+		}
+		if n--; n == 0 || tok.Type == token.EOF {
+			return tok
+		}
+	}
+}
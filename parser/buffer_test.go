@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bweir/lame/token"
+)
+
+// Ensure peek looks any number of tokens ahead without consuming them,
+// and that unscan still goes back a token after the ring has grown.
+func TestParser_peek(t *testing.T) {
+	p := NewParser(strings.NewReader("a ' note\n b[1] := c.d(2)"))
+	exp := []string{"a", "\n", "b", "[", "1", "]", ":=", "c", ".", "d", "(", "2", ")", "", ""}
+	for i, lit := range exp {
+		if tok := p.peek(i + 1); tok.Literal != lit {
+			t.Errorf("peek(%d) mismatch: exp=%q got=%q", i+1, lit, tok.Literal)
+		}
+	}
+	if tok := p.next(); tok.Literal != "a" {
+		t.Fatalf("unexpected next token: %q", tok.Literal)
+	}
+	p.unscan()
+	if tok := p.next(); tok.Literal != "a" {
+		t.Fatalf("unexpected token after unscan: %q", tok.Literal)
+	}
+}
+
+// Ensure the parser goes back to a mark, even after the ring has grown,
+// and drops the syntax errors reported since but not the lexical ones.
+func TestParser_mark(t *testing.T) {
+	src := "x" + strings.Repeat(" + 1", 40) + " `\n?"
+	p := NewParser(strings.NewReader(src))
+	p.next()
+
+	m := p.mark()
+	end := p.end
+	var last token.Token
+	for tok := p.next(); tok.Type != token.EOF; tok = p.next() {
+		last = tok
+	}
+	p.report(p.errorf(last, "found %q", last.Literal))
+	if last.Literal != "?" || len(p.diagnostics) != 2 {
+		t.Fatalf("unexpected state: %q %v", last.Literal, p.diagnostics)
+	}
+
+	p.reset(m)
+	if p.end != end || p.marks != 0 {
+		t.Fatalf("state not reset: end=%d marks=%d", p.end, p.marks)
+	} else if len(p.diagnostics) != 1 || p.diagnostics[0].Code != "illegal-token" {
+		t.Fatalf("unexpected diagnostics after reset: %v", p.diagnostics)
+	}
+	if tok := p.next(); tok.Type != token.ADD {
+		t.Fatalf("unexpected token after reset: %q", tok.Literal)
+	}
+
+	// Marks nest, and a released mark keeps the tokens read since.
+	outer := p.mark()
+	p.next()
+	inner := p.mark()
+	p.next()
+	p.release(inner)
+	if tok := p.next(); tok.Type != token.DECIMAL_NUMBER {
+		t.Fatalf("unexpected token after release: %q", tok.Literal)
+	}
+	p.unscan()
+	if tok := p.next(); tok.Type != token.DECIMAL_NUMBER {
+		t.Fatalf("unexpected token after unscan: %q", tok.Literal)
+	}
+	p.reset(outer)
+	if tok := p.next(); tok.Type != token.DECIMAL_NUMBER || p.buf.head != outer.head+2 {
+		t.Fatalf("unexpected token after nested reset: %q", tok.Literal)
+	}
+
+	// Marks must be released the latest first.
+	outer = p.mark()
+	p.mark()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic releasing a mark out of order")
+		}
+	}()
+	p.release(outer)
+}
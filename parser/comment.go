@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+// comments groups the comments read from the scanner.
+type comments struct {
+	groups   []*ast.CommentGroup
+	group    []*ast.Comment // current group
+	trailing bool           // the current group follows code on its line
+	code     bool           // code since the last newline
+	lines    int            // newlines since the last comment
+}
+
+// add records tok, which must be the next token from the scanner.
+func (c *comments) add(tok token.Token) {
+	switch {
+	case isComment(tok):
+		comment := &ast.Comment{From: tok.Pos, To: tok.End, Text: tok.Literal, Doc: tok.Type == token.DOC_COMMENT}
+
+		// A line comment is one quote longer than its text, a doc line
+		// comment two. Block comments have twice as many braces.
+		delims := int(tok.End-tok.Pos) - len(tok.Literal)
+		if comment.Doc {
+			comment.Block = delims > 2
+		} else {
+			comment.Block = delims > 1
+		}
+
+		if len(c.group) > 0 {
+			last := c.group[len(c.group)-1]
+			if c.lines > 1 || (c.trailing && c.lines > 0) || last.Doc != comment.Doc {
+				c.flush()
+			}
+		}
+		if len(c.group) == 0 {
+			c.trailing = c.code
+		}
+		c.group = append(c.group, comment)
+		c.lines = 0
+
+	case tok.Type == token.NEWLINE:
+		c.lines++
+		c.code = false
+
+	case tok.Type == token.EOF:
+		c.flush()
+
+	case !isLayout(tok):
+		c.flush()
+		c.code = true
+	}
+}
+
+// flush ends the current group.
+func (c *comments) flush() {
+	if len(c.group) > 0 {
+		c.groups = append(c.groups, &ast.CommentGroup{List: c.group})
+		c.group = nil
+	}
+}
@@ -0,0 +1,162 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+func (p *Parser) parseDatBlock(keyword token.Token) *ast.DatBlock {
+	block := &ast.DatBlock{From: keyword.Pos}
+
+	// Labels on a line of their own take the size of the next entry.
+	var pending []*ast.Label
+	sizeLabels := func(size token.Type) {
+		for _, label := range pending {
+			label.Size = size
+		}
+		pending = nil
+	}
+
+	parseLine := func() error {
+		var label *ast.Label
+		tok := p.next()
+		if tok.Type == token.COLON {
+			name, err := p.expect(token.IDENTIFIER, "local label")
+			if err != nil {
+				return err
+			}
+			label = &ast.Label{From: tok.Pos, To: name.End, Name: ":" + name.Literal}
+			block.Entries = append(block.Entries, label)
+			tok = p.next()
+		} else if tok.Type == token.IDENTIFIER && !p.isFileDirective(tok) && !isInstructionStart(tok) {
+			label = &ast.Label{From: tok.Pos, To: tok.End, Name: tok.Literal}
+			block.Entries = append(block.Entries, label)
+			tok = p.next()
+		}
+
+		switch {
+		case isEndOfStatement(tok):
+			p.unscan()
+			if label != nil {
+				pending = append(pending, label)
+			}
+
+		case isSize(tok):
+			directive, err := p.parseDataDirective(tok)
+			if err != nil {
+				return err
+			}
+			if label != nil {
+				label.Size = tok.Type
+			}
+			sizeLabels(tok.Type)
+			block.Entries = append(block.Entries, directive)
+
+		case p.isFileDirective(tok):
+			path, err := p.expect(token.STRING, "file name")
+			if err != nil {
+				return err
+			}
+			if label != nil {
+				label.Size = token.BYTE
+			}
+			sizeLabels(token.BYTE)
+			block.Entries = append(block.Entries, &ast.FileDirective{From: tok.Pos, To: path.End, Path: path.Literal})
+
+		case isInstructionStart(tok):
+			entry, err := p.parseInstruction(tok)
+			if err != nil {
+				return err
+			}
+			if label != nil {
+				label.Size = token.LONG
+			}
+			sizeLabels(token.LONG)
+			block.Entries = append(block.Entries, entry)
+
+		default:
+			return p.errorf(tok, "found %q, expected data directive or instruction", tok.Literal)
+		}
+
+		return p.expectEndOfLine()
+	}
+
+	for p.nextDeclaration() {
+		from := p.peek(1).Pos
+		if err := parseLine(); err != nil {
+			block.Entries = append(block.Entries, p.badDeclaration(from, err))
+		}
+	}
+	block.To = p.end
+
+	sizeLabels(token.LONG)
+	return block
+}
+
+// isFileDirective reports whether tok starts a file directive, i.e. it is
+// "file" followed by a file name.
+func (p *Parser) isFileDirective(tok token.Token) bool {
+	if tok.Type != token.IDENTIFIER || strings.ToUpper(tok.Literal) != "FILE" {
+		return false
+	}
+	return p.peek(1).Type == token.STRING
+}
+
+// parseDataDirective parses the values of a byte, word or long directive.
+func (p *Parser) parseDataDirective(size token.Token) (*ast.DataDirective, error) {
+	directive := &ast.DataDirective{From: size.Pos, To: size.End, Size: size.Type}
+	if tok := p.peek(1); isEndOfStatement(tok) {
+		return directive, nil
+	}
+
+	for {
+		value, err := p.parseDataValue()
+		if err != nil {
+			return nil, err
+		}
+		directive.Values = append(directive.Values, value)
+		directive.To = value.To
+
+		if tok := p.next(); tok.Type != token.COMMA {
+			p.unscan()
+			return directive, nil
+		}
+	}
+}
+
+// parseDataValue parses a value with an optional size override and
+// repeat count, e.g. "word $FF" or "0[16]".
+func (p *Parser) parseDataValue() (*ast.DataValue, error) {
+	value := &ast.DataValue{}
+
+	if tok := p.next(); isSize(tok) {
+		value.From = tok.Pos
+		value.Size = tok.Type
+	} else {
+		p.unscan()
+	}
+
+	x, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if !value.From.IsValid() {
+		value.From = x.Pos()
+	}
+	value.To = x.End()
+
+	// A bracket after a data value is a repeat count, not an index.
+	if index, ok := x.(*ast.IndexExpression); ok {
+		value.Value, value.Count = index.X, index.Index
+		return value, nil
+	}
+	value.Value = x
+
+	if value.Count, err = p.parseCount(); err != nil {
+		return nil, err
+	}
+	value.To = p.end
+	return value, nil
+}
@@ -0,0 +1,312 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+// parseExpression parses an expression, including assignments.
+// Assignments bind loosest and are right-associative.
+func (p *Parser) parseExpression() (ast.Expression, error) {
+	x, err := p.parseBinaryExpression(precedenceOr)
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.next()
+	if !isAssignment(tok) {
+		p.unscan()
+		return x, nil
+	}
+
+	value, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.AssignmentExpression{From: x.Pos(), To: p.end, Operator: tok.Type, Target: x, Value: value}, nil
+}
+
+// parseBinaryExpression parses binary operators that bind at least as
+// tightly as precedence, using precedence climbing.
+func (p *Parser) parseBinaryExpression(precedence int) (ast.Expression, error) {
+	x, err := p.parseUnaryExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.next()
+		prec := binaryPrecedence(tok)
+		if prec == 0 || prec < precedence {
+			p.unscan()
+			return x, nil
+		}
+
+		y, err := p.parseBinaryExpression(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &ast.BinaryExpression{From: x.Pos(), To: p.end, Operator: tok.Type, X: x, Y: y}
+	}
+}
+
+func (p *Parser) parseUnaryExpression() (ast.Expression, error) {
+	tok := p.next()
+
+	if tok.Type == token.NOT {
+		x, err := p.parseBinaryExpression(precedenceComparison)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.UnaryExpression{From: tok.Pos, To: p.end, Operator: tok.Type, X: x}, nil
+	}
+
+	if isPrefixOperator(tok) {
+		x, err := p.parseUnaryExpression()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.UnaryExpression{From: tok.Pos, To: p.end, Operator: tok.Type, X: x}, nil
+	}
+
+	p.unscan()
+	return p.parsePostfixExpression()
+}
+
+func (p *Parser) parsePostfixExpression() (ast.Expression, error) {
+	x, err := p.parsePrimaryExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.next()
+		if !isPostfixOperator(tok) {
+			p.unscan()
+			return x, nil
+		}
+		x = &ast.PostfixExpression{From: x.Pos(), To: tok.End, Operator: tok.Type, X: x}
+	}
+}
+
+func (p *Parser) parsePrimaryExpression() (ast.Expression, error) {
+	tok := p.next()
+
+	switch {
+	case tok.Type == token.IDENTIFIER:
+		return p.parseIdentifierExpression(tok)
+
+	case isNumber(tok):
+		return &ast.NumberLiteral{From: tok.Pos, To: tok.End, Kind: tok.Type, Value: tok.Literal}, nil
+
+	case tok.Type == token.STRING:
+		return &ast.StringLiteral{From: tok.Pos, To: tok.End, Value: tok.Literal}, nil
+
+	case tok.Type == token.TRUE || tok.Type == token.FALSE:
+		return &ast.BooleanLiteral{From: tok.Pos, To: tok.End, Value: tok.Type == token.TRUE}, nil
+
+	case tok.Type == token.PAREN_OPEN:
+		x, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		end, err := p.expect(token.PAREN_CLOSE, "')'")
+		if err != nil {
+			return nil, err
+		}
+		return &ast.ParenExpression{From: tok.Pos, To: end.End, X: x}, nil
+
+	case isSize(tok):
+		return p.parseMemoryExpression(tok)
+
+	case tok.Type == token.DOLLAR:
+		return &ast.CurrentAddressExpression{From: tok.Pos, To: tok.End}, nil
+	}
+
+	return nil, p.errorf(tok, "found %q, expected expression", tok.Literal)
+}
+
+// parseIdentifierExpression parses an identifier and any selectors,
+// indexes and calls that follow it, as well as the built-in string,
+// constant and lookup expressions.
+func (p *Parser) parseIdentifierExpression(tok token.Token) (ast.Expression, error) {
+	if next := p.peek(1); next.Type == token.PAREN_OPEN {
+		switch name := strings.ToUpper(tok.Literal); name {
+		case "STRING":
+			p.next()
+			args, err := p.parseArguments()
+			if err != nil {
+				return nil, err
+			}
+			return &ast.StringExpression{From: tok.Pos, To: p.end, Arguments: args}, nil
+
+		case "CONSTANT":
+			p.next()
+			x, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			end, err := p.expect(token.PAREN_CLOSE, "')'")
+			if err != nil {
+				return nil, err
+			}
+			return &ast.ConstantExpression{From: tok.Pos, To: end.End, X: x}, nil
+
+		case "LOOKUP", "LOOKUPZ", "LOOKDOWN", "LOOKDOWNZ":
+			p.next()
+			return p.parseLookupExpression(tok, name)
+		}
+	}
+
+	var x ast.Expression = identifier(tok)
+	for {
+		tok := p.next()
+		switch tok.Type {
+		case token.PAREN_OPEN:
+			args, err := p.parseArguments()
+			if err != nil {
+				return nil, err
+			}
+			x = &ast.CallExpression{From: x.Pos(), To: p.end, Function: x, Arguments: args}
+
+		case token.BRACKET_OPEN:
+			index, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			end, err := p.expect(token.BRACKET_CLOSE, "']'")
+			if err != nil {
+				return nil, err
+			}
+			x = &ast.IndexExpression{From: x.Pos(), To: end.End, X: x, Index: index}
+
+		case token.DOT:
+			name, err := p.expect(token.IDENTIFIER, "method name")
+			if err != nil {
+				return nil, err
+			}
+			x = &ast.SelectorExpression{From: x.Pos(), To: name.End, X: x, Name: identifier(name)}
+
+		case token.POUND:
+			object, ok := x.(*ast.Identifier)
+			if !ok {
+				return nil, p.errorf(tok, "found %q, expected object name before '#'", tok.Literal)
+			}
+			name, err := p.expect(token.IDENTIFIER, "constant name")
+			if err != nil {
+				return nil, err
+			}
+			x = &ast.ObjectConstantExpression{From: x.Pos(), To: name.End, Object: object, Name: identifier(name)}
+
+		default:
+			p.unscan()
+			return x, nil
+		}
+	}
+}
+
+// identifier returns an identifier for tok.
+func identifier(tok token.Token) *ast.Identifier {
+	return &ast.Identifier{From: tok.Pos, To: tok.End, Name: tok.Literal}
+}
+
+// parseArguments parses a comma-separated argument list after the
+// opening parenthesis, up to and including the closing parenthesis.
+func (p *Parser) parseArguments() (args []ast.Expression, err error) {
+	if tok := p.next(); tok.Type == token.PAREN_CLOSE {
+		return nil, nil
+	}
+	p.unscan()
+
+	for {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if tok := p.next(); tok.Type == token.PAREN_CLOSE {
+			return args, nil
+		} else if tok.Type != token.COMMA {
+			return nil, p.errorf(tok, "found %q, expected ',' or ')'", tok.Literal)
+		}
+	}
+}
+
+// parseLookupExpression parses the body of a lookup expression, e.g.
+// lookupz(index : "0".."9", "A".."F").
+func (p *Parser) parseLookupExpression(start token.Token, name string) (ast.Expression, error) {
+	index, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(token.COLON, "':'"); err != nil {
+		return nil, err
+	}
+
+	x := &ast.LookupExpression{From: start.Pos, Function: name, Index: index}
+	for {
+		item, err := p.parseRangeExpression()
+		if err != nil {
+			return nil, err
+		}
+		x.List = append(x.List, item)
+
+		if tok := p.next(); tok.Type == token.PAREN_CLOSE {
+			x.To = tok.End
+			return x, nil
+		} else if tok.Type != token.COMMA {
+			return nil, p.errorf(tok, "found %q, expected ',' or ')'", tok.Literal)
+		}
+	}
+}
+
+// parseRangeExpression parses an expression that may be a range, as
+// found in case matches and lookup lists.
+func (p *Parser) parseRangeExpression() (ast.Expression, error) {
+	low, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok.Type != token.RANGE {
+		p.unscan()
+		return low, nil
+	}
+	high, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.RangeExpression{From: low.Pos(), To: p.end, Low: low, High: high}, nil
+}
+
+// parseMemoryExpression parses a direct memory access such as
+// byte[base] or word[base][index].
+func (p *Parser) parseMemoryExpression(size token.Token) (ast.Expression, error) {
+	if _, err := p.expect(token.BRACKET_OPEN, "'['"); err != nil {
+		return nil, err
+	}
+	base, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	end, err := p.expect(token.BRACKET_CLOSE, "']'")
+	if err != nil {
+		return nil, err
+	}
+
+	x := &ast.MemoryExpression{From: size.Pos, To: end.End, Size: size.Type, Base: base}
+	if tok := p.next(); tok.Type != token.BRACKET_OPEN {
+		p.unscan()
+		return x, nil
+	}
+	if x.Index, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	if end, err = p.expect(token.BRACKET_CLOSE, "']'"); err != nil {
+		return nil, err
+	}
+	x.To = end.End
+	return x, nil
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// NewFSParser returns a parser for the file name in fsys. Diagnostics are
+// reported against name.
+func NewFSParser(fsys fs.FS, name string) (*Parser, error) {
+	text, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileParser(name, bytes.NewReader(text)), nil
+}
+
+// An Overlay is a file system of in-memory files, such as the unsaved
+// buffers of an editor, over another file system. Files holds the text of
+// the overlaid files by name, and hides the file of the same name in FS.
+// Other files, and directories, are opened from FS if it is not nil, which
+// checks their names; directories do not list the overlaid files.
+type Overlay struct {
+	FS    fs.FS
+	Files map[string][]byte
+}
+
+// Open opens the named file.
+func (o *Overlay) Open(name string) (fs.File, error) {
+	if text, ok := o.Files[name]; ok {
+		return &overlayFile{Reader: bytes.NewReader(text), name: path.Base(name), size: int64(len(text))}, nil
+	}
+	if o.FS == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return o.FS.Open(name)
+}
+
+// ReadFile returns the text of the named file.
+func (o *Overlay) ReadFile(name string) ([]byte, error) {
+	if text, ok := o.Files[name]; ok {
+		return append([]byte(nil), text...), nil
+	}
+	if o.FS == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.ReadFile(o.FS, name)
+}
+
+// An overlayFile is an open in-memory file. It is its own fs.FileInfo.
+type overlayFile struct {
+	*bytes.Reader
+	name string
+	size int64
+}
+
+func (f *overlayFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *overlayFile) Close() error               { return nil }
+
+func (f *overlayFile) Name() string       { return f.name }
+func (f *overlayFile) Size() int64        { return f.size }
+func (f *overlayFile) Mode() fs.FileMode  { return 0444 }
+func (f *overlayFile) ModTime() time.Time { return time.Time{} }
+func (f *overlayFile) IsDir() bool        { return false }
+func (f *overlayFile) Sys() interface{}   { return nil }
@@ -3,109 +3,570 @@ package parser
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/cst"
+	"github.com/bweir/lame/diagnostic"
 	"github.com/bweir/lame/lexer"
 	"github.com/bweir/lame/token"
 )
 
 type Parser struct {
-	s   *lexer.Scanner
-	buf struct {
-		tok token.Token // last read token
-		n   int         // buffer size (max=1)
-	}
+	s           *lexer.Scanner
+	buf         tokenBuffer
+	marks       int        // number of marks held
+	keep        int        // last token of the oldest mark held
+	prev        token.Type // type of the last token read from the scanner
+	end         token.Pos  // end of the last token of the current node
+	prevEnd     token.Pos  // end before the last scan, for unscan
+	comments    comments
+	diagnostics diagnostic.List
+	tokens      []token.Token // tokens read from the scanner, for ParseCST
+	record      bool
 }
 
 func NewParser(r io.Reader) *Parser {
-	return &Parser{s: lexer.NewScanner(r)}
+	return NewFileParser("", r)
+}
+
+// NewFileParser returns a parser that reports diagnostics against
+// filename.
+func NewFileParser(filename string, r io.Reader) *Parser {
+	s := lexer.NewScanner(r)
+	s.File().Name = filename
+	return &Parser{s: s}
+}
+
+// NewTreeParser returns a parser for the source of tree, so that Parse
+// returns its AST. The source is scanned again, so the AST reflects any
+// edits to the tree.
+func NewTreeParser(filename string, tree *cst.Tree) *Parser {
+	return NewFileParser(filename, strings.NewReader(tree.Text()))
+}
+
+// File returns the line table of the parsed source, which maps node
+// positions to lines and columns.
+func (p *Parser) File() *token.File {
+	return p.s.File()
+}
+
+// Diagnostics returns the problems found so far.
+func (p *Parser) Diagnostics() diagnostic.List {
+	return p.diagnostics
 }
 
 func (p *Parser) scan() (tok token.Token) {
-	if p.buf.n != 0 {
-		p.buf.n = 0
-		return p.buf.tok
+	if p.buf.head == p.buf.tail {
+		p.fill()
 	}
+	tok = p.buf.at(p.buf.head)
+	p.buf.last = p.buf.head
+	p.buf.head++
 
-	tok = p.s.Scan()
-
-	p.buf.tok = tok
+	p.prevEnd = p.end
+	if !isLayout(tok) {
+		p.end = tok.End
+	}
 	return
 }
 
-func (p *Parser) unscan() { p.buf.n = 1 }
+// checkToken reports lexical errors. Each is reported once, when the
+// token is first read from the scanner.
+func (p *Parser) checkToken(tok token.Token) {
+	switch {
+	case tok.Type == token.ILLEGAL:
+		p.diagnostics.Add(diagnostic.Error, p.position(tok), "illegal-token", "invalid token %q", tok.Literal)
+	case tok.Type == token.UNEXPECTED_EOF:
+		p.diagnostics.Add(diagnostic.Error, p.position(tok), "unexpected-eof", "unexpected end of file")
+	case tok.Type == token.INDENT && p.prev == token.DEDENT:
+		// The scanner dedents to the enclosing level and then indents to
+		// the new one if a line does not match any enclosing level.
+		p.diagnostics.Add(diagnostic.Error, p.position(tok), "bad-dedent", "unindent does not match any outer indentation level")
+	}
+	p.prev = tok.Type
+}
+
+func (p *Parser) position(tok token.Token) token.Position {
+	return p.s.File().Position(tok.Pos)
+}
+
+// errorf returns a syntax error at tok.
+func (p *Parser) errorf(tok token.Token, format string, args ...interface{}) error {
+	return diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Pos:      p.position(tok),
+		Message:  fmt.Sprintf(format, args...),
+		Code:     "unexpected-token",
+	}
+}
+
+// report adds err to the diagnostics. Only the first problem on a line is
+// reported, as any others usually follow from it.
+func (p *Parser) report(err error) {
+	d, ok := err.(diagnostic.Diagnostic)
+	if !ok {
+		d = p.errorf(p.buf.at(p.buf.last), "%s", err).(diagnostic.Diagnostic)
+	}
+	if n := len(p.diagnostics); n > 0 {
+		last := p.diagnostics[n-1].Pos
+		if last.Filename == d.Pos.Filename && last.Line == d.Pos.Line {
+			return
+		}
+	}
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+// resync reports err and skips the rest of the line, so that parsing can
+// continue at the next line. An indent, dedent or block keyword also ends
+// the skipped text, and is left for the caller.
+func (p *Parser) resync(err error) {
+	p.report(err)
+	p.skip(false)
+}
+
+// skip skips the rest of the line from the last token returned, as
+// resync does. If list is set, it also stops after the next comma outside
+// parentheses and brackets, so that parsing can continue with the next
+// item of a list, and reports whether it found one.
+func (p *Parser) skip(list bool) bool {
+	tok := p.buf.at(p.buf.last)
+	p.buf.head = p.buf.last + 1
+	depth := 0
+	for {
+		switch {
+		case tok.Type == token.NEWLINE:
+			return false
+		case tok.Type == token.EOF || tok.Type == token.INDENT || tok.Type == token.DEDENT || isBlock(tok):
+			p.unscan()
+			return false
+		case tok.Type == token.PAREN_OPEN || tok.Type == token.BRACKET_OPEN:
+			depth++
+		case (tok.Type == token.PAREN_CLOSE || tok.Type == token.BRACKET_CLOSE) && depth > 0:
+			depth--
+		case tok.Type == token.COMMA && depth == 0 && list:
+			return true
+		}
+		tok = p.scan()
+	}
+}
+
+// unscan goes back to the last token returned by scan. Calling it again
+// has no effect.
+func (p *Parser) unscan() {
+	p.buf.head = p.buf.last
+	p.end = p.prevEnd
+}
+
+// next returns the next token on the current line, skipping spaces and
+// comments.
+func (p *Parser) next() (tok token.Token) {
+	tok = p.scan()
+	for tok.Type == token.SPACE || isComment(tok) {
+		tok = p.scan()
+	}
+	return
+}
 
 func (p *Parser) scanIgnoreWhitespace() (tok token.Token) {
 	tok = p.scan()
-	for tok.Type == token.SPACE || tok.Type == token.NEWLINE {
+	for tok.Type == token.SPACE || tok.Type == token.NEWLINE || isComment(tok) {
 		tok = p.scan()
 	}
 	return
 }
 
+// expect consumes the next token and returns an error if it is not of
+// type t.
+func (p *Parser) expect(t token.Type, what string) (tok token.Token, err error) {
+	tok = p.next()
+	if tok.Type != t {
+		return tok, p.errorf(tok, "found %q, expected %s", tok.Literal, what)
+	}
+	return tok, nil
+}
+
+// expectEndOfLine consumes the end of the current line. A dedent or the
+// end of the file also ends a line, but is left for the caller.
+func (p *Parser) expectEndOfLine() error {
+	tok := p.next()
+	switch tok.Type {
+	case token.NEWLINE:
+		return nil
+	case token.EOF, token.DEDENT:
+		p.unscan()
+		return nil
+	}
+	return p.errorf(tok, "found %q, expected end of line", tok.Literal)
+}
+
+// Parse parses an object. It returns the object even if there are syntax
+// errors, with error nodes in place of the broken source, along with a
+// diagnostic.List of the problems found.
 func (p *Parser) Parse() (*ast.Object, error) {
 	object := &ast.Object{}
 
-	var list []ast.Block
-	block, err := p.parseBlock()
+	for {
+		tok := p.scanIgnoreWhitespace()
+		for tok.Type == token.INDENT || tok.Type == token.DEDENT {
+			tok = p.scanIgnoreWhitespace()
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+		p.unscan()
+
+		object.Blocks = append(object.Blocks, p.parseBlock())
+	}
+	if n := len(object.Blocks); n > 0 {
+		object.From, object.To = object.Blocks[0].Pos(), object.Blocks[n-1].End()
+	}
+	object.Comments = p.comments.groups
+	return object, p.diagnostics.Err()
+}
+
+// ParseCST parses an object and returns its concrete syntax tree, which
+// keeps every token of the source. The tree of the object holds the AST
+// in its Node field. As with Parse, the tree is returned even if there
+// are syntax errors.
+func (p *Parser) ParseCST() (*cst.Tree, error) {
+	p.record = true
+	object, err := p.Parse()
+	for len(p.tokens) == 0 || p.tokens[len(p.tokens)-1].Type != token.EOF {
+		p.scan()
+	}
+	return cst.New(object, p.tokens, p.s.File(), p.s.Source()), err
+}
+
+// ParseExpression parses a single expression.
+func (p *Parser) ParseExpression() (ast.Expression, error) {
+	x, err := p.parseExpression()
 	if err != nil {
-		fmt.Println(err)
+		return nil, err
 	}
-	list = append(list, block)
-	object.Blocks = list
-	return object, nil
+	if tok := p.scanIgnoreWhitespace(); tok.Type != token.EOF {
+		return nil, p.errorf(tok, "found %q, expected end of expression", tok.Literal)
+	}
+	return x, nil
 }
 
-func (p *Parser) parseBlock() (block ast.Block, err error) {
+func (p *Parser) parseBlock() ast.Block {
 	tok := p.scanIgnoreWhitespace()
-	fmt.Printf("%q\n", tok)
-	if !isBlock(tok) {
-		return nil, fmt.Errorf("expecting block, I guess, found %q", tok.Literal)
-	}
 
 	switch tok.Type {
 	case token.CON:
-		p.unscan()
-		block, err = p.parseConBlock()
+		return p.parseConBlock(tok)
+	case token.DAT:
+		return p.parseDatBlock(tok)
 	case token.OBJ:
-		block = &ast.ObjBlock{}
+		return p.parseObjBlock(tok)
+	case token.PRI:
+		return p.parsePriBlock(tok)
 	case token.PUB:
-		block = &ast.PubBlock{}
+		return p.parsePubBlock(tok)
+	case token.VAR:
+		return p.parseVarBlock(tok)
 	}
-	return block, nil
+
+	p.report(p.errorf(tok, "found %q, expected block", tok.Literal))
+	block := &ast.BadBlock{From: tok.Pos}
+	for tok.Type != token.EOF && !isBlock(tok) {
+		tok = p.scan()
+	}
+	p.unscan()
+	block.To = p.end
+	return block
 }
 
-func (p *Parser) parseConBlock() (block *ast.ConBlock, err error) {
+// nextDeclaration skips to the start of the next declaration in a block.
+// It returns false at the end of the block.
+func (p *Parser) nextDeclaration() bool {
 	tok := p.scanIgnoreWhitespace()
-	fmt.Printf("%q\n", tok)
+	for tok.Type == token.INDENT || tok.Type == token.DEDENT {
+		tok = p.scanIgnoreWhitespace()
+	}
+	p.unscan()
+	return tok.Type != token.EOF && !isBlock(tok)
+}
 
-	block = &ast.ConBlock{}
+func (p *Parser) parseConBlock(keyword token.Token) *ast.ConBlock {
+	block := &ast.ConBlock{From: keyword.Pos}
+	for p.nextDeclaration() {
+		from := p.peek(1).Pos
+		if err := p.parseConstantDeclarations(block); err != nil {
+			block.Declarations = append(block.Declarations, p.badDeclaration(from, err))
+		}
+	}
+	block.To = p.end
+	return block
+}
+
+// badDeclaration reports err and returns a BadDeclaration from the start
+// of the declaration to the end of the line.
+func (p *Parser) badDeclaration(from token.Pos, err error) *ast.BadDeclaration {
+	decl := &ast.BadDeclaration{From: from}
+	p.resync(err)
+	decl.To = p.end
+	return decl
+}
+
+// parseConstantDeclarations parses a line of comma-separated constant
+// declarations. A declaration with an error is reported and skipped up
+// to the next comma, so that the others on the line are kept.
+func (p *Parser) parseConstantDeclarations(block *ast.ConBlock) error {
 	for {
-		tok = p.scanIgnoreWhitespace()
-		if tok.Type != token.IDENTIFIER {
-			return nil, fmt.Errorf("found %q, expected identifier", tok.Literal)
+		from := p.peek(1).Pos
+		decl, err := p.parseConstantDeclaration()
+		if decl != nil {
+			block.Declarations = append(block.Declarations, decl)
+		}
+		if err != nil {
+			p.report(err)
+			found := p.skip(true)
+			if decl == nil {
+				bad := &ast.BadDeclaration{From: from, To: p.end}
+				if found {
+					bad.To = p.prevEnd
+				}
+				block.Declarations = append(block.Declarations, bad)
+			}
+			if found {
+				continue
+			}
+			return nil
 		}
-		name := tok.Literal
 
-		tok = p.scanIgnoreWhitespace()
-		if tok.Type != token.ASSIGN {
-			return nil, fmt.Errorf("found %q, expected assignment", tok.Literal)
+		if tok := p.next(); tok.Type != token.COMMA {
+			p.unscan()
+			break
 		}
+	}
+	return p.expectEndOfLine()
+}
 
-		tok = p.scanIgnoreWhitespace()
-		if tok.Type != token.DECIMAL_NUMBER {
-			return nil, fmt.Errorf("found %q, expected assignment", tok.Literal)
+// parseConstantDeclaration parses a constant declaration. A constant with
+// a broken value is still returned with a bad expression, so that
+// references to it are not reported as well.
+func (p *Parser) parseConstantDeclaration() (*ast.ConstantDeclaration, error) {
+	name, err := p.expect(token.IDENTIFIER, "identifier")
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.next(); tok.Type != token.ASSIGN {
+		return nil, p.errorf(tok, "found %q, expected assignment", tok.Literal)
+	}
+
+	decl := &ast.ConstantDeclaration{From: name.Pos, Name: name.Literal}
+	if decl.Value, err = p.parseExpression(); err != nil {
+		tok := p.buf.at(p.buf.last)
+		decl.Value = &ast.BadExpression{From: tok.Pos, To: tok.End}
+		decl.To = tok.End
+		return decl, err
+	}
+	decl.To = p.end
+	return decl, nil
+}
+
+func (p *Parser) parseObjBlock(keyword token.Token) *ast.ObjBlock {
+	block := &ast.ObjBlock{From: keyword.Pos}
+	for p.nextDeclaration() {
+		from := p.peek(1).Pos
+		decl, err := p.parseObjectDeclaration()
+		if err != nil {
+			block.Declarations = append(block.Declarations, p.badDeclaration(from, err))
+			continue
 		}
-		value := tok.Literal
-		decl := &ast.ConstantDeclaration{Name: name, Value: value}
-		block.Declarations = append(block.Declarations, *decl)
+		block.Declarations = append(block.Declarations, decl)
+	}
+	block.To = p.end
+	return block
+}
 
-		tok = p.scanIgnoreWhitespace()
-		p.unscan()
-		if tok.Type != token.IDENTIFIER {
+func (p *Parser) parseObjectDeclaration() (*ast.ObjectDeclaration, error) {
+	tok, err := p.expect(token.IDENTIFIER, "object name")
+	if err != nil {
+		return nil, err
+	}
+	decl := &ast.ObjectDeclaration{From: tok.Pos, Name: tok.Literal}
+
+	if decl.Count, err = p.parseCount(); err != nil {
+		return nil, err
+	}
+
+	if tok = p.next(); tok.Type != token.COLON {
+		return nil, p.errorf(tok, "found %q, expected ':'", tok.Literal)
+	}
+
+	if tok, err = p.expect(token.STRING, "object path"); err != nil {
+		return nil, err
+	}
+	decl.Path = tok.Literal
+	decl.To = tok.End
+
+	return decl, p.expectEndOfLine()
+}
+
+func (p *Parser) parseVarBlock(keyword token.Token) *ast.VarBlock {
+	block := &ast.VarBlock{From: keyword.Pos}
+	for p.nextDeclaration() {
+		from := p.peek(1).Pos
+		if err := p.parseVariableDeclarations(block); err != nil {
+			block.Declarations = append(block.Declarations, p.badDeclaration(from, err))
+		}
+	}
+	block.To = p.end
+	return block
+}
+
+// parseVariableDeclarations parses a line of comma-separated variable
+// declarations of the same size.
+func (p *Parser) parseVariableDeclarations(block *ast.VarBlock) error {
+	size := p.next()
+	if !isSize(size) {
+		return p.errorf(size, "found %q, expected byte, word or long", size.Literal)
+	}
+
+	for {
+		tok, err := p.expect(token.IDENTIFIER, "variable name")
+		if err != nil {
+			return err
+		}
+		decl := &ast.VariableDeclaration{From: tok.Pos, Size: size.Type, Name: tok.Literal}
+		if decl.Count, err = p.parseCount(); err != nil {
+			return err
+		}
+		decl.To = p.end
+		block.Declarations = append(block.Declarations, decl)
+
+		if tok = p.next(); tok.Type != token.COMMA {
+			p.unscan()
 			break
 		}
 	}
+	return p.expectEndOfLine()
+}
 
-	return
+// parseCount parses an optional array count, e.g. the [32] in
+// "byte stack[32]".
+func (p *Parser) parseCount() (ast.Expression, error) {
+	if tok := p.next(); tok.Type != token.BRACKET_OPEN {
+		p.unscan()
+		return nil, nil
+	}
+	count, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(token.BRACKET_CLOSE, "']'"); err != nil {
+		return nil, err
+	}
+	return count, nil
+}
+
+func (p *Parser) parsePubBlock(keyword token.Token) *ast.PubBlock {
+	m := p.parseMethod()
+	return &ast.PubBlock{
+		From:       keyword.Pos,
+		To:         m.end,
+		Name:       m.name,
+		Parameters: m.parameters,
+		Result:     m.result,
+		Locals:     m.locals,
+		Body:       m.body,
+	}
+}
+
+func (p *Parser) parsePriBlock(keyword token.Token) *ast.PriBlock {
+	m := p.parseMethod()
+	return &ast.PriBlock{
+		From:       keyword.Pos,
+		To:         m.end,
+		Name:       m.name,
+		Parameters: m.parameters,
+		Result:     m.result,
+		Locals:     m.locals,
+		Body:       m.body,
+	}
+}
+
+// method holds the parts shared by PUB and PRI blocks.
+type method struct {
+	name       string
+	parameters []*ast.Identifier
+	result     *ast.Identifier
+	locals     []*ast.LocalDeclaration
+	body       []ast.Statement
+	end        token.Pos
+}
+
+// parseMethod parses a method. If the header has a syntax error, the
+// body is still parsed.
+func (p *Parser) parseMethod() (m method) {
+	if err := p.parseMethodHeader(&m); err != nil {
+		p.resync(err)
+	}
+	m.body = p.parseStatementList(false)
+	m.end = p.end
+	return m
+}
+
+func (p *Parser) parseMethodHeader(m *method) error {
+	tok, err := p.expect(token.IDENTIFIER, "method name")
+	if err != nil {
+		return err
+	}
+	m.name = tok.Literal
+
+	if tok = p.next(); tok.Type == token.PAREN_OPEN {
+		for {
+			tok, err = p.expect(token.IDENTIFIER, "parameter name")
+			if err != nil {
+				return err
+			}
+			m.parameters = append(m.parameters, identifier(tok))
+
+			if tok = p.next(); tok.Type == token.PAREN_CLOSE {
+				break
+			} else if tok.Type != token.COMMA {
+				return p.errorf(tok, "found %q, expected ',' or ')'", tok.Literal)
+			}
+		}
+	} else {
+		p.unscan()
+	}
+
+	if tok = p.next(); tok.Type == token.COLON {
+		tok, err = p.expect(token.IDENTIFIER, "result name")
+		if err != nil {
+			return err
+		}
+		m.result = identifier(tok)
+	} else {
+		p.unscan()
+	}
+
+	if tok = p.next(); tok.Type == token.BITWISE_OR {
+		for {
+			tok, err = p.expect(token.IDENTIFIER, "local variable name")
+			if err != nil {
+				return err
+			}
+			local := &ast.LocalDeclaration{From: tok.Pos, Name: tok.Literal}
+			if local.Count, err = p.parseCount(); err != nil {
+				return err
+			}
+			local.To = p.end
+			m.locals = append(m.locals, local)
+
+			if tok = p.next(); tok.Type != token.COMMA {
+				p.unscan()
+				break
+			}
+		}
+	} else {
+		p.unscan()
+	}
+
+	return p.expectEndOfLine()
 }
@@ -0,0 +1,666 @@
+package parser_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/parser"
+	"github.com/bweir/lame/token"
+)
+
+// Ensure the parser respects Spin operator precedence.
+func TestParser_ParseExpression(t *testing.T) {
+	var tests = []struct {
+		src  string
+		want string
+	}{
+		// Literals
+		{src: `42`, want: `42`},
+		{src: `$FF`, want: `FF`},
+		{src: `1.5`, want: `1.5`},
+		{src: `"A"`, want: `"A"`},
+		{src: `true`, want: `true`},
+
+		// Binary operators
+		{src: `a + b * c`, want: `(ADD a (MULTIPLY b c))`},
+		{src: `a - b - c`, want: `(SUBTRACT (SUBTRACT a b) c)`},
+		{src: `a * b + c`, want: `(ADD (MULTIPLY a b) c)`},
+		{src: `a | b & c`, want: `(BITWISE_OR a (BITWISE_AND b c))`},
+		{src: `a & b << c`, want: `(BITWISE_AND a (BITWISE_SHIFT_LEFT b c))`},
+		{src: `a * b | c`, want: `(MULTIPLY a (BITWISE_OR b c))`},
+		{src: `a ** b // c`, want: `(MODULO (MULTIPLY_HIGH a b) c)`},
+		{src: `a + b #> c <# d`, want: `(LIMIT_MAXIMUM (LIMIT_MINIMUM (ADD a b) c) d)`},
+		{src: `a #> b == c`, want: `(EQUAL_TO (LIMIT_MINIMUM a b) c)`},
+		{src: `a == b or c <> d and e`, want: `(OR (EQUAL_TO a b) (AND (NOT_EQUAL_TO c d) e))`},
+		{src: `a => b`, want: `(GREATER_THAN_EQUAL_TO a b)`},
+		{src: `a ~> 2 >< 3`, want: `(BITWISE_REVERSE (BITWISE_SIGNED_SHIFT_RIGHT a 2) 3)`},
+
+		// Unary operators
+		{src: `-a * b`, want: `(MULTIPLY (SUBTRACT a) b)`},
+		{src: `!a & b`, want: `(BITWISE_AND (BITWISE_NOT a) b)`},
+		{src: `not a == b`, want: `(NOT (EQUAL_TO a b))`},
+		{src: `not a and b`, want: `(AND (NOT a) b)`},
+		{src: `||(a + b)`, want: `(ABSOLUTE (paren (ADD a b)))`},
+		{src: `|<a >> 1`, want: `(BITWISE_SHIFT_RIGHT (DECODE a) 1)`},
+		{src: `>|a`, want: `(ENCODE a)`},
+		{src: `^^a`, want: `(SQUARE_ROOT a)`},
+		{src: `@x + 4`, want: `(ADD (AT x) 4)`},
+		{src: `@@x`, want: `(AT_AT x)`},
+		{src: `~x`, want: `(BITWISE_SIGN_EXTEND_7 x)`},
+		{src: `~~x`, want: `(BITWISE_SIGN_EXTEND_15 x)`},
+		{src: `?x`, want: `(RANDOM x)`},
+		{src: `++x`, want: `(INCREMENT x)`},
+		{src: `--x`, want: `(DECREMENT x)`},
+
+		// Postfix operators
+		{src: `x++`, want: `(post-INCREMENT x)`},
+		{src: `x--`, want: `(post-DECREMENT x)`},
+		{src: `x~`, want: `(post-BITWISE_SIGN_EXTEND_7 x)`},
+		{src: `result~~`, want: `(post-BITWISE_SIGN_EXTEND_15 result)`},
+		{src: `x?`, want: `(post-RANDOM x)`},
+		{src: `byte[p++] - c`, want: `(SUBTRACT (BYTE (post-INCREMENT p) nil) c)`},
+
+		// Assignments
+		{src: `x := y`, want: `(ASSIGN x y)`},
+		{src: `x := y := 3`, want: `(ASSIGN x (ASSIGN y 3))`},
+		{src: `x += a * b`, want: `(ADD_ASSIGN x (MULTIPLY a b))`},
+		{src: `(v <-= 1) & 1`, want: `(BITWISE_AND (paren (BITWISE_ROTATE_LEFT_ASSIGN v 1)) 1)`},
+		{src: `x[i] := y`, want: `(ASSIGN (index x i) y)`},
+		{src: `x <= y`, want: `(LESS_THAN_ASSIGN x y)`},
+		{src: `x >= y + 1`, want: `(GREATER_THAN_ASSIGN x (ADD y 1))`},
+		{src: `x === y`, want: `(EQUAL_TO_ASSIGN x y)`},
+		{src: `x <>= y`, want: `(NOT_EQUAL_TO_ASSIGN x y)`},
+		{src: `x =<= y`, want: `(LESS_THAN_EQUAL_TO_ASSIGN x y)`},
+		{src: `x =>= y`, want: `(GREATER_THAN_EQUAL_TO_ASSIGN x y)`},
+		{src: `ok AND= x =< y`, want: `(AND_ASSIGN ok (LESS_THAN_EQUAL_TO x y))`},
+		{src: `(ok or= x => y) == 0`, want: `(EQUAL_TO (paren (OR_ASSIGN ok (GREATER_THAN_EQUAL_TO x y))) 0)`},
+
+		// Memory, objects and built-ins
+		{src: `byte[base]`, want: `(BYTE base nil)`},
+		{src: `word[font][gfx#SX]`, want: `(WORD font (const gfx SX))`},
+		{src: `long[@x][1]`, want: `(LONG (AT x) 1)`},
+		{src: `Char("-", x, y)`, want: `(call Char "-" x y)`},
+		{src: `Start()`, want: `(call Start)`},
+		{src: `gfx.Sprite(font, x)`, want: `(call (select gfx Sprite) font x)`},
+		{src: `gfx[i].Sprite`, want: `(select (index gfx i) Sprite)`},
+		{src: `gfx#SX * 2`, want: `(MULTIPLY (const gfx SX) 2)`},
+		{src: `string("hi", 13)`, want: `(string "hi" 13)`},
+		{src: `constant(A << 2)`, want: `(constant (BITWISE_SHIFT_LEFT A 2))`},
+		{src: `lookupz(v & $F : "0".."9", "A")`, want: `(LOOKUPZ (BITWISE_AND v F) (range "0" "9") "A")`},
+	}
+
+	for i, tt := range tests {
+		x, err := parser.NewParser(strings.NewReader(tt.src)).ParseExpression()
+		if err != nil {
+			t.Errorf("%d. %q unexpected error: %s", i, tt.src, err)
+		} else if got := ast.SExpr(x); got != tt.want {
+			t.Errorf("%d. %q mismatch: exp=%s got=%s", i, tt.src, tt.want, got)
+		}
+	}
+}
+
+// Ensure the parser builds statements for method bodies.
+func TestParser_Parse_Method(t *testing.T) {
+	src := `PUB Box(s, x, y) : r | c, buf[4]
+    repeat strsize(s)
+        c := byte[s++]
+        if c == 10
+            y += 8
+        elseif c == " "
+            x += 8
+        else
+            quit
+    repeat i from 0 to 3 step 1
+        buf[i]~
+    repeat
+        r++
+    until r > 3
+    case c
+        "0".."9", "A": r := 1
+        other:
+            return r
+`
+	object, err := parser.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(object.Blocks) != 1 {
+		t.Fatalf("expected 1 block, got %d", len(object.Blocks))
+	}
+
+	pub, ok := object.Blocks[0].(*ast.PubBlock)
+	if !ok {
+		t.Fatalf("expected *ast.PubBlock, got %T", object.Blocks[0])
+	}
+	if pub.Name != "Box" || len(pub.Parameters) != 3 || pub.Result.Name != "r" || len(pub.Locals) != 2 {
+		t.Errorf("unexpected method header: %+v", pub)
+	}
+	if len(pub.Body) != 4 {
+		t.Fatalf("expected 4 statements, got %d", len(pub.Body))
+	}
+
+	repeat := pub.Body[0].(*ast.RepeatStatement)
+	if ast.SExpr(repeat.Count) != "(call strsize s)" || len(repeat.Body) != 2 {
+		t.Errorf("unexpected repeat: %s, %d statements", ast.SExpr(repeat.Count), len(repeat.Body))
+	}
+	ifStmt := repeat.Body[1].(*ast.IfStatement)
+	elseIf := ifStmt.Else.(*ast.IfStatement)
+	if elseIf.Keyword != "ELSEIF" {
+		t.Errorf("expected ELSEIF, got %s", elseIf.Keyword)
+	}
+	if _, ok := elseIf.Else.(*ast.ElseStatement).Body[0].(*ast.QuitStatement); !ok {
+		t.Errorf("expected quit in else branch")
+	}
+
+	if r := pub.Body[1].(*ast.RepeatRangeStatement); ast.SExpr(r.Step) != "1" {
+		t.Errorf("unexpected step: %s", ast.SExpr(r.Step))
+	}
+	if r := pub.Body[2].(*ast.RepeatWhileStatement); !r.Post || r.Keyword != "UNTIL" {
+		t.Errorf("expected post-condition until, got %+v", r)
+	}
+
+	c := pub.Body[3].(*ast.CaseStatement)
+	if len(c.Arms) != 2 || len(c.Arms[0].Matches) != 2 || !c.Arms[1].Other {
+		t.Errorf("unexpected case arms: %+v", c.Arms)
+	}
+}
+
+// Ensure every passing object in the test tree parses.
+func TestParser_Parse_Files(t *testing.T) {
+	err := filepath.Walk("../test", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".spin" {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = parser.NewFileParser(path, f).Parse()
+		if fail := strings.HasPrefix(info.Name(), "fail-"); fail && err == nil {
+			t.Errorf("%s: expected errors", path)
+		} else if !fail && err != nil {
+			t.Errorf("%s: %s", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure the parser reports every syntax error, and recovers at the next
+// line or block to keep the blocks around them.
+func TestParser_Parse_Recovery(t *testing.T) {
+	var tests = []struct {
+		in          string
+		diagnostics []string // line:code
+		blocks      []string
+	}{
+		{
+			in:          "CON\n  a = 1\n  b = )\n  c = 3\n",
+			diagnostics: []string{"3:unexpected-token"},
+			blocks:      []string{"*ast.ConBlock"},
+		},
+		{
+			in:          "junk here\nCON\n  a = 1\n",
+			diagnostics: []string{"1:unexpected-token"},
+			blocks:      []string{"*ast.BadBlock", "*ast.ConBlock"},
+		},
+		{
+			in:          "PUB main\n  a := 1 ~ 2\n  b := ¤\n  c := 3\n",
+			diagnostics: []string{"2:unexpected-token", "3:illegal-token"},
+			blocks:      []string{"*ast.PubBlock"},
+		},
+		{
+			in:          "PUB main(a\n  a := 1\nPRI helper\n  return (\nVAR\n  long x\n  y\n",
+			diagnostics: []string{"1:unexpected-token", "4:unexpected-token", "7:unexpected-token"},
+			blocks:      []string{"*ast.PubBlock", "*ast.PriBlock", "*ast.VarBlock"},
+		},
+		{
+			in:          "PUB null | x\n        x = 5\n    b = 123\na = 3\n",
+			diagnostics: []string{"3:bad-dedent"},
+			blocks:      []string{"*ast.PubBlock"},
+		},
+		{
+			in:          "DAT\n  mov a, \n  nop\nlabel long 1\nOBJ\n  x : 5\n",
+			diagnostics: []string{"2:unexpected-token", "6:unexpected-token"},
+			blocks:      []string{"*ast.DatBlock", "*ast.ObjBlock"},
+		},
+		{
+			in:          "OBJ\n  a = \"a\"\n  b := \"b\"\n  c : \"c\"\n",
+			diagnostics: []string{"2:unexpected-token", "3:unexpected-token"},
+			blocks:      []string{"*ast.ObjBlock"},
+		},
+		{
+			in:          "PUB main\n  case x\n    1: a\n    2 b\n    other: c\n",
+			diagnostics: []string{"4:unexpected-token"},
+			blocks:      []string{"*ast.PubBlock"},
+		},
+		{
+			in:          "PUB main\n  s := string(\"abc",
+			diagnostics: []string{"2:unexpected-eof"},
+			blocks:      []string{"*ast.PubBlock"},
+		},
+	}
+
+	for i, tt := range tests {
+		p := parser.NewParser(strings.NewReader(tt.in))
+		object, err := p.Parse()
+		if err == nil {
+			t.Errorf("%d. %q: expected errors", i, tt.in)
+		}
+
+		var diagnostics []string
+		for _, d := range p.Diagnostics() {
+			diagnostics = append(diagnostics, fmt.Sprintf("%d:%s", d.Pos.Line, d.Code))
+		}
+		if got, want := strings.Join(diagnostics, " "), strings.Join(tt.diagnostics, " "); got != want {
+			t.Errorf("%d. %q diagnostics mismatch:\n  exp=%s\n  got=%s", i, tt.in, want, got)
+		}
+
+		var blocks []string
+		for _, b := range object.Blocks {
+			blocks = append(blocks, fmt.Sprintf("%T", b))
+		}
+		if got, want := strings.Join(blocks, " "), strings.Join(tt.blocks, " "); got != want {
+			t.Errorf("%d. %q blocks mismatch:\n  exp=%s\n  got=%s", i, tt.in, want, got)
+		}
+	}
+}
+
+// Ensure a declaration or statement with an error is replaced by a bad
+// node, keeping the others on the same line and in the same block.
+func TestParser_Parse_Recovery_Partial(t *testing.T) {
+	in := "CON\n  a = 1, b = ), c = 3\n  d = 4, 5 = e, f = (6)\nPUB main\n  x := 1\n  y := )\n  z := 3\n"
+	object, err := parser.NewParser(strings.NewReader(in)).Parse()
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+
+	con := object.Blocks[0].(*ast.ConBlock)
+	var names []string
+	for _, decl := range con.Declarations {
+		switch decl := decl.(type) {
+		case *ast.ConstantDeclaration:
+			names = append(names, decl.Name)
+		case *ast.BadDeclaration:
+			names = append(names, "<bad>")
+		}
+	}
+	if got, want := strings.Join(names, " "), "a b c d <bad> f"; got != want {
+		t.Errorf("constants: exp=%s got=%s", want, got)
+	}
+	if _, ok := con.Declarations[1].(*ast.ConstantDeclaration).Value.(*ast.BadExpression); !ok {
+		t.Errorf("constants: expected bad expression for b")
+	}
+	if bad := con.Declarations[4]; in[bad.Pos()-1:bad.End()-1] != "5 = e" {
+		t.Errorf("constants: unexpected bad declaration %q", in[bad.Pos()-1:bad.End()-1])
+	}
+
+	pub := object.Blocks[1].(*ast.PubBlock)
+	var stmts []string
+	for _, stmt := range pub.Body {
+		stmts = append(stmts, fmt.Sprintf("%T", stmt))
+	}
+	if got, want := strings.Join(stmts, " "), "*ast.ExpressionStatement *ast.BadStatement *ast.ExpressionStatement"; got != want {
+		t.Errorf("statements: exp=%s got=%s", want, got)
+	}
+}
+
+// checkPositions checks that n and every node below it has a source
+// range, and that children lie within their parents.
+func checkPositions(t *testing.T, path string, file *token.File, n ast.Node) {
+	from, to := n.Pos(), n.End()
+	if !from.IsValid() || to < from {
+		t.Errorf("%s: %T has bad range %s-%s", path, n, file.Position(from), file.Position(to))
+		return
+	}
+
+	var visit func(v reflect.Value)
+	visit = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr:
+			if v.IsNil() {
+				return
+			}
+			if child, ok := v.Interface().(ast.Node); ok {
+				if child.Pos() < from || child.End() > to {
+					t.Errorf("%s: %T at %s is outside its parent %T", path, child, file.Position(child.Pos()), n)
+				}
+				checkPositions(t, path, file, child)
+				return
+			}
+			visit(v.Elem())
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				visit(v.Index(i))
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				visit(v.Field(i))
+			}
+		}
+	}
+	visit(reflect.ValueOf(n).Elem())
+}
+
+// Ensure every node parsed from the test files has a source range, within
+// the range of its parent.
+func TestParser_Parse_Positions(t *testing.T) {
+	err := filepath.Walk("../test", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".spin" || strings.HasPrefix(info.Name(), "fail-") {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		p := parser.NewFileParser(path, f)
+		object, err := p.Parse()
+		if err != nil {
+			return err
+		}
+		for _, block := range object.Blocks {
+			checkPositions(t, path, p.File(), block)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure nodes span their source text, from the first token to the last,
+// without trailing comments or newlines.
+func TestParser_Parse_Ranges(t *testing.T) {
+	src := "CON\n  a = 1 + 2 ' one\nPUB main : r | x[2]\n  repeat x from 0 to 3\n    r += byte[@x][1]\n  return lib#C\n"
+	var exp = []string{
+		"ConBlock: CON\n  a = 1 + 2",
+		"ConstantDeclaration: a = 1 + 2",
+		"PubBlock: PUB main : r | x[2]\n  repeat x from 0 to 3\n    r += byte[@x][1]\n  return lib#C",
+		"LocalDeclaration: x[2]",
+		"RepeatRangeStatement: repeat x from 0 to 3\n    r += byte[@x][1]",
+		"AssignmentExpression: r += byte[@x][1]",
+		"MemoryExpression: byte[@x][1]",
+		"ReturnStatement: return lib#C",
+		"ObjectConstantExpression: lib#C",
+	}
+
+	p := parser.NewParser(strings.NewReader(src))
+	object, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := p.File()
+	text := func(n ast.Node) string {
+		return fmt.Sprintf("%s: %s", reflect.TypeOf(n).Elem().Name(), src[file.Offset(n.Pos()):file.Offset(n.End())])
+	}
+
+	con := object.Blocks[0].(*ast.ConBlock)
+	pub := object.Blocks[1].(*ast.PubBlock)
+	repeat := pub.Body[0].(*ast.RepeatRangeStatement)
+	assign := repeat.Body[0].(*ast.ExpressionStatement).X.(*ast.AssignmentExpression)
+	ret := pub.Body[1].(*ast.ReturnStatement)
+	got := []string{
+		text(con),
+		text(con.Declarations[0]),
+		text(pub),
+		text(pub.Locals[0]),
+		text(repeat),
+		text(assign),
+		text(assign.Value),
+		text(ret),
+		text(ret.Value),
+	}
+
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("%d. range mismatch:\n  exp=%q\n  got=%q", i, exp[i], got[i])
+		}
+	}
+}
+
+// Ensure the parser reads DAT labels and data directives.
+func TestParser_Parse_Dat(t *testing.T) {
+	src := `DAT
+font        word    0
+table
+            byte    1, 2, 3
+            long
+pad         byte    0[16], word $FFAA
+name        byte    "LameStation", 0
+sprite      file    "sprite.dat"
+`
+	object, err := parser.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dat := object.Blocks[0].(*ast.DatBlock)
+
+	var got []string
+	for _, entry := range dat.Entries {
+		switch e := entry.(type) {
+		case *ast.Label:
+			got = append(got, fmt.Sprintf("%s:%s", e.Name, e.Size))
+		case *ast.DataDirective:
+			var values []string
+			for _, v := range e.Values {
+				s := ast.SExpr(v.Value)
+				if v.Size != "" {
+					s = string(v.Size) + " " + s
+				}
+				if v.Count != nil {
+					s += "[" + ast.SExpr(v.Count) + "]"
+				}
+				values = append(values, s)
+			}
+			got = append(got, fmt.Sprintf("%s(%s)", e.Size, strings.Join(values, ", ")))
+		case *ast.FileDirective:
+			got = append(got, fmt.Sprintf("file(%s)", e.Path))
+		}
+	}
+
+	want := []string{
+		"font:WORD", "WORD(0)",
+		"table:BYTE", "BYTE(1, 2, 3)",
+		"LONG()",
+		"pad:BYTE", "BYTE(0[16], WORD FFAA)",
+		"name:BYTE", `BYTE("LameStation", 0)`,
+		"sprite:BYTE", "file(sprite.dat)",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("DAT mismatch:\nexp=%v\ngot=%v", want, got)
+	}
+}
+
+// Ensure LameStation library code parses without diagnostics.
+func TestParser_Parse_LameStation(t *testing.T) {
+	for _, name := range []string{"../test/LameText.spin"} {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := parser.NewFileParser(name, f)
+		object, err := p.Parse()
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if len(p.Diagnostics()) != 0 {
+			t.Errorf("%s: unexpected diagnostics: %v", name, p.Diagnostics())
+		} else if len(object.Blocks) == 0 {
+			t.Errorf("%s: expected blocks", name)
+		}
+	}
+}
+
+// Ensure the parser reads PASM instructions, directives and local labels.
+func TestParser_Parse_Pasm(t *testing.T) {
+	f, err := os.Open("../test/dat/pasm.spin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	object, err := parser.NewParser(f).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dat *ast.DatBlock
+	for _, block := range object.Blocks {
+		if b, ok := block.(*ast.DatBlock); ok {
+			dat = b
+		}
+	}
+	if dat == nil {
+		t.Fatal("expected a DAT block")
+	}
+
+	var got []string
+	for _, entry := range dat.Entries {
+		switch e := entry.(type) {
+		case *ast.Label:
+			got = append(got, fmt.Sprintf("%s:%s", e.Name, e.Size))
+		case *ast.Instruction:
+			s := e.Opcode
+			if e.Condition != "" {
+				s = e.Condition + " " + s
+			}
+			if e.Destination != nil {
+				s += " " + ast.SExpr(e.Destination)
+			}
+			if e.Source != nil {
+				if e.Immediate {
+					s += " #" + ast.SExpr(e.Source)
+				} else {
+					s += " " + ast.SExpr(e.Source)
+				}
+			}
+			if len(e.Effects) > 0 {
+				s += " " + strings.Join(e.Effects, ",")
+			}
+			got = append(got, s)
+		case *ast.OrgDirective:
+			got = append(got, "ORG "+ast.SExpr(e.Address))
+		case *ast.ResDirective:
+			got = append(got, "RES "+ast.SExpr(e.Count))
+		case *ast.FitDirective:
+			got = append(got, "FIT "+ast.SExpr(e.Address))
+		case *ast.DataDirective:
+			got = append(got, fmt.Sprintf("%s(%d)", e.Size, len(e.Values)))
+		}
+	}
+	all := strings.Join(got, "\n")
+
+	for _, want := range []string{
+		"ORG 0",
+		"entry:LONG\nMOV dira diramask",
+		"loop:LONG\nRDLONG eins par WZ",
+		"IF_Z JMP #loop",
+		":page:LONG\nMOV eins rcnt",
+		"OR eins CMD_SetPage",
+		"CALL #sendLCDcommand",
+		"MOV ccnt #(DIVIDE SCREEN_W 4)",
+		"DJNZ ccnt #:column",
+		"SHL eins #1 WC",
+		"TEST eins #80 WZ,WC",
+		"IF_NZ_AND_C NOP",
+		"JMP #(ADD $ 1)",
+		"sendLCDcommand_ret:LONG\nsendLCDdata_ret:LONG\nRET",
+		"diramask:LONG\nLONG(1)",
+		"eins:LONG\nRES 1",
+		"FIT 496",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("missing %q in:\n%s", want, all)
+		}
+	}
+}
+
+// Ensure objects are parsed from a file system, with unsaved files in an
+// overlay taking the place of the files on disk.
+func TestNewFSParser(t *testing.T) {
+	fsys := &parser.Overlay{
+		FS: fstest.MapFS{
+			"led.spin":  {Data: []byte("PUB on\n")},
+			"main.spin": {Data: []byte("PUB main\n")},
+		},
+		Files: map[string][]byte{
+			"main.spin":  []byte("PUB main\n  x :=\n"),
+			"extra.spin": []byte("PRI extra\n"),
+		},
+	}
+
+	for _, tt := range []struct {
+		name string
+		exp  string
+		err  string
+	}{
+		{name: "led.spin", exp: `(Object :blocks ((PubBlock :name "on")))`},
+		{name: "extra.spin", exp: `(Object :blocks ((PriBlock :name "extra")))`},
+		{name: "main.spin", err: `main.spin:2:7: error: found "\n", expected expression [unexpected-token]`},
+	} {
+		p, err := parser.NewFSParser(fsys, tt.name)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		object, err := p.Parse()
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: error mismatch: exp=%q got=%v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		} else if got := ast.SExpr(object); got != tt.exp {
+			t.Errorf("%s: mismatch:\nexp=%s\ngot=%s", tt.name, tt.exp, got)
+		}
+	}
+
+	if _, err := parser.NewFSParser(fsys, "missing.spin"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := parser.NewFSParser(&parser.Overlay{}, "main.spin"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func BenchmarkParser_Parse(b *testing.B) {
+	var sources [][]byte
+	err := filepath.Walk("../test", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".spin" {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		sources = append(sources, src)
+		return err
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, src := range sources {
+			_, _ = parser.NewParser(bytes.NewReader(src)).Parse()
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/pasm"
+	"github.com/bweir/lame/token"
+)
+
+// pasmName returns the upper-case name of tok if it may be part of a PASM
+// instruction. AND and OR are lexed as keywords but are also opcodes.
+func pasmName(tok token.Token) string {
+	switch tok.Type {
+	case token.IDENTIFIER, token.AND, token.OR:
+		return strings.ToUpper(tok.Literal)
+	}
+	return ""
+}
+
+// isInstructionStart reports whether tok starts a PASM instruction or
+// directive, i.e. it is a condition, an opcode or a directive.
+func isInstructionStart(tok token.Token) bool {
+	name := pasmName(tok)
+	if _, ok := pasm.Instructions[name]; ok {
+		return true
+	}
+	return isCondition(name) || pasm.Directives[name]
+}
+
+func isCondition(name string) bool {
+	_, ok := pasm.Conditions[name]
+	return ok
+}
+
+// parseInstruction parses a PASM instruction or an org, res or fit
+// directive, starting at tok.
+func (p *Parser) parseInstruction(tok token.Token) (ast.DataEntry, error) {
+	switch name := pasmName(tok); name {
+	case "ORG":
+		address, err := p.parseOptionalOperand()
+		return &ast.OrgDirective{From: tok.Pos, To: p.end, Address: address}, err
+	case "RES":
+		count, err := p.parseOptionalOperand()
+		return &ast.ResDirective{From: tok.Pos, To: p.end, Count: count}, err
+	case "FIT":
+		address, err := p.parseOptionalOperand()
+		return &ast.FitDirective{From: tok.Pos, To: p.end, Address: address}, err
+	}
+
+	in := &ast.Instruction{From: tok.Pos}
+	if name := pasmName(tok); isCondition(name) {
+		in.Condition = name
+		tok = p.next()
+	}
+
+	in.Opcode = pasmName(tok)
+	instruction, ok := pasm.Instructions[in.Opcode]
+	if !ok {
+		return nil, p.errorf(tok, "found %q, expected instruction", tok.Literal)
+	}
+
+	var err error
+	switch instruction.Operands {
+	case pasm.Destination:
+		in.Destination, err = p.parseOperand()
+	case pasm.Source:
+		err = p.parseSourceOperand(in)
+	case pasm.Both:
+		if in.Destination, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(token.COMMA, "','"); err != nil {
+			return nil, err
+		}
+		err = p.parseSourceOperand(in)
+	}
+	if err != nil {
+		return nil, err
+	}
+	in.To = p.end
+
+	for {
+		tok := p.next()
+		name := pasmName(tok)
+		if _, ok := pasm.Effects[name]; !ok {
+			p.unscan()
+			return in, nil
+		}
+		in.Effects = append(in.Effects, name)
+		in.To = tok.End
+
+		if tok = p.next(); tok.Type != token.COMMA {
+			p.unscan()
+			return in, nil
+		}
+	}
+}
+
+// parseSourceOperand parses a source operand and its # prefix.
+func (p *Parser) parseSourceOperand(in *ast.Instruction) (err error) {
+	if tok := p.next(); tok.Type == token.POUND {
+		in.Immediate = true
+	} else {
+		p.unscan()
+	}
+	in.Source, err = p.parseOperand()
+	return err
+}
+
+// parseOperand parses an operand, which is an expression or a local
+// label reference such as :loop.
+func (p *Parser) parseOperand() (ast.Expression, error) {
+	if tok := p.next(); tok.Type == token.COLON {
+		name, err := p.expect(token.IDENTIFIER, "local label")
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Identifier{From: tok.Pos, To: name.End, Name: ":" + name.Literal}, nil
+	}
+	p.unscan()
+	return p.parseExpression()
+}
+
+func (p *Parser) parseOptionalOperand() (ast.Expression, error) {
+	if tok := p.peek(1); isEndOfStatement(tok) {
+		return nil, nil
+	}
+	return p.parseOperand()
+}
@@ -0,0 +1,312 @@
+package parser
+
+import (
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+// parseStatementList parses statements up to the end of the method. If
+// nested is set, the list ends at the dedent that closes it instead.
+// Unexpected indents inside the list are accepted and their statements
+// added to the list. Statements with syntax errors are reported and
+// replaced by a BadStatement.
+func (p *Parser) parseStatementList(nested bool) (list []ast.Statement) {
+	for {
+		tok := p.next()
+		switch {
+		case tok.Type == token.NEWLINE:
+			continue
+
+		case tok.Type == token.INDENT:
+			list = append(list, p.parseStatementList(true)...)
+
+		case tok.Type == token.DEDENT:
+			if nested {
+				return list
+			}
+
+		case tok.Type == token.EOF || isBlock(tok):
+			p.unscan()
+			return list
+
+		default:
+			p.unscan()
+			stmt, err := p.parseStatement()
+			if err != nil {
+				bad := &ast.BadStatement{From: tok.Pos}
+				p.resync(err)
+				bad.To = p.end
+				stmt = bad
+			}
+			list = append(list, stmt)
+		}
+	}
+}
+
+// parseBody parses the end of a compound statement's header line and the
+// indented block that follows it.
+func (p *Parser) parseBody() ([]ast.Statement, error) {
+	if err := p.expectEndOfLine(); err != nil {
+		return nil, err
+	}
+	return p.parseIndentedBlock(), nil
+}
+
+// parseIndentedBlock parses an indented block of statements if one
+// follows. Otherwise the body is empty.
+func (p *Parser) parseIndentedBlock() []ast.Statement {
+	tok := p.next()
+	for tok.Type == token.NEWLINE {
+		tok = p.next()
+	}
+	if tok.Type != token.INDENT {
+		p.unscan()
+		return nil
+	}
+	return p.parseStatementList(true)
+}
+
+func (p *Parser) parseStatement() (ast.Statement, error) {
+	tok := p.next()
+
+	switch tok.Type {
+	case token.IF, token.IFNOT:
+		return p.parseIfStatement(tok)
+	case token.REPEAT:
+		return p.parseRepeatStatement(tok)
+	case token.CASE:
+		return p.parseCaseStatement(tok)
+	case token.RETURN:
+		value, err := p.parseOptionalValue()
+		if err != nil {
+			return nil, err
+		}
+		stmt := &ast.ReturnStatement{From: tok.Pos, To: p.end, Value: value}
+		return stmt, p.expectEndOfLine()
+	case token.ABORT:
+		value, err := p.parseOptionalValue()
+		if err != nil {
+			return nil, err
+		}
+		stmt := &ast.AbortStatement{From: tok.Pos, To: p.end, Value: value}
+		return stmt, p.expectEndOfLine()
+	case token.NEXT:
+		return &ast.NextStatement{From: tok.Pos, To: tok.End}, p.expectEndOfLine()
+	case token.QUIT:
+		return &ast.QuitStatement{From: tok.Pos, To: tok.End}, p.expectEndOfLine()
+	}
+
+	p.unscan()
+	x, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ExpressionStatement{From: x.Pos(), To: x.End(), X: x}, p.expectEndOfLine()
+}
+
+// parseOptionalValue parses the value of a return or abort statement, if
+// there is one.
+func (p *Parser) parseOptionalValue() (ast.Expression, error) {
+	if tok := p.peek(1); isEndOfStatement(tok) {
+		return nil, nil
+	}
+	return p.parseExpression()
+}
+
+func (p *Parser) parseIfStatement(keyword token.Token) (*ast.IfStatement, error) {
+	cond, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &ast.IfStatement{From: keyword.Pos, Keyword: keyword.Type, Condition: cond, Body: body}
+
+	switch tok := p.next(); tok.Type {
+	case token.ELSEIF, token.ELSEIFNOT:
+		if stmt.Else, err = p.parseIfStatement(tok); err != nil {
+			return nil, err
+		}
+	case token.ELSE:
+		body, err := p.parseBody()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Else = &ast.ElseStatement{From: tok.Pos, To: p.end, Body: body}
+	default:
+		p.unscan()
+	}
+	stmt.To = p.end
+	return stmt, nil
+}
+
+func (p *Parser) parseRepeatStatement(keyword token.Token) (ast.Statement, error) {
+	tok := p.next()
+
+	switch {
+	case isEndOfStatement(tok):
+		p.unscan()
+		body, err := p.parseBody()
+		if err != nil {
+			return nil, err
+		}
+
+		if tok = p.next(); tok.Type == token.WHILE || tok.Type == token.UNTIL {
+			cond, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			stmt := &ast.RepeatWhileStatement{From: keyword.Pos, To: p.end, Keyword: tok.Type, Condition: cond, Post: true, Body: body}
+			return stmt, p.expectEndOfLine()
+		}
+		p.unscan()
+		return &ast.RepeatStatement{From: keyword.Pos, To: p.end, Body: body}, nil
+
+	case tok.Type == token.WHILE || tok.Type == token.UNTIL:
+		cond, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.parseBody()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.RepeatWhileStatement{From: keyword.Pos, To: p.end, Keyword: tok.Type, Condition: cond, Body: body}, nil
+	}
+
+	p.unscan()
+	x, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok = p.next(); tok.Type != token.FROM {
+		p.unscan()
+		body, err := p.parseBody()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.RepeatStatement{From: keyword.Pos, To: p.end, Count: x, Body: body}, nil
+	}
+
+	stmt := &ast.RepeatRangeStatement{From: keyword.Pos, Variable: x}
+	if stmt.Start, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(token.TO, "to"); err != nil {
+		return nil, err
+	}
+	if stmt.Stop, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	if tok = p.next(); tok.Type == token.STEP {
+		if stmt.Step, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+	if stmt.Body, err = p.parseBody(); err != nil {
+		return nil, err
+	}
+	stmt.To = p.end
+	return stmt, nil
+}
+
+func (p *Parser) parseCaseStatement(keyword token.Token) (*ast.CaseStatement, error) {
+	value, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectEndOfLine(); err != nil {
+		return nil, err
+	}
+	stmt := &ast.CaseStatement{From: keyword.Pos, To: value.End(), Value: value}
+
+	tok := p.next()
+	for tok.Type == token.NEWLINE {
+		tok = p.next()
+	}
+	if tok.Type != token.INDENT {
+		p.unscan()
+		return stmt, nil
+	}
+
+	// Stray indents between arms are accepted, as in statement lists.
+	depth := 0
+	for {
+		tok := p.next()
+		switch {
+		case tok.Type == token.NEWLINE:
+			continue
+		case tok.Type == token.INDENT:
+			depth++
+			continue
+		case tok.Type == token.DEDENT:
+			if depth == 0 {
+				stmt.To = p.end
+				return stmt, nil
+			}
+			depth--
+			continue
+		case tok.Type == token.EOF || isBlock(tok):
+			p.unscan()
+			stmt.To = p.end
+			return stmt, nil
+		}
+
+		p.unscan()
+		arm, err := p.parseCaseArm()
+		if err != nil {
+			p.resync(err)
+			continue
+		}
+		stmt.Arms = append(stmt.Arms, arm)
+	}
+}
+
+func (p *Parser) parseCaseArm() (*ast.CaseArm, error) {
+	arm := &ast.CaseArm{From: p.peek(1).Pos}
+
+	if tok := p.next(); tok.Type == token.OTHER {
+		arm.Other = true
+	} else {
+		p.unscan()
+		for {
+			match, err := p.parseRangeExpression()
+			if err != nil {
+				return nil, err
+			}
+			arm.Matches = append(arm.Matches, match)
+
+			if tok := p.next(); tok.Type != token.COMMA {
+				p.unscan()
+				break
+			}
+		}
+	}
+
+	if tok := p.next(); tok.Type != token.COLON {
+		return nil, p.errorf(tok, "found %q, expected ':'", tok.Literal)
+	}
+
+	if tok := p.peek(1); isEndOfStatement(tok) {
+		body, err := p.parseBody()
+		if err != nil {
+			return nil, err
+		}
+		arm.Body = body
+		arm.To = p.end
+		return arm, nil
+	}
+
+	stmt, err := p.parseStatement()
+	if err != nil {
+		return nil, err
+	}
+	arm.Body = append([]ast.Statement{stmt}, p.parseIndentedBlock()...)
+	arm.To = p.end
+	return arm, nil
+}
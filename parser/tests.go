@@ -5,3 +5,113 @@ import "github.com/bweir/lame/token"
 func isBlock(tok token.Token) bool {
 	return tok.Type == token.PUB || tok.Type == token.PRI || tok.Type == token.CON || tok.Type == token.DAT || tok.Type == token.OBJ || tok.Type == token.VAR
 }
+
+func isComment(tok token.Token) bool {
+	return tok.Type == token.COMMENT || tok.Type == token.DOC_COMMENT
+}
+
+func isSize(tok token.Token) bool {
+	return tok.Type == token.BYTE || tok.Type == token.WORD || tok.Type == token.LONG
+}
+
+func isNumber(tok token.Token) bool {
+	switch tok.Type {
+	case token.DECIMAL_NUMBER, token.BINARY_NUMBER, token.QUATERNARY_NUMBER, token.HEXADECIMAL_NUMBER, token.FLOAT_NUMBER:
+		return true
+	}
+	return false
+}
+
+// isEndOfStatement reports whether tok ends a statement.
+func isEndOfStatement(tok token.Token) bool {
+	return tok.Type == token.NEWLINE || tok.Type == token.EOF || tok.Type == token.DEDENT
+}
+
+func isAssignment(tok token.Token) bool {
+	switch tok.Type {
+	case token.ASSIGN,
+		token.ADD_ASSIGN,
+		token.SUBTRACT_ASSIGN,
+		token.MULTIPLY_ASSIGN,
+		token.MULTIPLY_HIGH_ASSIGN,
+		token.DIVIDE_ASSIGN,
+		token.MODULO_ASSIGN,
+		token.LIMIT_MINIMUM_ASSIGN,
+		token.LIMIT_MAXIMUM_ASSIGN,
+		token.BITWISE_AND_ASSIGN,
+		token.BITWISE_OR_ASSIGN,
+		token.BITWISE_XOR_ASSIGN,
+		token.BITWISE_SHIFT_LEFT_ASSIGN,
+		token.BITWISE_SHIFT_RIGHT_ASSIGN,
+		token.BITWISE_ROTATE_LEFT_ASSIGN,
+		token.BITWISE_ROTATE_RIGHT_ASSIGN,
+		token.BITWISE_REVERSE_ASSIGN,
+		token.BITWISE_SIGNED_SHIFT_RIGHT_ASSIGN,
+		token.EQUAL_TO_ASSIGN,
+		token.NOT_EQUAL_TO_ASSIGN,
+		token.LESS_THAN_ASSIGN,
+		token.GREATER_THAN_ASSIGN,
+		token.LESS_THAN_EQUAL_TO_ASSIGN,
+		token.GREATER_THAN_EQUAL_TO_ASSIGN,
+		token.AND_ASSIGN,
+		token.OR_ASSIGN:
+		return true
+	}
+	return false
+}
+
+// isPrefixOperator reports whether tok is a unary operator that binds
+// tighter than any binary operator.
+func isPrefixOperator(tok token.Token) bool {
+	switch tok.Type {
+	case token.SUBTRACT,
+		token.BITWISE_NOT,
+		token.ABSOLUTE,
+		token.DECODE,
+		token.ENCODE,
+		token.SQUARE_ROOT,
+		token.BITWISE_SIGN_EXTEND_7,
+		token.BITWISE_SIGN_EXTEND_15,
+		token.RANDOM,
+		token.INCREMENT,
+		token.DECREMENT,
+		token.AT,
+		token.AT_AT:
+		return true
+	}
+	return false
+}
+
+func isPostfixOperator(tok token.Token) bool {
+	switch tok.Type {
+	case token.INCREMENT,
+		token.DECREMENT,
+		token.BITWISE_SIGN_EXTEND_7,
+		token.BITWISE_SIGN_EXTEND_15,
+		token.RANDOM:
+		return true
+	}
+	return false
+}
+
+// Binary operator precedence levels used by the parser; see
+// token.Type.Precedence.
+const (
+	precedenceOr         = token.LowestPrec
+	precedenceComparison = token.ComparisonPrec
+)
+
+func binaryPrecedence(tok token.Token) int {
+	return tok.Type.Precedence()
+}
+
+// isLayout reports whether tok only lays out the source, i.e. it is
+// whitespace, a comment or the end of the file. Layout tokens are not
+// part of any node.
+func isLayout(tok token.Token) bool {
+	switch tok.Type {
+	case token.SPACE, token.NEWLINE, token.INDENT, token.DEDENT, token.EOF:
+		return true
+	}
+	return isComment(tok)
+}
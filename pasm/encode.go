@@ -0,0 +1,71 @@
+package pasm
+
+import "fmt"
+
+// MaxRegister is the highest cog register address.
+const MaxRegister = 0x1FF
+
+// Registers maps upper-case special register names to their cog address.
+var Registers = map[string]uint32{
+	"PAR":  0x1F0,
+	"CNT":  0x1F1,
+	"INA":  0x1F2,
+	"INB":  0x1F3,
+	"OUTA": 0x1F4,
+	"OUTB": 0x1F5,
+	"DIRA": 0x1F6,
+	"DIRB": 0x1F7,
+	"CTRA": 0x1F8,
+	"CTRB": 0x1F9,
+	"FRQA": 0x1FA,
+	"FRQB": 0x1FB,
+	"PHSA": 0x1FC,
+	"PHSB": 0x1FD,
+	"VCFG": 0x1FE,
+	"VSCL": 0x1FF,
+}
+
+// Encode returns the machine code of the instruction mnemonic. condition
+// is a condition prefix, or "" to always execute, and effects holds
+// effect flags; all names are upper case. dest and src are the register
+// fields, and immediate sets the I bit for a # source operand.
+func Encode(mnemonic, condition string, effects []string, dest, src uint32, immediate bool) (uint32, error) {
+	instruction, ok := Instructions[mnemonic]
+	if !ok {
+		return 0, fmt.Errorf("unknown instruction %q", mnemonic)
+	}
+
+	cond := Conditions["IF_ALWAYS"]
+	if condition != "" {
+		if cond, ok = Conditions[condition]; !ok {
+			return 0, fmt.Errorf("unknown condition %q", condition)
+		}
+	}
+	if mnemonic == "NOP" {
+		cond = 0
+	}
+
+	if dest > MaxRegister {
+		return 0, fmt.Errorf("destination $%X out of range", dest)
+	}
+	if src > MaxRegister {
+		return 0, fmt.Errorf("source $%X out of range", src)
+	}
+
+	code := instruction.Opcode | cond<<18 | dest<<9 | src
+	if immediate {
+		code |= Immediate
+	}
+	for _, name := range effects {
+		bit, ok := Effects[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown effect %q", name)
+		}
+		if name == "NR" {
+			code &^= bit
+		} else {
+			code |= bit
+		}
+	}
+	return code, nil
+}
@@ -0,0 +1,51 @@
+package pasm_test
+
+import (
+	"testing"
+
+	"github.com/bweir/lame/pasm"
+)
+
+// Ensure instructions encode to the machine code in the Propeller manual.
+func TestEncode(t *testing.T) {
+	var tests = []struct {
+		mnemonic  string
+		condition string
+		effects   []string
+		dest, src uint32
+		immediate bool
+		code      uint32
+		err       string
+	}{
+		{mnemonic: "MOV", dest: 0x1F4, immediate: true, code: 0xA0FFE800},
+		{mnemonic: "MOV", dest: 0x10, src: 0x11, code: 0xA0BC2011},
+		{mnemonic: "JMP", src: 0x05, immediate: true, code: 0x5C7C0005},
+		{mnemonic: "RET", code: 0x5C7C0000},
+		{mnemonic: "NOP", code: 0x00000000},
+		{mnemonic: "NOP", condition: "IF_NZ_AND_C", code: 0x00000000},
+		{mnemonic: "JMP", condition: "IF_Z", src: 0x05, immediate: true, code: 0x5C680005},
+		{mnemonic: "TEST", effects: []string{"WZ", "WC"}, dest: 0x20, src: 0x80, immediate: true, code: 0x637C4080},
+		{mnemonic: "ADD", effects: []string{"NR"}, dest: 1, src: 2, code: 0x803C0202},
+		{mnemonic: "COGID", dest: 3, code: 0x0CFC0601},
+		{mnemonic: "WRLONG", dest: 1, src: 2, code: 0x083C0202},
+
+		{mnemonic: "FOO", err: `unknown instruction "FOO"`},
+		{mnemonic: "MOV", condition: "IF_X", err: `unknown condition "IF_X"`},
+		{mnemonic: "MOV", effects: []string{"WX"}, err: `unknown effect "WX"`},
+		{mnemonic: "MOV", dest: 0x200, err: "destination $200 out of range"},
+		{mnemonic: "MOV", src: 0x200, err: "source $200 out of range"},
+	}
+
+	for i, tt := range tests {
+		code, err := pasm.Encode(tt.mnemonic, tt.condition, tt.effects, tt.dest, tt.src, tt.immediate)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d. %s error mismatch: exp=%q got=%v", i, tt.mnemonic, tt.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("%d. %s: %s", i, tt.mnemonic, err)
+		} else if code != tt.code {
+			t.Errorf("%d. %s mismatch: exp=$%08X got=$%08X", i, tt.mnemonic, tt.code, code)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Package pasm describes the Propeller 1 assembly language: instructions,
+// condition prefixes and effect flags.
+package pasm
+
+// Operands tells which operands an instruction takes.
+type Operands int
+
+const (
+	None        Operands = iota // nop, ret
+	Destination                 // cogid, lockset, ...
+	Source                      // jmp, call
+	Both                        // mov, add, ...
+)
+
+// An Instruction is the encoding template of a PASM instruction. Opcode
+// holds the INSTR and ZCRI fields; the condition, destination and source
+// fields are zero, except for hub operations with a fixed source.
+type Instruction struct {
+	Opcode   uint32
+	Operands Operands
+}
+
+func op(instr, zcri uint32, operands Operands) Instruction {
+	return Instruction{Opcode: instr<<26 | zcri<<22, Operands: operands}
+}
+
+func hubop(zcri, src uint32) Instruction {
+	return Instruction{Opcode: 0x03<<26 | zcri<<22 | 1<<22 | src, Operands: Destination}
+}
+
+// Instructions maps upper-case mnemonics to their encoding.
+var Instructions = map[string]Instruction{
+	"WRBYTE": op(0x00, 0x0, Both),
+	"RDBYTE": op(0x00, 0x2, Both),
+	"WRWORD": op(0x01, 0x0, Both),
+	"RDWORD": op(0x01, 0x2, Both),
+	"WRLONG": op(0x02, 0x0, Both),
+	"RDLONG": op(0x02, 0x2, Both),
+
+	"HUBOP":   op(0x03, 0x0, Both),
+	"CLKSET":  hubop(0x0, 0),
+	"COGID":   hubop(0x2, 1),
+	"COGINIT": hubop(0x0, 2),
+	"COGSTOP": hubop(0x0, 3),
+	"LOCKNEW": hubop(0x2, 4),
+	"LOCKRET": hubop(0x0, 5),
+	"LOCKSET": hubop(0x0, 6),
+	"LOCKCLR": hubop(0x0, 7),
+
+	"ROR": op(0x08, 0x2, Both),
+	"ROL": op(0x09, 0x2, Both),
+	"SHR": op(0x0A, 0x2, Both),
+	"SHL": op(0x0B, 0x2, Both),
+	"RCR": op(0x0C, 0x2, Both),
+	"RCL": op(0x0D, 0x2, Both),
+	"SAR": op(0x0E, 0x2, Both),
+	"REV": op(0x0F, 0x2, Both),
+
+	"MINS": op(0x10, 0x2, Both),
+	"MAXS": op(0x11, 0x2, Both),
+	"MIN":  op(0x12, 0x2, Both),
+	"MAX":  op(0x13, 0x2, Both),
+	"MOVS": op(0x14, 0x2, Both),
+	"MOVD": op(0x15, 0x2, Both),
+	"MOVI": op(0x16, 0x2, Both),
+
+	"JMPRET": op(0x17, 0x2, Both),
+	"JMP":    op(0x17, 0x0, Source),
+	"CALL":   op(0x17, 0x2, Source),
+	"RET":    op(0x17, 0x1, None), // jmp #0, patched by call
+
+	"TEST":  op(0x18, 0x0, Both),
+	"AND":   op(0x18, 0x2, Both),
+	"TESTN": op(0x19, 0x0, Both),
+	"ANDN":  op(0x19, 0x2, Both),
+	"OR":    op(0x1A, 0x2, Both),
+	"XOR":   op(0x1B, 0x2, Both),
+	"MUXC":  op(0x1C, 0x2, Both),
+	"MUXNC": op(0x1D, 0x2, Both),
+	"MUXZ":  op(0x1E, 0x2, Both),
+	"MUXNZ": op(0x1F, 0x2, Both),
+
+	"ADD":    op(0x20, 0x2, Both),
+	"CMP":    op(0x21, 0x0, Both),
+	"SUB":    op(0x21, 0x2, Both),
+	"ADDABS": op(0x22, 0x2, Both),
+	"SUBABS": op(0x23, 0x2, Both),
+	"SUMC":   op(0x24, 0x2, Both),
+	"SUMNC":  op(0x25, 0x2, Both),
+	"SUMZ":   op(0x26, 0x2, Both),
+	"SUMNZ":  op(0x27, 0x2, Both),
+
+	"MOV":    op(0x28, 0x2, Both),
+	"NEG":    op(0x29, 0x2, Both),
+	"ABS":    op(0x2A, 0x2, Both),
+	"ABSNEG": op(0x2B, 0x2, Both),
+	"NEGC":   op(0x2C, 0x2, Both),
+	"NEGNC":  op(0x2D, 0x2, Both),
+	"NEGZ":   op(0x2E, 0x2, Both),
+	"NEGNZ":  op(0x2F, 0x2, Both),
+
+	"CMPS":   op(0x30, 0x0, Both),
+	"CMPSX":  op(0x31, 0x0, Both),
+	"ADDX":   op(0x32, 0x2, Both),
+	"CMPX":   op(0x33, 0x0, Both),
+	"SUBX":   op(0x33, 0x2, Both),
+	"ADDS":   op(0x34, 0x2, Both),
+	"SUBS":   op(0x35, 0x2, Both),
+	"ADDSX":  op(0x36, 0x2, Both),
+	"SUBSX":  op(0x37, 0x2, Both),
+	"CMPSUB": op(0x38, 0x2, Both),
+	"DJNZ":   op(0x39, 0x2, Both),
+	"TJNZ":   op(0x3A, 0x0, Both),
+	"TJZ":    op(0x3B, 0x0, Both),
+
+	"WAITPEQ": op(0x3C, 0x0, Both),
+	"WAITPNE": op(0x3D, 0x0, Both),
+	"WAITCNT": op(0x3E, 0x2, Both),
+	"WAITVID": op(0x3F, 0x0, Both),
+
+	"NOP": {Opcode: 0, Operands: None},
+}
+
+// Conditions maps upper-case condition prefixes to the CON field.
+var Conditions = map[string]uint32{
+	"IF_ALWAYS": 0xF,
+	"IF_NEVER":  0x0,
+
+	"IF_E":  0xA,
+	"IF_NE": 0x5,
+	"IF_A":  0x1,
+	"IF_B":  0xC,
+	"IF_AE": 0x3,
+	"IF_BE": 0xE,
+
+	"IF_C":  0xC,
+	"IF_NC": 0x3,
+	"IF_Z":  0xA,
+	"IF_NZ": 0x5,
+
+	"IF_C_EQ_Z": 0x9,
+	"IF_C_NE_Z": 0x6,
+	"IF_Z_EQ_C": 0x9,
+	"IF_Z_NE_C": 0x6,
+
+	"IF_C_AND_Z":   0x8,
+	"IF_C_AND_NZ":  0x4,
+	"IF_NC_AND_Z":  0x2,
+	"IF_NC_AND_NZ": 0x1,
+	"IF_Z_AND_C":   0x8,
+	"IF_NZ_AND_C":  0x4,
+	"IF_Z_AND_NC":  0x2,
+	"IF_NZ_AND_NC": 0x1,
+
+	"IF_C_OR_Z":   0xE,
+	"IF_C_OR_NZ":  0xD,
+	"IF_NC_OR_Z":  0xB,
+	"IF_NC_OR_NZ": 0x7,
+	"IF_Z_OR_C":   0xE,
+	"IF_NZ_OR_C":  0xD,
+	"IF_Z_OR_NC":  0xB,
+	"IF_NZ_OR_NC": 0x7,
+}
+
+// Effect flag bits.
+const (
+	EffectZ      = 1 << 25 // WZ
+	EffectC      = 1 << 24 // WC
+	EffectResult = 1 << 23 // WR, cleared by NR
+	Immediate    = 1 << 22 // # on the source operand
+)
+
+// Effects maps upper-case effect flags to their bits.
+var Effects = map[string]uint32{
+	"WZ": EffectZ,
+	"WC": EffectC,
+	"WR": EffectResult,
+	"NR": EffectResult,
+}
+
+// Directives are the assembler directives that may appear in DAT blocks.
+var Directives = map[string]bool{
+	"ORG": true,
+	"RES": true,
+	"FIT": true,
+}
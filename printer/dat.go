@@ -0,0 +1,110 @@
+package printer
+
+import (
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+func (p *printer) datBlock(b *ast.DatBlock) {
+	next := token.NoPos
+	if len(b.Entries) > 0 {
+		next = b.Entries[0].Pos()
+	}
+	p.line(b.Pos(), b.Pos(), next, p.keyword(token.DAT))
+
+	// Entries line up after the longest label.
+	column := p.Indent
+	for _, e := range b.Entries {
+		if label, ok := e.(*ast.Label); ok && len(label.Name)+1 > column {
+			column = len(label.Name) + 1
+		}
+	}
+
+	for i := 0; i < len(b.Entries); i++ {
+		e := b.Entries[i]
+		label, ok := e.(*ast.Label)
+		if !ok {
+			p.line(e.Pos(), e.End(), token.NoPos, strings.Repeat(" ", column)+p.entry(e))
+			continue
+		}
+
+		// A label shares the line of the entry after it if it did in the
+		// source.
+		if i+1 < len(b.Entries) {
+			next := b.Entries[i+1]
+			if _, isLabel := next.(*ast.Label); !isLabel && (p.file == nil || p.sourceLine(next.Pos()) == p.sourceLine(label.Pos())) {
+				text := label.Name + strings.Repeat(" ", column-len(label.Name)) + p.entry(next)
+				p.line(label.Pos(), next.End(), token.NoPos, text)
+				i++
+				continue
+			}
+		}
+		p.line(label.Pos(), label.End(), token.NoPos, label.Name)
+	}
+}
+
+// entry returns the source of a DAT entry other than a label.
+func (p *printer) entry(e ast.DataEntry) string {
+	switch e := e.(type) {
+	case *ast.DataDirective:
+		text := p.keyword(string(e.Size))
+		var values []string
+		for _, v := range e.Values {
+			value := p.expr(v.Value) + p.count(v.Count)
+			if v.Size != "" {
+				value = p.keyword(string(v.Size)) + " " + value
+			}
+			values = append(values, value)
+		}
+		if len(values) > 0 {
+			text += " " + strings.Join(values, ", ")
+		}
+		return text
+
+	case *ast.FileDirective:
+		return p.keyword("file") + " " + quote(e.Path)
+
+	case *ast.OrgDirective:
+		return p.keyword("org") + p.optional(e.Address)
+	case *ast.ResDirective:
+		return p.keyword("res") + p.optional(e.Count)
+	case *ast.FitDirective:
+		return p.keyword("fit") + p.optional(e.Address)
+
+	case *ast.Instruction:
+		var text string
+		if e.Condition != "" {
+			text = p.keyword(e.Condition) + " "
+		}
+		text += p.keyword(e.Opcode)
+
+		var operands []string
+		if e.Destination != nil {
+			operands = append(operands, p.expr(e.Destination))
+		}
+		if e.Source != nil {
+			source := p.expr(e.Source)
+			if e.Immediate {
+				source = "#" + source
+			}
+			operands = append(operands, source)
+		}
+		if len(operands) > 0 {
+			text += " " + strings.Join(operands, ", ")
+		}
+
+		var effects []string
+		for _, effect := range e.Effects {
+			effects = append(effects, p.keyword(effect))
+		}
+		if len(effects) > 0 {
+			text += " " + strings.Join(effects, ", ")
+		}
+		return text
+	}
+
+	p.fail(e)
+	return ""
+}
@@ -0,0 +1,262 @@
+package printer
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+// operators holds the spelling of each operator. Keyword operators are
+// written in the configured case. Comparisons are spelled as in Spin,
+// where <= and >= assign.
+var operators = map[token.Type]string{
+	token.ADD:                   "+",
+	token.SUBTRACT:              "-",
+	token.MULTIPLY:              "*",
+	token.DIVIDE:                "/",
+	token.MODULO:                "//",
+	token.MULTIPLY_HIGH:         "**",
+	token.LIMIT_MINIMUM:         "#>",
+	token.LIMIT_MAXIMUM:         "<#",
+	token.EQUAL_TO:              "==",
+	token.NOT_EQUAL_TO:          "<>",
+	token.LESS_THAN:             "<",
+	token.GREATER_THAN:          ">",
+	token.LESS_THAN_EQUAL_TO:    "=<",
+	token.GREATER_THAN_EQUAL_TO: "=>",
+	token.BITWISE_AND:           "&",
+	token.BITWISE_OR:            "|",
+	token.BITWISE_XOR:           "^",
+	token.BITWISE_NOT:           "!",
+
+	token.BITWISE_SHIFT_LEFT:         "<<",
+	token.BITWISE_SHIFT_RIGHT:        ">>",
+	token.BITWISE_ROTATE_LEFT:        "<-",
+	token.BITWISE_ROTATE_RIGHT:       "->",
+	token.BITWISE_REVERSE:            "><",
+	token.BITWISE_SIGNED_SHIFT_RIGHT: "~>",
+	token.BITWISE_SIGN_EXTEND_7:      "~",
+	token.BITWISE_SIGN_EXTEND_15:     "~~",
+
+	token.ASSIGN:                            ":=",
+	token.ADD_ASSIGN:                        "+=",
+	token.SUBTRACT_ASSIGN:                   "-=",
+	token.MULTIPLY_ASSIGN:                   "*=",
+	token.DIVIDE_ASSIGN:                     "/=",
+	token.MODULO_ASSIGN:                     "//=",
+	token.MULTIPLY_HIGH_ASSIGN:              "**=",
+	token.LIMIT_MINIMUM_ASSIGN:              "#>=",
+	token.LIMIT_MAXIMUM_ASSIGN:              "<#=",
+	token.BITWISE_AND_ASSIGN:                "&=",
+	token.BITWISE_OR_ASSIGN:                 "|=",
+	token.BITWISE_XOR_ASSIGN:                "^=",
+	token.BITWISE_SHIFT_LEFT_ASSIGN:         "<<=",
+	token.BITWISE_SHIFT_RIGHT_ASSIGN:        ">>=",
+	token.BITWISE_ROTATE_LEFT_ASSIGN:        "<-=",
+	token.BITWISE_ROTATE_RIGHT_ASSIGN:       "->=",
+	token.BITWISE_REVERSE_ASSIGN:            "><=",
+	token.BITWISE_SIGNED_SHIFT_RIGHT_ASSIGN: "~>=",
+	token.EQUAL_TO_ASSIGN:                   "===",
+	token.NOT_EQUAL_TO_ASSIGN:               "<>=",
+	token.LESS_THAN_ASSIGN:                  "<=",
+	token.GREATER_THAN_ASSIGN:               ">=",
+	token.LESS_THAN_EQUAL_TO_ASSIGN:         "=<=",
+	token.GREATER_THAN_EQUAL_TO_ASSIGN:      "=>=",
+
+	token.INCREMENT:   "++",
+	token.DECREMENT:   "--",
+	token.ABSOLUTE:    "||",
+	token.DECODE:      "|<",
+	token.ENCODE:      ">|",
+	token.SQUARE_ROOT: "^^",
+	token.RANDOM:      "?",
+	token.AT:          "@",
+	token.AT_AT:       "@@",
+}
+
+// operator returns the spelling of op.
+func (p *printer) operator(op token.Type) string {
+	if s, ok := operators[op]; ok {
+		return s
+	}
+	switch op {
+	case token.AND_ASSIGN:
+		return p.keyword(string(token.AND)) + "="
+	case token.OR_ASSIGN:
+		return p.keyword(string(token.OR)) + "="
+	}
+	return p.keyword(string(op))
+}
+
+// numberPrefixes holds the prefix of each kind of number.
+var numberPrefixes = map[token.Type]string{
+	token.HEXADECIMAL_NUMBER: "$",
+	token.BINARY_NUMBER:      "%",
+	token.QUATERNARY_NUMBER:  "%%",
+}
+
+// Precedence of the operands of unary operators, used to decide where
+// parentheses are needed.
+const (
+	assignmentPrec = 0
+	unaryPrec      = token.HighestPrec + 1
+	postfixPrec    = token.HighestPrec + 2
+	primaryPrec    = token.HighestPrec + 3
+)
+
+// precedence returns how tightly x binds.
+func precedence(x ast.Expression) int {
+	switch x := x.(type) {
+	case *ast.AssignmentExpression:
+		return assignmentPrec
+	case *ast.BinaryExpression:
+		return x.Operator.Precedence()
+	case *ast.UnaryExpression:
+		if x.Operator == token.NOT {
+			return token.NotPrec
+		}
+		return unaryPrec
+	case *ast.PostfixExpression:
+		return postfixPrec
+	}
+	return primaryPrec
+}
+
+// operand returns the source of x, in parentheses if it binds less
+// tightly than prec.
+func (p *printer) operand(x ast.Expression, prec int) string {
+	if precedence(x) < prec {
+		return "(" + p.expr(x) + ")"
+	}
+	return p.expr(x)
+}
+
+// unary returns the source of the operand x of a unary operator or the
+// right operand of a binary operator. NOT needs no parentheses there, as
+// the parser reads it like any other unary operator.
+func (p *printer) unary(x ast.Expression, prec int) string {
+	if u, ok := x.(*ast.UnaryExpression); ok && u.Operator == token.NOT {
+		return p.expr(x)
+	}
+	return p.operand(x, prec)
+}
+
+// expr returns the source of x. Parentheses are added where the tree
+// could not be parsed back otherwise, so trees built or rewritten in code
+// print correctly too.
+func (p *printer) expr(x ast.Expression) string {
+	switch x := x.(type) {
+	case *ast.Identifier:
+		return x.Name
+	case *ast.NumberLiteral:
+		return numberPrefixes[x.Kind] + x.Value
+	case *ast.StringLiteral:
+		return quote(x.Value)
+	case *ast.BooleanLiteral:
+		if x.Value {
+			return p.keyword(token.TRUE)
+		}
+		return p.keyword(token.FALSE)
+	case *ast.CurrentAddressExpression:
+		return "$"
+
+	case *ast.UnaryExpression:
+		if x.Operator == token.NOT {
+			return p.keyword(token.NOT) + " " + p.unary(x.X, token.ComparisonPrec)
+		}
+		// Adjacent operators could be read as a single one, e.g. - -x.
+		operand := p.unary(x.X, unaryPrec)
+		if _, ok := x.X.(*ast.UnaryExpression); ok {
+			operand = " " + operand
+		}
+		return p.operator(x.Operator) + operand
+
+	case *ast.PostfixExpression:
+		operand := p.operand(x.X, postfixPrec)
+		if _, ok := x.X.(*ast.PostfixExpression); ok {
+			operand += " "
+		}
+		return operand + p.operator(x.Operator)
+
+	case *ast.BinaryExpression:
+		prec := x.Operator.Precedence()
+		return p.operand(x.X, prec) + " " + p.operator(x.Operator) + " " + p.unary(x.Y, prec+1)
+
+	case *ast.AssignmentExpression:
+		return p.operand(x.Target, token.LowestPrec) + " " + p.operator(x.Operator) + " " + p.expr(x.Value)
+
+	case *ast.ParenExpression:
+		return "(" + p.expr(x.X) + ")"
+
+	case *ast.MemoryExpression:
+		text := p.keyword(string(x.Size)) + "[" + p.expr(x.Base) + "]"
+		if x.Index != nil {
+			text += "[" + p.expr(x.Index) + "]"
+		}
+		return text
+
+	case *ast.IndexExpression:
+		return p.operand(x.X, primaryPrec) + "[" + p.expr(x.Index) + "]"
+	case *ast.SelectorExpression:
+		return p.operand(x.X, primaryPrec) + "." + x.Name.Name
+	case *ast.CallExpression:
+		return p.operand(x.Function, primaryPrec) + "(" + p.list(x.Arguments) + ")"
+	case *ast.ObjectConstantExpression:
+		return x.Object.Name + "#" + x.Name.Name
+
+	case *ast.StringExpression:
+		return p.keyword("string") + "(" + p.list(x.Arguments) + ")"
+	case *ast.ConstantExpression:
+		return p.keyword("constant") + "(" + p.expr(x.X) + ")"
+	case *ast.LookupExpression:
+		return p.keyword(x.Function) + "(" + p.expr(x.Index) + " : " + p.list(x.List) + ")"
+	case *ast.RangeExpression:
+		return p.expr(x.Low) + ".." + p.expr(x.High)
+	}
+
+	p.fail(x)
+	return ""
+}
+
+// list returns a comma-separated list of expressions.
+func (p *printer) list(list []ast.Expression) string {
+	var items []string
+	for _, x := range list {
+		items = append(items, p.expr(x))
+	}
+	return strings.Join(items, ", ")
+}
+
+// quote returns s as a string literal. Characters the scanner cannot read
+// back from a literal are escaped with their character code. A digit
+// after a character code is escaped too, so that it is not read as part
+// of the code.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	escaped := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+			escaped = false
+		case r == '\n':
+			b.WriteString(`\n`)
+			escaped = false
+		case r == '\t':
+			b.WriteString(`\t`)
+			escaped = false
+		case r == '\\' || !unicode.IsPrint(r) || (escaped && (r >= '0' && r <= '9' || r == '_')):
+			b.WriteString(`\` + strconv.Itoa(int(r)))
+			escaped = true
+		default:
+			b.WriteRune(r)
+			escaped = false
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
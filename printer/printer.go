@@ -0,0 +1,299 @@
+// Package printer writes Lame source code from an AST.
+package printer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+// A Case selects how keywords are written.
+type Case int
+
+const (
+	// SpinCase writes block keywords in upper case and all other keywords
+	// in lower case, e.g. PUB and repeat.
+	SpinCase Case = iota
+
+	// UpperCase writes all keywords in upper case.
+	UpperCase
+
+	// LowerCase writes all keywords in lower case.
+	LowerCase
+)
+
+// A Config controls the output of Fprint.
+type Config struct {
+	Indent int  // spaces per indentation level; 0 means 4
+	Case   Case // case of keywords, PASM opcodes and built-in functions
+}
+
+// Fprint writes object to w using the default configuration.
+func Fprint(w io.Writer, file *token.File, object *ast.Object) error {
+	return (&Config{}).Fprint(w, file, object)
+}
+
+// Fprint writes object to w as source code. Comments are written before
+// the line of the next node after them. If file is the line table of the
+// parsed source, comments that followed code on the same line stay at the
+// end of that line, and single empty lines between declarations and
+// statements are kept. Objects with syntax errors cannot be printed.
+func (c *Config) Fprint(w io.Writer, file *token.File, object *ast.Object) error {
+	p := &printer{Config: *c, file: file, comments: object.Comments}
+	if p.Indent == 0 {
+		p.Indent = 4
+	}
+
+	for i, block := range object.Blocks {
+		if p.err != nil {
+			break
+		}
+		next := token.NoPos
+		if i+1 < len(object.Blocks) {
+			next = object.Blocks[i+1].Pos()
+		}
+		p.block(block)
+		p.internal(next)
+	}
+	if p.err != nil {
+		return p.err
+	}
+	p.flush(token.NoPos)
+
+	_, err := io.WriteString(w, p.out.String())
+	return err
+}
+
+type printer struct {
+	Config
+	file     *token.File
+	comments []*ast.CommentGroup // comments not yet written
+	out      strings.Builder
+	depth    int // indentation level
+	lastLine int // source line of the last output, or 0
+	err      error
+}
+
+// line writes text as a line of its own for the source from start to end,
+// with the comments before start and the comments after end on the same
+// line. Comments before next stay before the line.
+func (p *printer) line(start, end, next token.Pos, text string) {
+	p.flush(start)
+	p.gap(start)
+	p.out.WriteString(strings.Repeat(" ", p.depth*p.Indent) + text)
+	p.trailing(end, next)
+	p.out.WriteString("\n")
+	if p.file != nil && p.lastLine < p.sourceLine(end) {
+		p.lastLine = p.sourceLine(end)
+	}
+}
+
+// sourceLine returns the source line of pos, or 0 if it is not known.
+func (p *printer) sourceLine(pos token.Pos) int {
+	if p.file == nil || !pos.IsValid() {
+		return 0
+	}
+	return p.file.Position(pos).Line
+}
+
+// gap writes an empty line if the source had one or more before pos.
+func (p *printer) gap(pos token.Pos) {
+	if line := p.sourceLine(pos); p.lastLine > 0 && line > p.lastLine+1 {
+		p.out.WriteString("\n")
+	}
+}
+
+// flush writes the comments that end before pos on lines of their own.
+// NoPos flushes all comments.
+func (p *printer) flush(pos token.Pos) {
+	for len(p.comments) > 0 && (!pos.IsValid() || p.comments[0].End() <= pos) {
+		g := p.comments[0]
+		p.comments = p.comments[1:]
+		for _, c := range g.List {
+			p.gap(c.Pos())
+
+			// The lines of a block comment cannot be indented safely, so a
+			// block comment over several lines keeps its source column.
+			indent := p.depth * p.Indent
+			if c.Block && strings.Contains(c.Text, "\n") && p.file != nil {
+				indent = p.file.Position(c.Pos()).Column - 1
+			}
+			p.out.WriteString(strings.Repeat(" ", indent) + comment(c) + "\n")
+			if line := p.sourceLine(c.End()); line > 0 {
+				p.lastLine = line
+			}
+		}
+	}
+}
+
+// internal writes the indented comments after a block, up to the block
+// that starts at next, inside the block.
+func (p *printer) internal(next token.Pos) {
+	p.depth++
+	for len(p.comments) > 0 && (!next.IsValid() || p.comments[0].End() <= next) {
+		if p.file == nil || p.file.Position(p.comments[0].Pos()).Column == 1 {
+			break
+		}
+		p.flush(p.comments[0].End())
+	}
+	p.depth--
+}
+
+// trailing writes the comment groups that follow end on its source line,
+// but come before next if it is set.
+func (p *printer) trailing(end, next token.Pos) {
+	line := p.sourceLine(end)
+	for line > 0 && len(p.comments) > 0 {
+		g := p.comments[0]
+		if g.Pos() < end || p.sourceLine(g.Pos()) != line || (next.IsValid() && g.Pos() >= next) {
+			return
+		}
+		p.comments = p.comments[1:]
+		for _, c := range g.List {
+			p.out.WriteString(" " + comment(c))
+		}
+		p.lastLine = p.sourceLine(g.End())
+	}
+}
+
+// comment returns the source of c.
+func comment(c *ast.Comment) string {
+	switch {
+	case c.Doc && c.Block:
+		return "{{" + c.Text + "}}"
+	case c.Block:
+		return "{" + c.Text + "}"
+	case c.Doc:
+		return "''" + c.Text
+	}
+	return "'" + c.Text
+}
+
+// keyword returns a keyword, opcode or built-in name in the configured
+// case.
+func (p *printer) keyword(name string) string {
+	switch p.Case {
+	case UpperCase:
+		return strings.ToUpper(name)
+	case SpinCase:
+		switch strings.ToUpper(name) {
+		case token.CON, token.DAT, token.OBJ, token.PRI, token.PUB, token.VAR:
+			return strings.ToUpper(name)
+		}
+	}
+	return strings.ToLower(name)
+}
+
+// fail records that n cannot be printed.
+func (p *printer) fail(n ast.Node) {
+	if p.err != nil {
+		return
+	}
+	name := strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")
+	if p.file != nil {
+		p.err = fmt.Errorf("%s: cannot print %s", p.file.Position(n.Pos()), name)
+	} else {
+		p.err = fmt.Errorf("cannot print %s", name)
+	}
+}
+
+func (p *printer) block(b ast.Block) {
+	// Blocks are separated by an empty line, which comes before the
+	// comments that lead the block.
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+	}
+	p.lastLine = 0
+	p.flush(b.Pos())
+
+	switch b := b.(type) {
+	case *ast.ConBlock:
+		p.declarations(b, token.CON, b.Declarations)
+	case *ast.ObjBlock:
+		p.declarations(b, token.OBJ, b.Declarations)
+	case *ast.VarBlock:
+		p.declarations(b, token.VAR, b.Declarations)
+	case *ast.DatBlock:
+		p.datBlock(b)
+	case *ast.PubBlock:
+		p.method(b, token.PUB, b.Name, b.Parameters, b.Result, b.Locals, b.Body)
+	case *ast.PriBlock:
+		p.method(b, token.PRI, b.Name, b.Parameters, b.Result, b.Locals, b.Body)
+	default:
+		p.fail(b)
+	}
+}
+
+func (p *printer) declarations(b ast.Block, keyword string, list []ast.Declaration) {
+	next := token.NoPos
+	if len(list) > 0 {
+		next = list[0].Pos()
+	}
+	p.line(b.Pos(), b.Pos(), next, p.keyword(keyword))
+
+	p.depth++
+	for i, d := range list {
+		// A comment after declarations that share a line follows the last.
+		next := token.NoPos
+		if i+1 < len(list) {
+			next = list[i+1].Pos()
+		}
+
+		var text string
+		switch d := d.(type) {
+		case *ast.ConstantDeclaration:
+			text = d.Name + " = " + p.expr(d.Value)
+		case *ast.ObjectDeclaration:
+			text = d.Name + p.count(d.Count) + " : " + quote(d.Path)
+		case *ast.VariableDeclaration:
+			text = p.keyword(string(d.Size)) + " " + d.Name + p.count(d.Count)
+		default:
+			p.fail(d)
+		}
+		p.line(d.Pos(), d.End(), next, text)
+	}
+	p.depth--
+}
+
+// count returns an array count such as [4], or "" if count is nil.
+func (p *printer) count(count ast.Expression) string {
+	if count == nil {
+		return ""
+	}
+	return "[" + p.expr(count) + "]"
+}
+
+func (p *printer) method(b ast.Block, keyword, name string, parameters []*ast.Identifier, result *ast.Identifier, locals []*ast.LocalDeclaration, body []ast.Statement) {
+	header := p.keyword(keyword) + " " + name
+	end := b.Pos()
+	if len(parameters) > 0 {
+		var names []string
+		for _, id := range parameters {
+			names = append(names, id.Name)
+		}
+		header += "(" + strings.Join(names, ", ") + ")"
+		end = parameters[len(parameters)-1].End()
+	}
+	if result != nil {
+		header += " : " + result.Name
+		end = result.End()
+	}
+	if len(locals) > 0 {
+		var names []string
+		for _, l := range locals {
+			names = append(names, l.Name+p.count(l.Count))
+		}
+		header += " | " + strings.Join(names, ", ")
+		end = locals[len(locals)-1].End()
+	}
+
+	next := token.NoPos
+	if len(body) > 0 {
+		next = body[0].Pos()
+	}
+	p.line(b.Pos(), end, next, header)
+	p.body(body)
+}
@@ -0,0 +1,164 @@
+package printer_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/parser"
+	"github.com/bweir/lame/printer"
+)
+
+// roundTrip parses src, prints it with c and parses the result again. It
+// returns the printed source.
+func roundTrip(t *testing.T, name string, c *printer.Config, src string) string {
+	t.Helper()
+
+	p := parser.NewFileParser(name, strings.NewReader(src))
+	object, err := p.Parse()
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Fprint(&buf, p.File(), object); err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+
+	printed, err := parser.NewFileParser(name, bytes.NewReader(buf.Bytes())).Parse()
+	if err != nil {
+		t.Fatalf("%s: reparse: %s\n%s", name, err, buf.String())
+	}
+	if exp, got := ast.SExpr(object), ast.SExpr(printed); exp != got {
+		t.Errorf("%s: tree changed:\n  exp=%s\n  got=%s\n%s", name, exp, got, buf.String())
+	}
+	return buf.String()
+}
+
+// Ensure every test object prints to source with the same tree, in every
+// keyword case.
+func TestFprint_Files(t *testing.T) {
+	err := filepath.Walk("../test", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".spin" || strings.HasPrefix(info.Name(), "fail-") {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, c := range []printer.Config{
+			{},
+			{Indent: 2, Case: printer.UpperCase},
+			{Indent: 3, Case: printer.LowerCase},
+		} {
+			printed := roundTrip(t, path, &c, string(src))
+
+			// Printing is idempotent.
+			if again := roundTrip(t, path, &c, printed); again != printed {
+				t.Errorf("%s: printing again changed the source:\n%s\n---\n%s", path, printed, again)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure the printer writes the expected source.
+func TestFprint(t *testing.T) {
+	var tests = []struct {
+		c   printer.Config
+		src string
+		exp string
+	}{
+		{
+			src: "con a=1,b=2 ' two\nvar byte x[4], y\n",
+			exp: "CON\n    a = 1\n    b = 2 ' two\n\nVAR\n    byte x[4]\n    byte y\n",
+		},
+		{
+			c:   printer.Config{Indent: 2, Case: printer.UpperCase},
+			src: "pub main(a) : r | i\n  if a\n    r := true\n  elseifnot i\n    quit\n  else\n    repeat i from 0 to 3 step 1\n      r += i\n",
+			exp: "PUB main(a) : r | i\n  IF a\n    r := TRUE\n  ELSEIFNOT i\n    QUIT\n  ELSE\n    REPEAT i FROM 0 TO 3 STEP 1\n      r += i\n",
+		},
+		{
+			c:   printer.Config{Case: printer.LowerCase},
+			src: "PUB main\n  case x\n    1, 3..5: y\n    OTHER:\n      z\n  repeat\n    x++\n  while x < 10\n",
+			exp: "pub main\n    case x\n        1, 3..5: y\n        other:\n            z\n    repeat\n        x++\n    while x < 10\n",
+		},
+		{
+			src: "PUB main\n  x := lookupz(i: \"a\", $F, %%12, %01) + string(\"\\\"q\\\"\\n\", 1)\n  not a and b == not c\n  - -x + byte[@a][2]\n",
+			exp: "PUB main\n    x := lookupz(i : \"a\", $F, %%12, %01) + string(\"\\\"q\\\"\\n\", 1)\n    not a and b == not c\n    - -x + byte[@a][2]\n",
+		},
+		{
+			src: "PUB main\n  x := a =< b and a => c\n  x #>= 0\n  x := ||x ~> 2 -> 1 >< 8\n  x := ^^x // 3 + |<x + >|x\n  ~x\n  ?x\n",
+			exp: "PUB main\n    x := a =< b and a => c\n    x #>= 0\n    x := ||x ~> 2 -> 1 >< 8\n    x := ^^x // 3 + |<x + >|x\n    ~x\n    ?x\n",
+		},
+		{
+			src: "PUB main\n  x <= y\n  x >= y\n  x === y\n  x <>= y\n  x =<= y\n  x =>= y\n  x and= y\n  x OR= y\n",
+			exp: "PUB main\n    x <= y\n    x >= y\n    x === y\n    x <>= y\n    x =<= y\n    x =>= y\n    x and= y\n    x or= y\n",
+		},
+		{
+			src: "DAT\nloop if_z mov a, #:x wz, wc\n:x long 1, word 2[4]\nbuf\n  file \"a.bin\"\n  org\n",
+			exp: "DAT\nloop if_z mov a, #:x wz, wc\n:x   long 1, word 2[4]\nbuf\n     file \"a.bin\"\n     org\n",
+		},
+		{
+			src: "{{ Doc }}\nOBJ\n  lib[2] : \"lib\"\n\n\n  ' about c\n  c : \"c\"\n",
+			exp: "{{ Doc }}\nOBJ\n    lib[2] : \"lib\"\n\n    ' about c\n    c : \"c\"\n",
+		},
+	}
+
+	for i, tt := range tests {
+		if got := roundTrip(t, "x.spin", &tt.c, tt.src); got != tt.exp {
+			t.Errorf("%d. mismatch:\n  exp=%q\n  got=%q", i, tt.exp, got)
+		}
+	}
+}
+
+// Ensure parentheses are added to trees built in code.
+func TestFprint_Parentheses(t *testing.T) {
+	id := func(name string) *ast.Identifier { return &ast.Identifier{Name: name} }
+	object := &ast.Object{Blocks: []ast.Block{&ast.PubBlock{
+		Name: "main",
+		Body: []ast.Statement{
+			&ast.ExpressionStatement{X: &ast.BinaryExpression{
+				Operator: "MULTIPLY",
+				X:        &ast.BinaryExpression{Operator: "ADD", X: id("a"), Y: id("b")},
+				Y:        &ast.BinaryExpression{Operator: "SUBTRACT", X: id("c"), Y: id("d")},
+			}},
+			&ast.ExpressionStatement{X: &ast.BinaryExpression{
+				Operator: "SUBTRACT",
+				X:        id("a"),
+				Y:        &ast.AssignmentExpression{Operator: "ASSIGN", Target: id("b"), Value: id("c")},
+			}},
+			&ast.ExpressionStatement{X: &ast.PostfixExpression{
+				Operator: "INCREMENT",
+				X:        &ast.UnaryExpression{Operator: "SUBTRACT", X: id("a")},
+			}},
+		},
+	}}}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, nil, object); err != nil {
+		t.Fatal(err)
+	}
+	exp := "PUB main\n    (a + b) * (c - d)\n    a - (b := c)\n    (-a)++\n"
+	if buf.String() != exp {
+		t.Errorf("mismatch:\n  exp=%q\n  got=%q", exp, buf.String())
+	}
+}
+
+// Ensure objects with syntax errors are not printed.
+func TestFprint_Bad(t *testing.T) {
+	p := parser.NewFileParser("bad.spin", strings.NewReader("PUB main\n  x := )\n"))
+	object, _ := p.Parse()
+
+	err := printer.Fprint(&bytes.Buffer{}, p.File(), object)
+	if err == nil || err.Error() != "bad.spin:2:3: cannot print BadStatement" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package printer
+
+import (
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+// body writes an indented list of statements.
+func (p *printer) body(list []ast.Statement) {
+	p.depth++
+	for _, s := range list {
+		p.statement(s)
+	}
+	p.depth--
+}
+
+// simple returns the source of a statement without a body, or false.
+func (p *printer) simple(s ast.Statement) (string, bool) {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		return p.expr(s.X), true
+	case *ast.ReturnStatement:
+		return p.keyword(token.RETURN) + p.optional(s.Value), true
+	case *ast.AbortStatement:
+		return p.keyword(token.ABORT) + p.optional(s.Value), true
+	case *ast.NextStatement:
+		return p.keyword(token.NEXT), true
+	case *ast.QuitStatement:
+		return p.keyword(token.QUIT), true
+	}
+	return "", false
+}
+
+// optional returns x after a space, or "" if x is nil.
+func (p *printer) optional(x ast.Expression) string {
+	if x == nil {
+		return ""
+	}
+	return " " + p.expr(x)
+}
+
+// header writes the first line of a compound statement, which ends at
+// end, followed by its body.
+func (p *printer) header(s ast.Node, end token.Pos, text string, body []ast.Statement) {
+	next := token.NoPos
+	if len(body) > 0 {
+		next = body[0].Pos()
+	}
+	p.line(s.Pos(), end, next, text)
+	p.body(body)
+}
+
+func (p *printer) statement(s ast.Statement) {
+	if text, ok := p.simple(s); ok {
+		p.line(s.Pos(), s.End(), token.NoPos, text)
+		return
+	}
+
+	switch s := s.(type) {
+	case *ast.IfStatement:
+		p.ifStatement(s)
+
+	case *ast.RepeatStatement:
+		end := s.Pos()
+		text := p.keyword(token.REPEAT)
+		if s.Count != nil {
+			end = s.Count.End()
+			text += " " + p.expr(s.Count)
+		}
+		p.header(s, end, text, s.Body)
+
+	case *ast.RepeatRangeStatement:
+		text := p.keyword(token.REPEAT) + " " + p.expr(s.Variable) +
+			" " + p.keyword(token.FROM) + " " + p.expr(s.Start) +
+			" " + p.keyword(token.TO) + " " + p.expr(s.Stop)
+		end := s.Stop.End()
+		if s.Step != nil {
+			text += " " + p.keyword(token.STEP) + " " + p.expr(s.Step)
+			end = s.Step.End()
+		}
+		p.header(s, end, text, s.Body)
+
+	case *ast.RepeatWhileStatement:
+		condition := p.keyword(string(s.Keyword)) + " " + p.expr(s.Condition)
+		if !s.Post {
+			p.header(s, s.Condition.End(), p.keyword(token.REPEAT)+" "+condition, s.Body)
+			return
+		}
+		p.header(s, s.Pos(), p.keyword(token.REPEAT), s.Body)
+		p.line(s.Condition.Pos(), s.Condition.End(), token.NoPos, condition)
+
+	case *ast.CaseStatement:
+		next := token.NoPos
+		if len(s.Arms) > 0 {
+			next = s.Arms[0].Pos()
+		}
+		p.line(s.Pos(), s.Value.End(), next, p.keyword(token.CASE)+" "+p.expr(s.Value))
+		p.depth++
+		for _, arm := range s.Arms {
+			p.caseArm(arm)
+		}
+		p.depth--
+
+	default:
+		p.fail(s)
+	}
+}
+
+func (p *printer) ifStatement(s *ast.IfStatement) {
+	p.header(s, s.Condition.End(), p.keyword(string(s.Keyword))+" "+p.expr(s.Condition), s.Body)
+
+	switch e := s.Else.(type) {
+	case nil:
+	case *ast.IfStatement:
+		p.ifStatement(e)
+	case *ast.ElseStatement:
+		p.header(e, e.Pos(), p.keyword(token.ELSE), e.Body)
+	default:
+		p.fail(e)
+	}
+}
+
+func (p *printer) caseArm(arm *ast.CaseArm) {
+	text := p.keyword(token.OTHER)
+	end := arm.Pos()
+	if !arm.Other {
+		var matches []string
+		for _, m := range arm.Matches {
+			matches = append(matches, p.expr(m))
+		}
+		text = strings.Join(matches, ", ")
+		end = arm.Matches[len(arm.Matches)-1].End()
+	}
+	text += ":"
+
+	// A single simple statement stays on the line of the arm if it was
+	// there in the source.
+	if len(arm.Body) == 1 && (p.file == nil || p.sourceLine(arm.Body[0].Pos()) == p.sourceLine(arm.Pos())) {
+		if stmt, ok := p.simple(arm.Body[0]); ok {
+			p.line(arm.Pos(), arm.Body[0].End(), token.NoPos, text+" "+stmt)
+			return
+		}
+	}
+	p.header(arm, end, text, arm.Body)
+}
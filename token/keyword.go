@@ -15,19 +15,23 @@ const (
 	FALSE = "FALSE"
 
 	// Flow Control
-	CASE   = "CASE"
-	IF     = "IF"
-	ELSEIF = "ELSEIF"
-	ELSE   = "ELSE"
-	NEXT   = "NEXT"
-	QUIT   = "QUIT"
-	REPEAT = "REPEAT"
-	FROM   = "FROM"
-	TO     = "TO"
-	STEP   = "STEP"
-	WHILE  = "WHILE"
-	UNTIL  = "UNTIL"
-	RETURN = "RETURN"
+	CASE      = "CASE"
+	IF        = "IF"
+	IFNOT     = "IFNOT"
+	ELSEIF    = "ELSEIF"
+	ELSEIFNOT = "ELSEIFNOT"
+	ELSE      = "ELSE"
+	OTHER     = "OTHER"
+	NEXT      = "NEXT"
+	QUIT      = "QUIT"
+	REPEAT    = "REPEAT"
+	FROM      = "FROM"
+	TO        = "TO"
+	STEP      = "STEP"
+	WHILE     = "WHILE"
+	UNTIL     = "UNTIL"
+	RETURN    = "RETURN"
+	ABORT     = "ABORT"
 
 	// Memory
 	BYTE = "BYTE"
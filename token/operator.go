@@ -6,27 +6,57 @@ const (
 	SUBTRACT = "SUBTRACT" // -
 	MULTIPLY = "MULTIPLY" // *
 	DIVIDE   = "DIVIDE"   // /
-	MODULO   = "MODULO"   // %
-	ASSIGN   = "ASSIGN"   // =
+	MODULO   = "MODULO"   // %, //
+	ASSIGN   = "ASSIGN"   // =, :=
 
 	ADD_ASSIGN      = "ADD_ASSIGN"      // +=
 	SUBTRACT_ASSIGN = "SUBTRACT_ASSIGN" // -=
 	MULTIPLY_ASSIGN = "MULTIPLY_ASSIGN" // *=
 	DIVIDE_ASSIGN   = "DIVIDE_ASSIGN"   // /=
-	MODULO_ASSIGN   = "MODULO_ASSIGN"   // /=
+	MODULO_ASSIGN   = "MODULO_ASSIGN"   // %=, //=
+
+	MULTIPLY_HIGH        = "MULTIPLY_HIGH"        // **
+	MULTIPLY_HIGH_ASSIGN = "MULTIPLY_HIGH_ASSIGN" // **=
+
+	LIMIT_MINIMUM        = "LIMIT_MINIMUM"        // #>
+	LIMIT_MINIMUM_ASSIGN = "LIMIT_MINIMUM_ASSIGN" // #>=
+	LIMIT_MAXIMUM        = "LIMIT_MAXIMUM"        // <#
+	LIMIT_MAXIMUM_ASSIGN = "LIMIT_MAXIMUM_ASSIGN" // <#=
+
+	INCREMENT = "INCREMENT" // ++
+	DECREMENT = "DECREMENT" // --
+
+	ABSOLUTE    = "ABSOLUTE"    // ||
+	DECODE      = "DECODE"      // |<
+	ENCODE      = "ENCODE"      // >|
+	SQUARE_ROOT = "SQUARE_ROOT" // ^^
+	RANDOM      = "RANDOM"      // ?
 
 	AT    = "AT"    // @
+	AT_AT = "AT_AT" // @@
 	POUND = "POUND" // #
 
+	DOLLAR = "DOLLAR" // $
+
 	DOT   = "DOT"   // .
 	RANGE = "RANGE" // ..
 	PIPE  = "PIPE"  // |
 
 	EQUAL_TO              = "EQUAL_TO"              // ==
+	NOT_EQUAL_TO          = "NOT_EQUAL_TO"          // <>
 	LESS_THAN             = "LESS_THAN"             // <
 	GREATER_THAN          = "GREATER_THAN"          // >
-	LESS_THAN_EQUAL_TO    = "LESS_THAN_EQUAL_TO"    // <=
-	GREATER_THAN_EQUAL_TO = "GREATER_THAN_EQUAL_TO" // >=
+	LESS_THAN_EQUAL_TO    = "LESS_THAN_EQUAL_TO"    // =<
+	GREATER_THAN_EQUAL_TO = "GREATER_THAN_EQUAL_TO" // =>
+
+	EQUAL_TO_ASSIGN              = "EQUAL_TO_ASSIGN"              // ===
+	NOT_EQUAL_TO_ASSIGN          = "NOT_EQUAL_TO_ASSIGN"          // <>=
+	LESS_THAN_ASSIGN             = "LESS_THAN_ASSIGN"             // <=
+	GREATER_THAN_ASSIGN          = "GREATER_THAN_ASSIGN"          // >=
+	LESS_THAN_EQUAL_TO_ASSIGN    = "LESS_THAN_EQUAL_TO_ASSIGN"    // =<=
+	GREATER_THAN_EQUAL_TO_ASSIGN = "GREATER_THAN_EQUAL_TO_ASSIGN" // =>=
+	AND_ASSIGN                   = "AND_ASSIGN"                   // AND=
+	OR_ASSIGN                    = "OR_ASSIGN"                    // OR=
 
 	BITWISE_AND        = "BITWISE_AND"        // &
 	BITWISE_AND_ASSIGN = "BITWISE_AND_ASSIGN" // &=
@@ -45,6 +75,13 @@ const (
 	BITWISE_SIGN_EXTEND_7      = "BITWISE_SIGN_EXTEND_7"      // ~
 	BITWISE_SIGN_EXTEND_15     = "BITWISE_SIGN_EXTEND_15"     // ~~
 
+	BITWISE_SHIFT_LEFT_ASSIGN         = "BITWISE_SHIFT_LEFT_ASSIGN"         // <<=
+	BITWISE_SHIFT_RIGHT_ASSIGN        = "BITWISE_SHIFT_RIGHT_ASSIGN"        // >>=
+	BITWISE_ROTATE_LEFT_ASSIGN        = "BITWISE_ROTATE_LEFT_ASSIGN"        // <-=
+	BITWISE_ROTATE_RIGHT_ASSIGN       = "BITWISE_ROTATE_RIGHT_ASSIGN"       // ->=
+	BITWISE_REVERSE_ASSIGN            = "BITWISE_REVERSE_ASSIGN"            // ><=
+	BITWISE_SIGNED_SHIFT_RIGHT_ASSIGN = "BITWISE_SIGNED_SHIFT_RIGHT_ASSIGN" // ~>=
+
 	// Misc characters
 	BRACKET_OPEN  = "BRACKET_OPEN"  // [
 	BRACKET_CLOSE = "BRACKET_CLOSE" // ]
@@ -55,3 +92,49 @@ const (
 	BRACE_OPEN    = "BRACE_OPEN"    // {
 	BRACE_CLOSE   = "BRACE_CLOSE"   // }
 )
+
+// Binary operator precedence, from the Spin operator table. Higher binds
+// tighter. NOT applies to operands that bind at least as tightly as
+// comparisons.
+const (
+	LowestPrec     = 1 // OR
+	NotPrec        = 3
+	ComparisonPrec = 4
+	HighestPrec    = 10 // shifts
+)
+
+// Precedence returns the precedence of the binary operator t, or 0 if t
+// is not a binary operator.
+func (t Type) Precedence() int {
+	switch t {
+	case OR:
+		return LowestPrec
+	case AND:
+		return 2
+	case EQUAL_TO,
+		NOT_EQUAL_TO,
+		LESS_THAN,
+		GREATER_THAN,
+		LESS_THAN_EQUAL_TO,
+		GREATER_THAN_EQUAL_TO:
+		return ComparisonPrec
+	case LIMIT_MINIMUM, LIMIT_MAXIMUM:
+		return 5
+	case ADD, SUBTRACT:
+		return 6
+	case MULTIPLY, MULTIPLY_HIGH, DIVIDE, MODULO:
+		return 7
+	case BITWISE_OR, BITWISE_XOR:
+		return 8
+	case BITWISE_AND:
+		return 9
+	case BITWISE_SHIFT_LEFT,
+		BITWISE_SHIFT_RIGHT,
+		BITWISE_SIGNED_SHIFT_RIGHT,
+		BITWISE_ROTATE_LEFT,
+		BITWISE_ROTATE_RIGHT,
+		BITWISE_REVERSE:
+		return HighestPrec
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A Position is a printable source position. Line and Column start at 1.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) String() string {
+	s := p.Filename
+	if s == "" {
+		s = "<input>"
+	}
+	if p.Line > 0 {
+		s += fmt.Sprintf(":%d:%d", p.Line, p.Column)
+	}
+	return s
+}
+
+// A File records where the lines of a source file start, so that a Pos
+// can be turned into a Position.
+type File struct {
+	Name  string
+	lines []int // offset of the first character of each line
+}
+
+func NewFile(name string) *File {
+	return &File{Name: name, lines: []int{0}}
+}
+
+// AddLine records that a line starts at offset. Offsets must be added in
+// increasing order; others are ignored.
+func (f *File) AddLine(offset int) {
+	if offset > f.lines[len(f.lines)-1] {
+		f.lines = append(f.lines, offset)
+	}
+}
+
+// Pos returns the position of the byte at offset.
+func (f *File) Pos(offset int) Pos {
+	return Pos(offset + 1)
+}
+
+// Offset returns the byte offset of p.
+func (f *File) Offset(p Pos) int {
+	return int(p) - 1
+}
+
+// Position returns the line and column of p. Columns count bytes.
+func (f *File) Position(p Pos) Position {
+	if !p.IsValid() {
+		return Position{Filename: f.Name}
+	}
+	offset := f.Offset(p)
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	return Position{Filename: f.Name, Line: i + 1, Column: offset - f.lines[i] + 1}
+}
@@ -12,3 +12,6 @@ const (
 	OBJECT      = "OBJECT"
 	CONSTANT    = "CONSTANT"
 )
+
+// States lists every lexer state.
+var States = []State{DEFAULT, COMMENT, DOC_COMMENT, FUNCTION, VARIABLE, DATA, OBJECT, CONSTANT}
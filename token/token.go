@@ -4,14 +4,26 @@ import "github.com/bweir/lame/token/state"
 
 type Type string
 
+// A Pos is a position in a source file: the byte offset plus one. The
+// zero value, NoPos, is no position. Use a File to turn a Pos into a line
+// and column.
 type Pos int
 
+const NoPos Pos = 0
+
+// IsValid reports whether the position is set.
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
 type Token struct {
 	Type    Type
 	Literal string
 	State   state.State
-	Line    int
-	Column  int
+	Pos     Pos // position of the first character
+	End     Pos // position just after the last character
+	Line    int // zero-based line of the first character
+	Column  int // zero-based column of the first character
 }
 
 const (
@@ -30,6 +42,7 @@ const (
 	BINARY_NUMBER      = "BINARY_NUMBER"
 	QUATERNARY_NUMBER  = "QUATERNARY_NUMBER"
 	HEXADECIMAL_NUMBER = "HEXADECIMAL_NUMBER"
+	FLOAT_NUMBER       = "FLOAT_NUMBER"
 
 	COMMENT     = "COMMENT"
 	DOC_COMMENT = "DOC_COMMENT"
@@ -0,0 +1,29 @@
+package token
+
+// Types lists every token type.
+var Types = []Type{
+	NULL, EOF, SPACE, NEWLINE, INDENT, DEDENT, IDENTIFIER, DECIMAL_NUMBER,
+	BINARY_NUMBER, QUATERNARY_NUMBER, HEXADECIMAL_NUMBER, FLOAT_NUMBER,
+	COMMENT, DOC_COMMENT, STRING,
+	CON, DAT, OBJ, PRI, PUB, VAR, TRUE, FALSE, CASE, IF, IFNOT, ELSEIF,
+	ELSEIFNOT, ELSE, OTHER, NEXT, QUIT, REPEAT, FROM, TO, STEP, WHILE, UNTIL,
+	RETURN, ABORT, BYTE, WORD, LONG, NOT, AND, OR,
+	ADD, SUBTRACT, MULTIPLY, DIVIDE, MODULO, ASSIGN, ADD_ASSIGN,
+	SUBTRACT_ASSIGN, MULTIPLY_ASSIGN, DIVIDE_ASSIGN, MODULO_ASSIGN,
+	MULTIPLY_HIGH, MULTIPLY_HIGH_ASSIGN, LIMIT_MINIMUM, LIMIT_MINIMUM_ASSIGN,
+	LIMIT_MAXIMUM, LIMIT_MAXIMUM_ASSIGN, INCREMENT, DECREMENT, ABSOLUTE,
+	DECODE, ENCODE, SQUARE_ROOT, RANDOM, AT, AT_AT, POUND, DOLLAR, DOT, RANGE,
+	PIPE, EQUAL_TO, NOT_EQUAL_TO, LESS_THAN, GREATER_THAN, LESS_THAN_EQUAL_TO,
+	GREATER_THAN_EQUAL_TO, EQUAL_TO_ASSIGN, NOT_EQUAL_TO_ASSIGN,
+	LESS_THAN_ASSIGN, GREATER_THAN_ASSIGN, LESS_THAN_EQUAL_TO_ASSIGN,
+	GREATER_THAN_EQUAL_TO_ASSIGN, AND_ASSIGN, OR_ASSIGN, BITWISE_AND, BITWISE_AND_ASSIGN, BITWISE_OR,
+	BITWISE_OR_ASSIGN, BITWISE_XOR, BITWISE_XOR_ASSIGN, BITWISE_NOT,
+	BITWISE_SHIFT_LEFT, BITWISE_SHIFT_RIGHT, BITWISE_ROTATE_LEFT,
+	BITWISE_ROTATE_RIGHT, BITWISE_REVERSE, BITWISE_SIGNED_SHIFT_RIGHT,
+	BITWISE_SIGN_EXTEND_7, BITWISE_SIGN_EXTEND_15, BITWISE_SHIFT_LEFT_ASSIGN,
+	BITWISE_SHIFT_RIGHT_ASSIGN, BITWISE_ROTATE_LEFT_ASSIGN,
+	BITWISE_ROTATE_RIGHT_ASSIGN, BITWISE_REVERSE_ASSIGN,
+	BITWISE_SIGNED_SHIFT_RIGHT_ASSIGN, BRACKET_OPEN, BRACKET_CLOSE, COMMA,
+	PAREN_OPEN, PAREN_CLOSE, COLON, BRACE_OPEN, BRACE_CLOSE,
+	ILLEGAL, UNEXPECTED_EOF,
+}
@@ -0,0 +1,84 @@
+package vet
+
+import (
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/checker"
+)
+
+// compilerConstants are the constants that the compiler reads from the
+// top object, which are used without being named.
+var compilerConstants = map[string]bool{
+	"_CLKMODE": true,
+	"_CLKFREQ": true,
+	"_XINFREQ": true,
+	"_STACK":   true,
+	"_FREE":    true,
+}
+
+func unusedLocals(p *pass)     { p.unusedInMethods(checker.Local) }
+func unusedParameters(p *pass) { p.unusedInMethods(checker.Parameter) }
+
+// unusedInMethods reports the method symbols of kind k that are never
+// used.
+func (p *pass) unusedInMethods(k checker.Kind) {
+	p.methods(func(m ast.Block, body []ast.Statement) {
+		for _, sym := range p.object.Info.Scopes[m].Symbols() {
+			if sym.Kind == k && !p.used[sym] {
+				p.reportf(sym.Decl.Pos(), "%s %s is never used", k, sym.Name)
+			}
+		}
+	})
+}
+
+func unusedConstants(p *pass) {
+	for _, sym := range p.object.Info.Scope.Symbols() {
+		if sym.Kind == checker.Constant && !p.used[sym] && !compilerConstants[strings.ToUpper(sym.Name)] {
+			p.reportf(sym.Decl.Pos(), "constant %s is never used", sym.Name)
+		}
+	}
+}
+
+func unusedMethods(p *pass) {
+	for _, b := range p.object.Object.Blocks {
+		if b, ok := b.(*ast.PriBlock); ok {
+			if sym := p.object.Info.Defs[b]; sym != nil && !p.used[sym] {
+				p.reportf(b.Pos(), "method %s is never called", sym.Name)
+			}
+		}
+	}
+}
+
+func shadow(p *pass) {
+	object := p.object.Info.Scope
+	p.methods(func(m ast.Block, body []ast.Statement) {
+		for _, sym := range p.object.Info.Scopes[m].Symbols() {
+			if sym.Decl == m {
+				continue // the implicit RESULT
+			}
+			if prev := object.Lookup(sym.Name); prev != nil {
+				p.reportf(sym.Decl.Pos(), "%s %s shadows %s %s declared at line %d",
+					sym.Kind, sym.Name, prev.Kind, prev.Name, p.line(prev.Decl.Pos()))
+			}
+		}
+	})
+}
+
+func nameCase(p *pass) {
+	info := p.object.Info
+	ast.Inspect(p.object.Object, func(n ast.Node) bool {
+		x, ok := n.(*ast.Identifier)
+		if !ok {
+			return true
+		}
+		// Built-in names, names of other objects and the implicit RESULT
+		// are not in Defs.
+		sym := info.Uses[x]
+		if sym == nil || info.Defs[sym.Decl] != sym || x.Name == sym.Name || !strings.EqualFold(x.Name, sym.Name) {
+			return true
+		}
+		p.reportf(x.Pos(), "%s differs only in case from %s declared at line %d", x.Name, sym.Name, p.line(sym.Decl.Pos()))
+		return true
+	})
+}
@@ -0,0 +1,228 @@
+package vet
+
+import (
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/token"
+)
+
+func unreachable(p *pass) {
+	p.bodies(func(list []ast.Statement) {
+		for i := 0; i+1 < len(list); i++ {
+			if p.terminates(list[i]) {
+				p.reportf(list[i+1].Pos(), "unreachable code")
+				return
+			}
+		}
+	})
+}
+
+// terminates reports whether the statements after s are never run: s
+// leaves the method or loop, or every branch of s does, or s loops
+// forever.
+func (p *pass) terminates(s ast.Statement) bool {
+	switch s := s.(type) {
+	case *ast.ReturnStatement, *ast.AbortStatement, *ast.NextStatement, *ast.QuitStatement:
+		return true
+	case *ast.IfStatement:
+		return s.Else != nil && p.terminatesList(s.Body) && p.terminates(s.Else)
+	case *ast.ElseStatement:
+		return p.terminatesList(s.Body)
+	case *ast.CaseStatement:
+		other := false
+		for _, arm := range s.Arms {
+			if !p.terminatesList(arm.Body) {
+				return false
+			}
+			other = other || arm.Other
+		}
+		return other
+	case *ast.RepeatStatement, *ast.RepeatWhileStatement:
+		return p.endless(s) && !quits(s)
+	}
+	return false
+}
+
+// terminatesList reports whether a statement of list terminates.
+func (p *pass) terminatesList(list []ast.Statement) bool {
+	for _, s := range list {
+		if p.terminates(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// endless reports whether the loop s has no condition that ends it: a
+// repeat without a count, or a repeat while or until whose condition is
+// constant and never ends it.
+func (p *pass) endless(s ast.Statement) bool {
+	switch s := s.(type) {
+	case *ast.RepeatStatement:
+		return s.Count == nil
+	case *ast.RepeatWhileStatement:
+		v, ok := p.constant(s.Condition)
+		return ok && (v != 0) == (s.Keyword == token.WHILE)
+	}
+	return false
+}
+
+// quits reports whether the body of the loop s has a quit statement that
+// exits s, rather than a loop inside it.
+func quits(s ast.Statement) bool {
+	found := false
+	ast.Inspect(s, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.RepeatStatement, *ast.RepeatRangeStatement, *ast.RepeatWhileStatement:
+			return n == s
+		case *ast.QuitStatement:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// leaves reports whether the body of the loop s has a quit, return or
+// abort statement.
+func leaves(s ast.Statement) bool {
+	found := false
+	ast.Inspect(s, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.ReturnStatement, *ast.AbortStatement:
+			found = true
+		}
+		return !found
+	})
+	return found || quits(s)
+}
+
+func endlessRepeat(p *pass) {
+	p.methods(func(m ast.Block, body []ast.Statement) {
+		var last ast.Statement
+		if p.entries[m] && len(body) > 0 {
+			last = body[len(body)-1]
+		}
+		for _, s := range body {
+			ast.Inspect(s, func(n ast.Node) bool {
+				if s, ok := n.(ast.Statement); ok && s != last && p.endless(s) && !leaves(s) {
+					p.reportf(s.Pos(), "repeat loop never exits and is not at the end of a cog's entry method")
+				}
+				return true
+			})
+		}
+	})
+}
+
+func ifAssign(p *pass) {
+	p.methods(func(m ast.Block, body []ast.Statement) {
+		for _, s := range body {
+			ast.Inspect(s, func(n ast.Node) bool {
+				// As in C, parentheses mark an assignment that is meant,
+				// as in "if (x := next)".
+				if s, ok := n.(*ast.IfStatement); ok {
+					if x, ok := s.Condition.(*ast.AssignmentExpression); ok && x.Operator == token.ASSIGN {
+						p.reportf(x.Pos(), "assignment used as if condition; use == to compare, or parenthesize the assignment")
+					}
+				}
+				return true
+			})
+		}
+	})
+}
+
+// An interval is the values from lo to hi that a case match matches.
+type interval struct{ lo, hi int32 }
+
+func deadCase(p *pass) {
+	p.methods(func(m ast.Block, body []ast.Statement) {
+		for _, s := range body {
+			ast.Inspect(s, func(n ast.Node) bool {
+				if s, ok := n.(*ast.CaseStatement); ok {
+					p.deadArms(s)
+				}
+				return true
+			})
+		}
+	})
+}
+
+// deadArms reports the arms of s that never match: those after the
+// other arm or an arm that always matches, those that cannot match a
+// constant case value, and those whose values earlier arms match.
+func (p *pass) deadArms(s *ast.CaseStatement) {
+	value, known := p.constant(s.Value)
+	var seen []interval
+	var after string
+	for _, arm := range s.Arms {
+		if after != "" {
+			p.reportf(arm.Pos(), "case arm never matches: %s", after)
+			continue
+		}
+		if arm.Other {
+			after = "it follows the other arm"
+			continue
+		}
+
+		all := len(arm.Matches) > 0
+		var matches []interval
+		for _, x := range arm.Matches {
+			if m, ok := p.interval(x); ok {
+				matches = append(matches, m)
+			} else {
+				all = false
+			}
+		}
+		switch {
+		case known && all && !contains(matches, value):
+			p.reportf(arm.Pos(), "case arm never matches: the case value is %d", value)
+		case known && contains(matches, value):
+			after = "an earlier arm matches the case value"
+		case all && covers(seen, matches):
+			p.reportf(arm.Pos(), "case arm never matches: earlier arms match all its values")
+		}
+		seen = append(seen, matches...)
+	}
+}
+
+// interval returns the values that the case match x matches, if they are
+// known at compile time.
+func (p *pass) interval(x ast.Expression) (interval, bool) {
+	if r, ok := x.(*ast.RangeExpression); ok {
+		lo, ok1 := p.constant(r.Low)
+		hi, ok2 := p.constant(r.High)
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		return interval{lo, hi}, ok1 && ok2
+	}
+	v, ok := p.constant(x)
+	return interval{v, v}, ok
+}
+
+// contains reports whether one of list contains v.
+func contains(list []interval, v int32) bool {
+	for _, m := range list {
+		if m.lo <= v && v <= m.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether every interval of matches is inside one of
+// seen.
+func covers(seen, matches []interval) bool {
+	for _, m := range matches {
+		inside := false
+		for _, s := range seen {
+			if s.lo <= m.lo && m.hi <= s.hi {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return false
+		}
+	}
+	return true
+}
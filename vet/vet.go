@@ -0,0 +1,201 @@
+// Package vet reports suspicious constructs in Spin objects: code that
+// compiles, but is unlikely to do what was meant, such as unused names,
+// unreachable statements and case arms that never match.
+//
+// Vet works on objects that the checker has resolved without errors.
+// Each check is named by the code of the warnings it reports, and can be
+// switched off by leaving it out of Config.Checks.
+package vet
+
+import (
+	"strings"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/checker"
+	"github.com/bweir/lame/diagnostic"
+	"github.com/bweir/lame/token"
+)
+
+// A Check is one analysis. Its name is the code of the warnings it
+// reports.
+type Check struct {
+	Name string
+	Doc  string
+	run  func(p *pass)
+}
+
+// Checks lists every check.
+var Checks = []*Check{
+	{Name: "unused-local", Doc: "locals that are never used", run: unusedLocals},
+	{Name: "unused-parameter", Doc: "parameters that are never used", run: unusedParameters},
+	{Name: "unused-constant", Doc: "constants that are never used in the program", run: unusedConstants},
+	{Name: "unused-method", Doc: "PRI methods that are never called", run: unusedMethods},
+	{Name: "shadow", Doc: "parameters, results and locals that shadow object names", run: shadow},
+	{Name: "name-case", Doc: "names spelled with a different case than their declaration", run: nameCase},
+	{Name: "unreachable", Doc: "statements after return, abort, next, quit or an endless loop", run: unreachable},
+	{Name: "dead-case", Doc: "case arms that never match", run: deadCase},
+	{Name: "if-assign", Doc: "assignments used as if conditions", run: ifAssign},
+	{Name: "endless-repeat", Doc: "repeat loops with no exit that are not at the end of a cog's entry method", run: endlessRepeat},
+}
+
+// An Object is an object to vet, with the names the checker resolved.
+type Object struct {
+	File   *token.File
+	Object *ast.Object
+	Info   *checker.Info
+}
+
+// A Config configures vet.
+type Config struct {
+	// Checks lists the checks to run. If nil, every check is run.
+	Checks []*Check
+
+	// Constant returns the value of x if it is known at compile time.
+	// If Constant is nil, no values are known, and the checks that need
+	// them find less.
+	Constant func(x ast.Expression) (int32, bool)
+}
+
+// Vet runs the checks of conf over the objects of a program, which lists
+// the top object last. Names are used if any object uses them, so that a
+// constant only used as obj#NAME is not reported.
+func (conf *Config) Vet(objects []*Object) diagnostic.List {
+	checks := conf.Checks
+	if checks == nil {
+		checks = Checks
+	}
+	p := &pass{
+		conf:    conf,
+		used:    make(map[*checker.Symbol]bool),
+		entries: make(map[ast.Block]bool),
+	}
+	for _, o := range objects {
+		for _, sym := range o.Info.Uses {
+			p.used[sym] = true
+		}
+		p.object = o
+		p.findEntries()
+	}
+	// The first PUB method of the top object runs in the first cog.
+	if len(objects) > 0 {
+		for _, b := range objects[len(objects)-1].Object.Blocks {
+			if b, ok := b.(*ast.PubBlock); ok {
+				p.entries[b] = true
+				break
+			}
+		}
+	}
+
+	for _, c := range checks {
+		p.check = c
+		for _, o := range objects {
+			p.object = o
+			c.run(p)
+		}
+	}
+	p.diagnostics.Sort()
+	return p.diagnostics
+}
+
+// A pass holds the state of vetting a program.
+type pass struct {
+	conf        *Config
+	check       *Check
+	object      *Object
+	diagnostics diagnostic.List
+
+	// used holds the symbols used anywhere in the program.
+	used map[*checker.Symbol]bool
+
+	// entries holds the methods that cogs start in.
+	entries map[ast.Block]bool
+}
+
+// reportf reports a warning of the current check at pos.
+func (p *pass) reportf(pos token.Pos, format string, args ...interface{}) {
+	p.diagnostics.Add(diagnostic.Warning, p.object.File.Position(pos), p.check.Name, format, args...)
+}
+
+// line returns the line of pos in the current object.
+func (p *pass) line(pos token.Pos) int {
+	return p.object.File.Position(pos).Line
+}
+
+// constant returns the value of x if it is known at compile time.
+func (p *pass) constant(x ast.Expression) (int32, bool) {
+	if p.conf.Constant == nil {
+		return 0, false
+	}
+	return p.conf.Constant(x)
+}
+
+// findEntries adds the methods that the current object starts cogs in
+// with COGNEW(method(...), @stack) or COGINIT(id, method(...), @stack).
+func (p *pass) findEntries() {
+	info := p.object.Info
+	ast.Inspect(p.object.Object, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		f, ok := call.Function.(*ast.Identifier)
+		if !ok || info.Uses[f] == nil || info.Uses[f].Decl != nil {
+			return true
+		}
+		var arg ast.Expression
+		switch {
+		case strings.EqualFold(f.Name, "COGNEW") && len(call.Arguments) > 0:
+			arg = call.Arguments[0]
+		case strings.EqualFold(f.Name, "COGINIT") && len(call.Arguments) > 1:
+			arg = call.Arguments[1]
+		}
+		if c, ok := arg.(*ast.CallExpression); ok {
+			arg = c.Function
+		}
+		if id, ok := arg.(*ast.Identifier); ok {
+			if sym := info.Uses[id]; sym != nil && sym.Kind == checker.Method {
+				p.entries[sym.Decl.(ast.Block)] = true
+			}
+		}
+		return true
+	})
+}
+
+// methods calls f for each method of the current object.
+func (p *pass) methods(f func(m ast.Block, body []ast.Statement)) {
+	for _, b := range p.object.Object.Blocks {
+		switch b := b.(type) {
+		case *ast.PubBlock:
+			f(b, b.Body)
+		case *ast.PriBlock:
+			f(b, b.Body)
+		}
+	}
+}
+
+// bodies calls f for each statement list of the current object: the
+// bodies of methods, and of the statements in them.
+func (p *pass) bodies(f func(list []ast.Statement)) {
+	p.methods(func(m ast.Block, body []ast.Statement) {
+		f(body)
+		for _, s := range body {
+			ast.Inspect(s, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.IfStatement:
+					f(n.Body)
+				case *ast.ElseStatement:
+					f(n.Body)
+				case *ast.RepeatStatement:
+					f(n.Body)
+				case *ast.RepeatRangeStatement:
+					f(n.Body)
+				case *ast.RepeatWhileStatement:
+					f(n.Body)
+				case *ast.CaseArm:
+					f(n.Body)
+				}
+				return true
+			})
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package vet_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/bweir/lame/ast"
+	"github.com/bweir/lame/compiler"
+	"github.com/bweir/lame/vet"
+)
+
+// run checks main.spin in files and runs the check called name over it.
+func run(t *testing.T, files map[string]string, dialect compiler.Dialect, name string) string {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, src := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(src)}
+	}
+	r, err := compiler.Check("main.spin", &compiler.Options{FS: fsys, Dialect: dialect})
+	if err != nil {
+		t.Fatal(err)
+	} else if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &vet.Config{Constant: func(x ast.Expression) (int32, bool) {
+		v, ok := r.Constant(x)
+		return v.Bits, ok
+	}}
+	for _, c := range vet.Checks {
+		if c.Name == name {
+			conf.Checks = append(conf.Checks, c)
+		}
+	}
+	if len(conf.Checks) == 0 {
+		t.Fatalf("unknown check %s", name)
+	}
+	var objects []*vet.Object
+	for _, s := range r.Tree.Sources {
+		objects = append(objects, &vet.Object{File: s.File, Object: s.Object, Info: r.Infos[s]})
+	}
+	return conf.Vet(objects).Error()
+}
+
+// Ensure each check reports what it is meant to, and nothing else.
+func TestVet(t *testing.T) {
+	var tests = []struct {
+		check   string
+		src     string
+		dialect compiler.Dialect
+		diag    string
+	}{
+		{check: "unused-local", src: "PUB main | x, y\n  x := 1", diag: `main.spin:1:15: warning: local y is never used [unused-local]`},
+		{check: "unused-parameter", src: "PUB main(a, b)\n  return b", diag: `main.spin:1:10: warning: parameter a is never used [unused-parameter]`},
+		{check: "unused-constant", src: "CON\n  _clkmode = xtal1\n  A = 1\n  B = 2\n  C = lib#D\nOBJ\n  lib : \"lib\"\nPUB main\n  return A + C", diag: `main.spin:4:3: warning: constant B is never used [unused-constant]`},
+		{check: "unused-method", src: "PUB main\n  f\nPRI f\nPRI g\n  g", diag: "no errors"},
+		{check: "unused-method", src: "PUB main\nPRI f\nPUB g", diag: `main.spin:2:1: warning: method f is never called [unused-method]`},
+		{check: "shadow", src: "VAR\n  long x\nPUB main(a) | X\n  X := a", dialect: compiler.Lame, diag: `main.spin:3:15: warning: local X shadows variable x declared at line 2 [shadow]`},
+		{check: "name-case", src: "CON\n  Tile_W = 8\nPUB main : r\n  r := TILE_W + Tile_W + Result + cnt + CNT", diag: `main.spin:4:8: warning: TILE_W differs only in case from Tile_W declared at line 2 [name-case]`},
+		{check: "unreachable", src: "PUB main | x\n  repeat\n    if x\n      quit\n    next\n    x++\n  return\n  x := 1", diag: "main.spin:6:5: warning: unreachable code [unreachable]\nmain.spin:8:3: warning: unreachable code [unreachable]"},
+		{check: "unreachable", src: "PUB main | x\n  if x\n    abort\n  else\n    return\n  x := 1", diag: `main.spin:6:3: warning: unreachable code [unreachable]`},
+		{check: "unreachable", src: "PUB main | x\n  repeat\n    if x\n      return\n  x := 1", diag: `main.spin:5:3: warning: unreachable code [unreachable]`},
+		{check: "unreachable", src: "PUB main | x\n  repeat\n    if x\n      quit\n  x := 1", diag: "no errors"},
+		{check: "dead-case", src: "CON\n  A = 2\nPUB main | x\n  case x\n    1, A: x := 0\n    0..2: x := 1\n    2: x := 2\n    other: x := 3\n    4: x := 4", diag: "main.spin:7:5: warning: case arm never matches: earlier arms match all its values [dead-case]\nmain.spin:9:5: warning: case arm never matches: it follows the other arm [dead-case]"},
+		{check: "dead-case", src: "CON\n  A = 2\nPUB main | x\n  case A\n    1: x := 0\n    x: x := 1\n    0..2: x := 2\n    3: x := 3", diag: "main.spin:5:5: warning: case arm never matches: the case value is 2 [dead-case]\nmain.spin:8:5: warning: case arm never matches: an earlier arm matches the case value [dead-case]"},
+		{check: "if-assign", src: "PUB main | x\n  if x := 1\n    x := 2\n  elseif (x := 2)\n    x := 3\n  ifnot x == 1\n    x := 4", diag: `main.spin:2:6: warning: assignment used as if condition; use == to compare, or parenthesize the assignment [if-assign]`},
+		{check: "endless-repeat", src: "VAR\n  long stack[16]\nPUB main\n  cognew(loop(1), @stack)\n  repeat\nPRI loop(a)\n  repeat\n    a++\n  a := 1\n  repeat while true\n  repeat until a\n  repeat\n    quit\n  repeat\n    return\n  repeat", diag: "main.spin:7:3: warning: repeat loop never exits and is not at the end of a cog's entry method [endless-repeat]\nmain.spin:10:3: warning: repeat loop never exits and is not at the end of a cog's entry method [endless-repeat]"},
+		{check: "endless-repeat", src: "PUB main\n  helper\nPUB helper\n  repeat", diag: `main.spin:4:3: warning: repeat loop never exits and is not at the end of a cog's entry method [endless-repeat]`},
+	}
+
+	for i, tt := range tests {
+		diag := run(t, map[string]string{
+			"main.spin": tt.src,
+			"lib.spin":  "CON\n  D = 1",
+		}, tt.dialect, tt.check)
+		if diag != tt.diag {
+			t.Errorf("%d. %s: %q mismatch:\nexp=%s\ngot=%s", i, tt.check, tt.src, tt.diag, diag)
+		}
+	}
+}
+
+// Ensure names may only shadow object names in the Lame dialect.
+func TestVet_ShadowSpin(t *testing.T) {
+	r, err := compiler.Check("main.spin", &compiler.Options{FS: fstest.MapFS{
+		"main.spin": {Data: []byte("VAR\n  long x\nPUB main | X")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := r.Diagnostics.Error(), `main.spin:3:12: error: X redeclared, first declared at line 2 [redeclared]`; got != exp {
+		t.Errorf("mismatch:\nexp=%s\ngot=%s", exp, got)
+	}
+}